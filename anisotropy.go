@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// anisotropy - distance under a positive-definite matrix M, sqrt((p-q)ᵀ M (p-q)), as the euclidean distance
+// between the points taken through the linear transform A, where M = AᵀA. A is the transpose of the
+// Cholesky factor of M, so it is upper triangular.
+type anisotropy struct {
+	forward [2][2]float64 // A
+	inverse [2][2]float64
+}
+
+// newAnisotropy returns the transform for a matrix, or an error wrapping ErrInvalidInput if it is not
+// finite, symmetric and positive-definite
+func newAnisotropy(matrix [2][2]float64) (*anisotropy, error) {
+	a, b, c := matrix[0][0], matrix[0][1], matrix[1][1]
+	if !isFinite(a) || !isFinite(b) || !isFinite(c) || b != matrix[1][0] || a <= 0 || a*c-b*b <= 0 {
+		return nil, &InputError{Index: -1, Reason: fmt.Sprintf("anisotropy %v is not a symmetric, positive-definite "+
+			"matrix", matrix), Err: ErrInvalidInput}
+	}
+	root := math.Sqrt(a)
+	forward := [2][2]float64{{root, b / root}, {0, math.Sqrt(c - b*b/a)}}
+	inverse := [2][2]float64{{1 / forward[0][0], -forward[0][1] / (forward[0][0] * forward[1][1])},
+		{0, 1 / forward[1][1]}}
+	return &anisotropy{forward: forward, inverse: inverse}, nil
+}
+
+// validateAnisotropy checks that an anisotropy in the options can be combined with the others
+func validateAnisotropy(options Options) error {
+	if options.Anisotropy == ([2][2]float64{}) {
+		return nil
+	}
+	if options.Metric != Euclidean || options.Periodic || options.Weighting != Unweighted {
+		return &InputError{Index: -1, Reason: "anisotropy needs an unweighted euclidean diagram in a box which " +
+			"does not wrap", Err: ErrInvalidInput}
+	}
+	_, err := newAnisotropy(options.Anisotropy)
+	return err
+}
+
+// plainEuclidean - whether the options measure plain euclidean distance in a box which does not wrap, as the
+// diagrams built on top of the voronoi diagram of the sites need
+func (options Options) plainEuclidean() bool {
+	return options.Metric == Euclidean && !options.Periodic && options.Weighting == Unweighted &&
+		options.Anisotropy == ([2][2]float64{})
+}
+
+// applyMatrix - the product of a matrix and a point
+func applyMatrix(matrix [2][2]float64, p vertex) vertex {
+	return vertex{x: matrix[0][0]*p.x + matrix[0][1]*p.y, y: matrix[1][0]*p.x + matrix[1][1]*p.y}
+}
+
+// distance - the distance between two points under the matrix
+func (anisotropy *anisotropy) distance(p, q vertex) float64 {
+	d := applyMatrix(anisotropy.forward, vertex{x: q.x - p.x, y: q.y - p.y})
+	return math.Hypot(d.x, d.y)
+}
+
+// nearest returns whichever of the candidate sites (or all of the sites if there are no candidates) is
+// nearest p under the matrix
+func (anisotropy *anisotropy) nearest(siteList []site, candidates []SiteID, p vertex) SiteID {
+	nearest, nearestDistance := SiteID(-1), math.Inf(1)
+	consider := func(id SiteID) {
+		if distance := anisotropy.distance(p, vertex(siteList[id])); nearest < 0 || distance < nearestDistance {
+			nearest, nearestDistance = id, distance
+		}
+	}
+	if candidates == nil {
+		for id := range siteList {
+			consider(SiteID(id))
+		}
+	}
+	for _, id := range candidates {
+		consider(id)
+	}
+	return nearest
+}
+
+// distanceToSegment - the distance under the matrix from p to the nearest point of the segment from a to b,
+// which is the euclidean distance between them after the transform
+func (anisotropy *anisotropy) distanceToSegment(p, a, b vertex) float64 {
+	return distanceToSegment(applyMatrix(anisotropy.forward, p), applyMatrix(anisotropy.forward, a),
+		applyMatrix(anisotropy.forward, b))
+}
+
+// computeAnisotropic finds the cells of a diagram whose distance is measured under a matrix. Its sites are
+// taken through the transform, where distance is euclidean, and the diagram of them is swept over the box
+// around the transformed bounding box, a parallelogram. The cells of that diagram are mapped back through
+// the inverse transform and clipped to the bounding box, and the dcel is made from them.
+func (builder *Builder) computeAnisotropic(limits *sweepLimits, diagram *Diagram, options Options) error {
+	box, anisotropy := diagram.boundingBox, diagram.anisotropy
+	corners := []vertex{{0, 0}, {box.width, 0}, {box.width, box.height}, {0, box.height}}
+	low, high := vertex{x: math.Inf(1), y: math.Inf(1)}, vertex{x: math.Inf(-1), y: math.Inf(-1)}
+	for _, corner := range corners {
+		p := applyMatrix(anisotropy.forward, corner)
+		low = vertex{x: math.Min(low.x, p.x), y: math.Min(low.y, p.y)}
+		high = vertex{x: math.Max(high.x, p.x), y: math.Max(high.y, p.y)}
+	}
+	// The box of the transformed diagram starts at the origin, so the transformed sites are moved into it
+	isotropic := &Diagram{sites: make([]site, len(diagram.sites)), boundingBox: boundingBox{width: high.x - low.x,
+		height: high.y - low.y}, metric: Euclidean}
+	for id, s := range diagram.sites {
+		p := applyMatrix(anisotropy.forward, vertex(s))
+		isotropic.sites[id] = site{x: p.x - low.x, y: p.y - low.y}
+	}
+	if err := builder.computeDCEL(limits, isotropic, options); err != nil {
+		return err
+	}
+
+	cells := make([][]cellCorner, len(diagram.sites))
+	for id, cell := range isotropic.cellPolygons() {
+		mapped := make([]cellCorner, len(cell))
+		for k, corner := range cell {
+			mapped[k] = cellCorner{applyMatrix(anisotropy.inverse, vertex{x: corner.x + low.x, y: corner.y + low.y}),
+				corner.across}
+		}
+		cells[id] = clipToBox(mapped, box)
+	}
+	dcel, err := dcelFromCells(diagram.sites, cells, box)
+	if err != nil {
+		return err
+	}
+	diagram.dcel, diagram.fixedCells = dcel, cells
+	return nil
+}
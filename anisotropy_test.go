@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAnisotropicDiagram(t *testing.T) {
+	source := rand.New(rand.NewSource(20))
+	siteList := uniformSites(100, source)
+	matrix := [2][2]float64{{4, 1.5}, {1.5, 1}}
+	diagram, err := Compute(siteList, benchmarkBox, Options{Anisotropy: matrix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diagram.dcel.validate(); err != nil {
+		t.Fatal(err)
+	}
+	distance := func(id SiteID, p vertex) float64 {
+		d := vertex{p.x - siteList[id].x, p.y - siteList[id].y}
+		return math.Sqrt(matrix[0][0]*d.x*d.x + 2*matrix[0][1]*d.x*d.y + matrix[1][1]*d.y*d.y)
+	}
+
+	// The edges are straight, and every point of one is as near its two sites under the matrix
+	for _, edge := range diagram.CurvedEdges() {
+		for _, p := range edge.Points {
+			if a, b := distance(edge.Sites[0], p), distance(edge.Sites[1], p); math.Abs(a-b) > 1e-6 {
+				t.Fatalf("edge point %v is %v from %d and %v from %d", p, a, edge.Sites[0], b, edge.Sites[1])
+			}
+		}
+	}
+	for k := 0; k < 2000; k++ {
+		p := vertex{source.Float64() * benchmarkBox.width, source.Float64() * benchmarkBox.height}
+		got, want := diagram.Locate(p), SiteID(-1)
+		for id := range siteList {
+			if want < 0 || distance(SiteID(id), p) < distance(want, p) {
+				want = SiteID(id)
+			}
+		}
+		if got != want && distance(got, p)-distance(want, p) > 1e-9 {
+			t.Fatalf("%v is in cell %d, nearest is %d", p, got, want)
+		}
+	}
+	totalArea := 0.0
+	for _, cell := range diagram.CellStats() {
+		totalArea += cell.Area
+	}
+	if math.Abs(totalArea-benchmarkBox.width*benchmarkBox.height) > 1e-6 {
+		t.Errorf("cells cover %v, want %v", totalArea, benchmarkBox.width*benchmarkBox.height)
+	}
+}
+
+func TestAnisotropyStretch(t *testing.T) {
+	// Distance weighing y four times is plain distance with y stretched to twice as far, so each cell is half
+	// the area of the cell in the stretched box
+	siteList := uniformSites(60, rand.New(rand.NewSource(21)))
+	stretched := make([]site, len(siteList))
+	for k, s := range siteList {
+		stretched[k] = site{s.x, 2 * s.y}
+	}
+	want, err := Compute(stretched, boundingBox{width: benchmarkBox.width, height: 2 * benchmarkBox.height},
+		Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagram, err := Compute(siteList, benchmarkBox, Options{Anisotropy: [2][2]float64{{1, 0}, {0, 4}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStats := want.CellStats()
+	for id, cell := range diagram.CellStats() {
+		if math.Abs(cell.Area-wantStats[id].Area/2) > 1e-6 || len(cell.Neighbours) != len(wantStats[id].Neighbours) {
+			t.Fatalf("cell %d has area %v and %d neighbours, want %v and %d", id, cell.Area, len(cell.Neighbours),
+				wantStats[id].Area/2, len(wantStats[id].Neighbours))
+		}
+	}
+	circle := diagram.LargestEmptyCircle()
+	if wantCircle := want.LargestEmptyCircle(); math.Abs(circle.Radius-wantCircle.Radius) > 1e-6 {
+		t.Errorf("the largest empty ellipse has radius %v, want %v", circle.Radius, wantCircle.Radius)
+	}
+}
+
+func TestAnisotropyInput(t *testing.T) {
+	siteList := []site{{10, 10}, {20, 20}, {30, 10}}
+	box := boundingBox{width: 100, height: 100}
+	for _, test := range []struct {
+		name    string
+		options Options
+	}{
+		{"not symmetric", Options{Anisotropy: [2][2]float64{{2, 1}, {0, 2}}}},
+		{"not positive-definite", Options{Anisotropy: [2][2]float64{{1, 2}, {2, 1}}}},
+		{"not finite", Options{Anisotropy: [2][2]float64{{math.NaN(), 0}, {0, 1}}}},
+		{"manhattan", Options{Anisotropy: [2][2]float64{{2, 0}, {0, 1}}, Metric: Manhattan}},
+		{"periodic", Options{Anisotropy: [2][2]float64{{2, 0}, {0, 1}}, Periodic: true}},
+		{"weighted", Options{Anisotropy: [2][2]float64{{2, 0}, {0, 1}}, Weighting: AdditiveWeights,
+			Weights: []float64{1, 2, 3}}},
+	} {
+		if _, err := Compute(siteList, box, test.options); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: got %v, want ErrInvalidInput", test.name, err)
+		}
+	}
+	if _, err := ComputeOrder(siteList, box, 2, Options{Anisotropy: [2][2]float64{{2, 0}, {0, 1}}}); !errors.Is(err,
+		ErrInvalidInput) {
+		t.Errorf("order diagram: got %v, want ErrInvalidInput", err)
+	}
+
+	diagram, err := Compute(siteList, box, Options{Anisotropy: [2][2]float64{{2, 0}, {0, 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diagram.NaturalNeighbours(vertex{50, 50}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("natural neighbours of an anisotropic diagram: got %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+const (
+	minimumSlabBlock = 64
+	maximumSlabBlock = 8192
+)
+
+// A slab hands out pointers to values allocated in blocks, so a sweep makes a handful of allocations rather
+// than one per value. Blocks grow geometrically up to maximumSlabBlock. reset makes every block available
+// again without freeing it - values handed out before a reset are overwritten by later allocations.
+type slab[T any] struct {
+	blocks [][]T
+	block  int // Index of the block currently being filled
+	used   int // Values handed out from the current block
+}
+
+// alloc - return a pointer to a zeroed value
+func (s *slab[T]) alloc() *T {
+	if s.block < len(s.blocks) && s.used == len(s.blocks[s.block]) {
+		s.block++
+		s.used = 0
+	}
+	if s.block == len(s.blocks) {
+		size := minimumSlabBlock
+		if len(s.blocks) > 0 {
+			size = min(2*len(s.blocks[len(s.blocks)-1]), maximumSlabBlock)
+		}
+		s.blocks = append(s.blocks, make([]T, size))
+	}
+	value := &s.blocks[s.block][s.used]
+	var zero T
+	*value = zero
+	s.used++
+	return value
+}
+
+// reset - make all of the blocks available for reuse
+func (s *slab[T]) reset() {
+	s.block = 0
+	s.used = 0
+}
+
+// arena - the slabs for everything the sweep creates. A nil arena allocates each value on the heap.
+type arena struct {
+	nodes       slab[node]
+	breakpoints slab[breakpoint]
+	halfEdges   slab[halfEdge]
+	vertices    slab[vertex]
+	circles     slab[circleEvent]
+}
+
+func (arena *arena) newNode() *node {
+	if arena == nil {
+		return &node{}
+	}
+	return arena.nodes.alloc()
+}
+
+func (arena *arena) newBreakpoint() *breakpoint {
+	if arena == nil {
+		return &breakpoint{}
+	}
+	return arena.breakpoints.alloc()
+}
+
+func (arena *arena) newHalfEdge() *halfEdge {
+	if arena == nil {
+		return &halfEdge{}
+	}
+	return arena.halfEdges.alloc()
+}
+
+func (arena *arena) newVertex() *vertex {
+	if arena == nil {
+		return &vertex{}
+	}
+	return arena.vertices.alloc()
+}
+
+func (arena *arena) newCircleEvent() *circleEvent {
+	if arena == nil {
+		return &circleEvent{}
+	}
+	return arena.circles.alloc()
+}
+
+// reset - make all of the memory in the arena available for reuse
+func (arena *arena) reset() {
+	arena.nodes.reset()
+	arena.breakpoints.reset()
+	arena.halfEdges.reset()
+	arena.vertices.reset()
+	arena.circles.reset()
+}
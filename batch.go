@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// Input - one diagram for ComputeBatch to compute
+type Input struct {
+	ID          int // Chosen by the caller and returned with the result
+	Sites       []site
+	BoundingBox boundingBox
+	Options     Options
+}
+
+// Result - the diagram computed for the Input with the same ID, or the error it failed with
+type Result struct {
+	ID      int
+	Diagram *Diagram
+	Err     error
+}
+
+// ComputeBatch computes a diagram for each input using a pool of GOMAXPROCS workers. Results are sent in the
+// order the inputs were received, whatever order they finish in, so sending inputs in increasing order of ID
+// gives results in increasing order of ID. A failed input only fails its own result. Each diagram is computed
+// on its own, sharing no memory with the others. The result channel is closed once the input channel is
+// closed and every result has been sent. If ctx is cancelled ComputeBatch stops taking inputs and closes the
+// result channel without sending the remaining results.
+func ComputeBatch(ctx context.Context, inputs <-chan Input) <-chan Result {
+	workers := runtime.GOMAXPROCS(0)
+	results := make(chan Result)
+
+	// Each input gets its own channel for its result, queued in the order the inputs arrived. The queue
+	// holds a few results per worker, which bounds how far the workers can get ahead of the slowest input.
+	type job struct {
+		input  Input
+		result chan Result
+	}
+	jobs := make(chan job)
+	pending := make(chan chan Result, 2*workers)
+
+	var wait sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for job := range jobs {
+				result := Result{ID: job.input.ID}
+				if result.Err = ctx.Err(); result.Err == nil {
+					result.Diagram, result.Err = ComputeContext(ctx, job.input.Sites, job.input.BoundingBox,
+						job.input.Options)
+				}
+				job.result <- result
+			}
+		}()
+	}
+
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for {
+			var input Input
+			var ok bool
+			select {
+			case input, ok = <-inputs:
+			case <-ctx.Done():
+				return
+			}
+			if !ok {
+				return
+			}
+			next := job{input: input, result: make(chan Result, 1)}
+			select {
+			case pending <- next.result:
+			case <-ctx.Done():
+				return
+			}
+			jobs <- next
+		}
+	}()
+
+	go func() {
+		defer close(results)
+		for result := range pending {
+			select {
+			case results <- <-result:
+			case <-ctx.Done():
+				// Let the workers finish what they were given so none are left blocked
+				for range pending {
+				}
+				wait.Wait()
+				return
+			}
+		}
+		wait.Wait()
+	}()
+
+	return results
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestComputeBatch(t *testing.T) {
+	source := rand.New(rand.NewSource(4))
+	var batch []Input
+	for id := 0; id < 200; id++ {
+		// Large inputs among the small ones finish out of order
+		size := 2 + source.Intn(20)
+		if id%17 == 0 {
+			size = 2000
+		}
+		input := Input{ID: id, Sites: uniformSites(size, source), BoundingBox: benchmarkBox}
+		if id%10 == 3 {
+			input.Sites = input.Sites[:1]
+		}
+		batch = append(batch, input)
+	}
+
+	inputs := make(chan Input)
+	go func() {
+		defer close(inputs)
+		for _, input := range batch {
+			inputs <- input
+		}
+	}()
+	id := 0
+	for result := range ComputeBatch(context.Background(), inputs) {
+		if result.ID != id {
+			t.Fatalf("got result %d, want %d", result.ID, id)
+		}
+		if id%10 == 3 {
+			if !errors.Is(result.Err, ErrDegenerateInput) {
+				t.Errorf("result %d: got error %v, want ErrDegenerateInput", id, result.Err)
+			}
+		} else if result.Err != nil {
+			t.Errorf("result %d: %v", id, result.Err)
+		} else {
+			want, _ := Compute(batch[id].Sites, benchmarkBox, Options{})
+			if len(result.Diagram.dcel.edges) != len(want.dcel.edges) {
+				t.Errorf("result %d: %d half-edges, want %d", id, len(result.Diagram.dcel.edges),
+					len(want.dcel.edges))
+			}
+		}
+		id++
+	}
+	if id != len(batch) {
+		t.Errorf("got %d results, want %d", id, len(batch))
+	}
+}
+
+func TestComputeBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	inputs := make(chan Input)
+	go func() {
+		// Never closed - only cancelling can end the batch
+		for id := 0; ; id++ {
+			select {
+			case inputs <- Input{ID: id, Sites: uniformSites(10, rand.New(rand.NewSource(1))),
+				BoundingBox: benchmarkBox}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := ComputeBatch(ctx, inputs)
+	for result := range results {
+		if result.ID == 5 {
+			cancel()
+			break
+		}
+	}
+	for range results {
+	}
+}
@@ -3,8 +3,8 @@
 package main
 
 import (
-	"container/heap"
 	"fmt"
+	"math"
 )
 
 const (
@@ -20,7 +20,7 @@ type node struct {
 	breakpoint                          *breakpoint
 	arcSite                             *site
 	key                                 int
-	circleEvent                         *Item
+	circleEvent                         *circleEvent
 	halfEdge                            *halfEdge
 }
 
@@ -28,12 +28,19 @@ type redblacktree struct {
 	root *node
 }
 
-func (rbtree *redblacktree) insert(newKey int, newSite *site, eventQueue *PriorityQueue, dcel *doublyConnectedEdgeList) {
+func (rbtree *redblacktree) insert(newKey int, newSite *site, eventQueue *eventQueue,
+	dcel *doublyConnectedEdgeList) error {
 	if rbtree.root == nil {
-		rbtree.root = &node{key: newKey, colour: black, arcSite: newSite}
-	} else {
-		rbtree.root = rbtree.root.insert(rbtree.root, newKey, newSite, eventQueue, dcel)
+		rbtree.root = dcel.arena.newNode()
+		*rbtree.root = node{key: newKey, colour: black, arcSite: newSite}
+		return nil
+	}
+	root, err := rbtree.root.insert(rbtree.root, newKey, newSite, eventQueue, dcel)
+	if err != nil {
+		return err
 	}
+	rbtree.root = root
+	return nil
 }
 
 // Insert finds the arc on the beachline above the new site (this is the leaf node found) and replaces it with a subtree
@@ -43,105 +50,194 @@ func (rbtree *redblacktree) insert(newKey int, newSite *site, eventQueue *Priori
 //         o  ---------------------->          x     o      | o = leaf node (arc)
 //                   (transform)             /  \
 //                                          o    o
-func (n *node) insert(currentNode *node, newKey int, newSite *site, eventQueue *PriorityQueue,
-	dcel *doublyConnectedEdgeList) *node {
+func (n *node) insert(currentNode *node, newKey int, newSite *site, eventQueue *eventQueue,
+	dcel *doublyConnectedEdgeList) (*node, error) {
 	// Check if this is a leaf node
 	if currentNode.breakpoint == nil {
 
 		if currentNode.circleEvent != nil {
-			// Remove circle event from event queue as it is a false alarm
-			heap.Remove(eventQueue, currentNode.circleEvent.index)
+			// The circle event is a false alarm - it is skipped when it reaches the front of the queue
+			currentNode.circleEvent.falseAlarm = true
+		}
+
+		if currentNode.arcSite.y == newSite.y {
+			return splitArcAtSameHeight(currentNode, newKey, newSite, eventQueue, dcel)
 		}
 
 		// Define the breakpoints that will be used in the two new internal nodes
-		leftBreakpoint := breakpoint{
+		leftBreakpoint, rightBreakpoint := dcel.arena.newBreakpoint(), dcel.arena.newBreakpoint()
+		*leftBreakpoint = breakpoint{
 			leftSite:  currentNode.arcSite,
 			rightSite: newSite,
 		}
-		rightBreakpoint := breakpoint{
+		*rightBreakpoint = breakpoint{
 			leftSite:  newSite,
 			rightSite: currentNode.arcSite,
 		}
 
 		// The 3 leaf nodes that represent the arcs
-		leftLeafNode := node{
+		leftLeafNode, middleLeafNode, rightLeafNode := dcel.arena.newNode(), dcel.arena.newNode(), dcel.arena.newNode()
+		*leftLeafNode = node{
 			arcSite:  currentNode.arcSite,
 			previous: currentNode.previous,
+			next:     middleLeafNode,
 			key:      currentNode.key,
 		}
-		middleLeafNode := node{arcSite: newSite,
-			previous: &leftLeafNode,
+		*middleLeafNode = node{arcSite: newSite,
+			previous: leftLeafNode,
+			next:     rightLeafNode,
 			key:      newKey,
 		}
-		rightLeafNode := node{
+		*rightLeafNode = node{
 			arcSite:  currentNode.arcSite,
 			next:     currentNode.next,
-			previous: &middleLeafNode,
+			previous: middleLeafNode,
 			key:      currentNode.key,
 		}
-		middleLeafNode.next = &rightLeafNode
-		leftLeafNode.next = &middleLeafNode
 
 		// Create and add half-edges to dcel structure
-		leftHalfEdge := dcel.addIsolatedEdge()
+		leftHalfEdge := dcel.addIsolatedEdge(newSite, currentNode.arcSite)
 		rightHalfEdge := leftHalfEdge.twinEdge
 
 		// The 2 internal nodes which represent each edge being traced out
-		leftInternalNode := node{
-			left:       &leftLeafNode,
-			right:      &middleLeafNode,
-			breakpoint: &leftBreakpoint,
+		leftInternalNode, rightInternalNode := dcel.arena.newNode(), dcel.arena.newNode()
+		*leftInternalNode = node{
+			left:       leftLeafNode,
+			right:      middleLeafNode,
+			breakpoint: leftBreakpoint,
 			halfEdge:   leftHalfEdge,
 			key:        newKey,
 		}
-		rightInternalNode := node{
-			left:       &leftInternalNode,
-			right:      &rightLeafNode,
-			breakpoint: &rightBreakpoint,
+		*rightInternalNode = node{
+			left:       leftInternalNode,
+			right:      rightLeafNode,
+			breakpoint: rightBreakpoint,
 			halfEdge:   rightHalfEdge,
 			key:        newKey,
 		}
 
 		// Set parent nodes
-		leftInternalNode.parent = &rightInternalNode
-		rightLeafNode.parent = &rightInternalNode
-		middleLeafNode.parent = &leftInternalNode
-		leftLeafNode.parent = &leftInternalNode
+		leftInternalNode.parent = rightInternalNode
+		rightLeafNode.parent = rightInternalNode
+		middleLeafNode.parent = leftInternalNode
+		leftLeafNode.parent = leftInternalNode
 
 		// Fix prev/next pointers of the old leaf nodes prev/next leaves
 		if currentNode.next != nil {
-			currentNode.next.previous = &rightLeafNode
+			currentNode.next.previous = rightLeafNode
 		}
 		if currentNode.previous != nil {
-			currentNode.previous.next = &leftLeafNode
+			currentNode.previous.next = leftLeafNode
 		}
 
 		// Check for circle event (i.e. check for unique triples of sites on beachline (a,b,c))
-		leftLeafNode.circleEvent = checkCircleEvent(&leftLeafNode, newSite.y, eventQueue)
-		rightLeafNode.circleEvent = checkCircleEvent(&rightLeafNode, newSite.y, eventQueue)
+		var err error
+		if leftLeafNode.circleEvent, err = checkCircleEvent(leftLeafNode, newSite.y, eventQueue); err != nil {
+			return nil, err
+		}
+		if rightLeafNode.circleEvent, err = checkCircleEvent(rightLeafNode, newSite.y, eventQueue); err != nil {
+			return nil, err
+		}
 
-		return &rightInternalNode
+		return rightInternalNode, nil
 	}
 
 	// The directrix will be at the same y coordinate as the new site being added
 	breakpointXCoordinate := getBreakpointXCoordinate(currentNode.breakpoint, newSite.y)
+	if math.IsNaN(breakpointXCoordinate) {
+		return nil, ErrNumericalFailure
+	}
 
+	// A site directly below a breakpoint goes to the right hand arc. This splits off a zero width arc whose
+	// circle event is due immediately, creating the voronoi vertex at the breakpoint.
 	if newSite.x < breakpointXCoordinate {
-		currentNode.left = currentNode.insert(currentNode.left, newKey, newSite, eventQueue, dcel)
+		left, err := currentNode.insert(currentNode.left, newKey, newSite, eventQueue, dcel)
+		if err != nil {
+			return nil, err
+		}
+		currentNode.left = left
 		currentNode.left.parent = currentNode
-	} else if newSite.x > breakpointXCoordinate {
-		currentNode.right = currentNode.insert(currentNode.right, newKey, newSite, eventQueue, dcel)
+	} else {
+		right, err := currentNode.insert(currentNode.right, newKey, newSite, eventQueue, dcel)
+		if err != nil {
+			return nil, err
+		}
+		currentNode.right = right
 		currentNode.right.parent = currentNode
 	}
 
-	return currentNode
+	return currentNode, nil
 }
 
-func (rbtree *redblacktree) removeArc(leafNode *node, eventQueue *PriorityQueue, circleCenter *site,
-	dcel *doublyConnectedEdgeList, sweepline float64, newKey int) {
-	if leafNode.parent == nil {
-		// This happens if only 1 node is in the tree and you call remove - this should never happen
-		rbtree.root = nil
+// splitArcAtSameHeight handles a new site at the same height as the site of the arc above it. This only
+// happens while the sweepline is still at the first site. The new site's parabola is a vertical ray so
+// rather than splitting the arc in three the two arcs simply sit side by side, separated by a vertical edge.
+//                                                x
+// (leaf node found)                            /   \       | x = internal node (breakpoint)
+//         o  ---------------------->          o     o      | o = leaf node (arc)
+//                   (transform)
+func splitArcAtSameHeight(currentNode *node, newKey int, newSite *site, eventQueue *eventQueue,
+	dcel *doublyConnectedEdgeList) (*node, error) {
+	oldLeafNode, newLeafNode := dcel.arena.newNode(), dcel.arena.newNode()
+	*oldLeafNode = node{
+		arcSite: currentNode.arcSite,
+		key:     currentNode.key,
+	}
+	*newLeafNode = node{
+		arcSite: newSite,
+		key:     newKey,
+	}
+
+	leftLeafNode, rightLeafNode := oldLeafNode, newLeafNode
+	if newSite.x < currentNode.arcSite.x {
+		leftLeafNode, rightLeafNode = newLeafNode, oldLeafNode
+	}
+	leftLeafNode.previous = currentNode.previous
+	leftLeafNode.next = rightLeafNode
+	rightLeafNode.previous = leftLeafNode
+	rightLeafNode.next = currentNode.next
+
+	internalBreakpoint := dcel.arena.newBreakpoint()
+	*internalBreakpoint = breakpoint{
+		leftSite:  leftLeafNode.arcSite,
+		rightSite: rightLeafNode.arcSite,
+	}
+	internalNode := dcel.arena.newNode()
+	*internalNode = node{
+		left:       leftLeafNode,
+		right:      rightLeafNode,
+		breakpoint: internalBreakpoint,
+		halfEdge:   dcel.addIsolatedEdge(rightLeafNode.arcSite, leftLeafNode.arcSite),
+		key:        newKey,
+	}
+	leftLeafNode.parent = internalNode
+	rightLeafNode.parent = internalNode
+
+	if currentNode.next != nil {
+		currentNode.next.previous = rightLeafNode
+	}
+	if currentNode.previous != nil {
+		currentNode.previous.next = leftLeafNode
+	}
+
+	var err error
+	if leftLeafNode.circleEvent, err = checkCircleEvent(leftLeafNode, newSite.y, eventQueue); err != nil {
+		return nil, err
+	}
+	if rightLeafNode.circleEvent, err = checkCircleEvent(rightLeafNode, newSite.y, eventQueue); err != nil {
+		return nil, err
+	}
+
+	return internalNode, nil
+}
+
+func (rbtree *redblacktree) removeArc(circle *circleEvent, eventQueue *eventQueue, dcel *doublyConnectedEdgeList,
+	newKey int) error {
+	leafNode := circle.leafNode
+	if leafNode.parent == nil || leafNode.parent.parent == nil || leafNode.previous == nil || leafNode.next == nil {
+		// Only an arc with neighbours on both sides can have a circle event, and such an arc is never a
+		// child of the root - this can only happen if rounding has put arcs in the wrong order
+		return ErrNumericalFailure
 	}
 
 	leftLeafNode := leafNode.previous
@@ -149,10 +245,10 @@ func (rbtree *redblacktree) removeArc(leafNode *node, eventQueue *PriorityQueue,
 
 	// Check if left or right have circle events - these won't be valid once leaf node has been removed
 	if leftLeafNode.circleEvent != nil {
-		heap.Remove(eventQueue, leftLeafNode.circleEvent.index)
+		leftLeafNode.circleEvent.falseAlarm = true
 	}
 	if rightLeafNode.circleEvent != nil {
-		heap.Remove(eventQueue, rightLeafNode.circleEvent.index)
+		rightLeafNode.circleEvent.falseAlarm = true
 	}
 
 	// Get the ancestors of the leafnode required
@@ -161,7 +257,7 @@ func (rbtree *redblacktree) removeArc(leafNode *node, eventQueue *PriorityQueue,
 	grandparent := parentNode.parent
 
 	// Check if leaf node is left or right child and modify the internal node accordingly
-	alteredInternalNode := &node{}
+	var alteredInternalNode *node
 	isLeafLeftChild := parentNode.left == leafNode
 	if isLeafLeftChild == true {
 		// Leaf node is a left child
@@ -196,7 +292,7 @@ func (rbtree *redblacktree) removeArc(leafNode *node, eventQueue *PriorityQueue,
 	siblingNode.parent = grandparent
 
 	// Assign which halfedge is inbound from left and which is from right
-	leftHalfEdge, rightHalfEdge := &halfEdge{}, &halfEdge{}
+	var leftHalfEdge, rightHalfEdge *halfEdge
 	if isLeafLeftChild == true {
 		leftHalfEdge = alteredInternalNode.halfEdge
 		rightHalfEdge = parentNode.halfEdge
@@ -205,11 +301,12 @@ func (rbtree *redblacktree) removeArc(leafNode *node, eventQueue *PriorityQueue,
 		rightHalfEdge = alteredInternalNode.halfEdge
 	}
 
-	// Create new halfedge pair
-	newHalfEdge := dcel.addIsolatedEdge()
+	// Create new halfedge pair - the twin is traced out by the altered breakpoint
+	newHalfEdge := dcel.addIsolatedEdge(alteredInternalNode.breakpoint.leftSite,
+		alteredInternalNode.breakpoint.rightSite)
 
 	// Add circle center as a new vertex of the voronoi diagram
-	voronoiVertex := dcel.addIsolatedVertex(circleCenter.x, circleCenter.y)
+	voronoiVertex := dcel.addIsolatedVertex(circle.center.x, circle.center.y)
 
 	// Connect halfedges to the vertex
 	leftHalfEdge.originVertex = voronoiVertex
@@ -229,8 +326,12 @@ func (rbtree *redblacktree) removeArc(leafNode *node, eventQueue *PriorityQueue,
 	alteredInternalNode.halfEdge = newHalfEdge.twinEdge
 
 	// Check for new circle events now that the leaf has been removed from the beachline
-	leftLeafNode.circleEvent = checkCircleEvent(leftLeafNode, sweepline, eventQueue)
-	rightLeafNode.circleEvent = checkCircleEvent(rightLeafNode, sweepline, eventQueue)
+	var err error
+	if leftLeafNode.circleEvent, err = checkCircleEvent(leftLeafNode, circle.y, eventQueue); err != nil {
+		return err
+	}
+	rightLeafNode.circleEvent, err = checkCircleEvent(rightLeafNode, circle.y, eventQueue)
+	return err
 }
 
 // Assumption - due to the nature of the algorithm a node will always have a sibling unless root node
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// benchmarkBox - the bounding box every benchmark input is generated within
+var benchmarkBox = boundingBox{height: 1000, width: 1000}
+
+// siteDistribution - a named way of generating benchmark input
+type siteDistribution struct {
+	name     string
+	generate func(n int, source *rand.Rand) []site
+}
+
+var siteDistributions = []siteDistribution{
+	{"uniform", uniformSites},
+	{"clustered", clusteredSites},
+	{"sorted", sortedSites},
+	{"grid", gridSites},
+}
+
+func findDistribution(name string) (siteDistribution, bool) {
+	for _, distribution := range siteDistributions {
+		if distribution.name == name {
+			return distribution, true
+		}
+	}
+	return siteDistribution{}, false
+}
+
+// uniformSites - sites spread uniformly over the box
+func uniformSites(n int, source *rand.Rand) []site {
+	siteList := make([]site, n)
+	for i := range siteList {
+		siteList[i] = site{x: source.Float64() * benchmarkBox.width, y: source.Float64() * benchmarkBox.height}
+	}
+	return siteList
+}
+
+// clusteredSites - sites in normally distributed clusters around a few random centres
+func clusteredSites(n int, source *rand.Rand) []site {
+	centres := uniformSites(1+int(math.Sqrt(float64(n))/4), source)
+	spread := benchmarkBox.width / 50
+	siteList := make([]site, 0, n)
+	for len(siteList) < n {
+		centre := centres[source.Intn(len(centres))]
+		x := centre.x + source.NormFloat64()*spread
+		y := centre.y + source.NormFloat64()*spread
+		if x >= 0 && x <= benchmarkBox.width && y >= 0 && y <= benchmarkBox.height {
+			siteList = append(siteList, site{x: x, y: y})
+		}
+	}
+	return siteList
+}
+
+// sortedSites - uniform sites given in the order the sweep visits them (top down)
+func sortedSites(n int, source *rand.Rand) []site {
+	siteList := uniformSites(n, source)
+	sort.Slice(siteList, func(i, j int) bool {
+		return siteBefore(&siteList[i], &siteList[j])
+	})
+	return siteList
+}
+
+// gridSites - sites on a square lattice, so every cell is a square and each vertex is shared by four cells
+func gridSites(n int, source *rand.Rand) []site {
+	columns := int(math.Ceil(math.Sqrt(float64(n))))
+	spacing := benchmarkBox.width / float64(columns)
+	siteList := make([]site, n)
+	for i := range siteList {
+		siteList[i] = site{x: (float64(i%columns) + 0.5) * spacing, y: (float64(i/columns) + 0.5) * spacing}
+	}
+	return siteList
+}
+
+// stageTimes - how long each stage of computing and drawing a diagram took
+type stageTimes struct {
+	sweep, clip, render time.Duration
+}
+
+func (times stageTimes) total() time.Duration {
+	return times.sweep + times.clip + times.render
+}
+
+// timeStages computes and draws the diagram of the sites, timing the sweep, the clipping to the bounding box
+// (including validation) and the rendering separately
+func timeStages(builder *Builder, siteList []site) (stageTimes, error) {
+	var times stageTimes
+	builder.Reset()
+	mergedSites, _, err := builder.merger.merge(siteList, 0, KeepFirstDuplicate)
+	if err != nil {
+		return times, err
+	}
+
+	start := time.Now()
+	dcel, err := builder.sweep(nil, mergedSites)
+	if err != nil {
+		return times, err
+	}
+	times.sweep = time.Since(start)
+
+	start = time.Now()
+	connectEdgesToBoundary(benchmarkBox, dcel)
+	if err := dcel.validate(); err != nil {
+		return times, err
+	}
+	times.clip = time.Since(start)
+
+	start = time.Now()
+	renderVoronoi(benchmarkBox, dcel, mergedSites)
+	times.render = time.Since(start)
+	return times, nil
+}
+
+// runBench - the bench subcommand. It times every distribution at each size, prints a table of the fastest
+// of the runs, and writes cpu and heap profiles covering all of them.
+func runBench(args []string, output io.Writer) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	sizeList := flags.String("sizes", "100,1000,10000,100000", "comma separated numbers of sites")
+	distributionList := flags.String("distributions", "uniform,clustered,sorted,grid",
+		"comma separated input distributions")
+	runs := flags.Int("runs", 3, "times to compute each diagram - the fastest is reported")
+	seed := flags.Int64("seed", 1, "random seed for generating the sites")
+	cpuProfile := flags.String("cpuprofile", "cpu.pprof", "write a cpu profile to this file (empty to skip)")
+	memProfile := flags.String("memprofile", "mem.pprof", "write a heap profile to this file (empty to skip)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *runs < 1 {
+		return fmt.Errorf("runs must be at least 1")
+	}
+
+	var sizes []int
+	for _, field := range strings.Split(*sizeList, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || size < 2 {
+			return fmt.Errorf("invalid size %q: need at least 2 sites", field)
+		}
+		sizes = append(sizes, size)
+	}
+	var distributions []siteDistribution
+	for _, field := range strings.Split(*distributionList, ",") {
+		distribution, ok := findDistribution(strings.TrimSpace(field))
+		if !ok {
+			return fmt.Errorf("unknown distribution %q", field)
+		}
+		distributions = append(distributions, distribution)
+	}
+
+	if *cpuProfile != "" {
+		file, err := os.Create(*cpuProfile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := pprof.StartCPUProfile(file); err != nil {
+			return err
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	table := tabwriter.NewWriter(output, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "distribution\tsites\tsweep\tclip\trender\ttotal\tsweep/site\t")
+	var builder Builder
+	for _, distribution := range distributions {
+		for _, size := range sizes {
+			siteList := distribution.generate(size, rand.New(rand.NewSource(*seed)))
+			var fastest stageTimes
+			for run := 0; run < *runs; run++ {
+				times, err := timeStages(&builder, siteList)
+				if err != nil {
+					return fmt.Errorf("%s %d: %w", distribution.name, size, err)
+				}
+				if run == 0 || times.total() < fastest.total() {
+					fastest = times
+				}
+			}
+			fmt.Fprintf(table, "%s\t%d\t%v\t%v\t%v\t%v\t%v\t\n", distribution.name, size,
+				fastest.sweep.Round(time.Microsecond), fastest.clip.Round(time.Microsecond),
+				fastest.render.Round(time.Microsecond), fastest.total().Round(time.Microsecond),
+				fastest.sweep/time.Duration(size))
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	if *memProfile != "" {
+		file, err := os.Create(*memProfile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var benchmarkSizes = []int{1e2, 1e3, 1e4, 1e5, 1e6}
+
+// benchmarkInputs runs benchmark for every distribution at every size, named like "uniform/1000"
+func benchmarkInputs(b *testing.B, benchmark func(b *testing.B, siteList []site)) {
+	for _, distribution := range siteDistributions {
+		for _, size := range benchmarkSizes {
+			siteList := distribution.generate(size, rand.New(rand.NewSource(1)))
+			b.Run(fmt.Sprintf("%s/%d", distribution.name, size), func(b *testing.B) {
+				benchmark(b, siteList)
+			})
+		}
+	}
+}
+
+func BenchmarkSweep(b *testing.B) {
+	benchmarkInputs(b, func(b *testing.B, siteList []site) {
+		var builder Builder
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			builder.Reset()
+			if _, err := builder.sweep(nil, siteList); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkClip(b *testing.B) {
+	benchmarkInputs(b, func(b *testing.B, siteList []site) {
+		var builder Builder
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			builder.Reset()
+			dcel, err := builder.sweep(nil, siteList)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+			connectEdgesToBoundary(benchmarkBox, dcel)
+			if err := dcel.validate(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRender(b *testing.B) {
+	benchmarkInputs(b, func(b *testing.B, siteList []site) {
+		diagram, err := Compute(siteList, benchmarkBox, Options{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			renderVoronoi(benchmarkBox, diagram.dcel, diagram.sites)
+		}
+	})
+}
+
+func TestSiteDistributions(t *testing.T) {
+	for _, distribution := range siteDistributions {
+		siteList := distribution.generate(500, rand.New(rand.NewSource(1)))
+		if len(siteList) != 500 {
+			t.Fatalf("%s: generated %d sites, want 500", distribution.name, len(siteList))
+		}
+		diagram, err := Compute(siteList, benchmarkBox, Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", distribution.name, err)
+		}
+		checkEdgesAreBisectors(t, diagram.dcel, diagram.Sites())
+	}
+}
+
+func TestRunBench(t *testing.T) {
+	directory := t.TempDir()
+	cpuProfile, memProfile := filepath.Join(directory, "cpu.pprof"), filepath.Join(directory, "mem.pprof")
+	var output strings.Builder
+	err := runBench([]string{"-sizes", "10,100", "-distributions", "uniform,grid", "-runs", "1",
+		"-cpuprofile", cpuProfile, "-memprofile", memProfile}, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(output.String()), "\n"); len(lines) != 5 {
+		t.Errorf("got table\n%s\nwant a header and 4 rows", output.String())
+	}
+	for _, profile := range []string{cpuProfile, memProfile} {
+		if info, err := os.Stat(profile); err != nil || info.Size() == 0 {
+			t.Errorf("profile %s not written: %v", profile, err)
+		}
+	}
+
+	if err := runBench([]string{"-distributions", "spiral"}, io.Discard); err == nil {
+		t.Error("unknown distribution accepted")
+	}
+}
@@ -4,89 +4,72 @@ import (
 	"math"
 )
 
-func connectEdgesToBoundary(currentNode *node, boundingBox boundingBox, dcel *doublyConnectedEdgeList) {
-	// Each internal node left in the beachline represents a half infinite edge
-	// Need to connect each of these to the bounding box
-	if currentNode.breakpoint != nil {
-		// Steps:
-		// 1. Find the midpoint between the left and right site in breakpoint
-		// 2. Use this point and the nodes half edge twin vertex point to determine the equation of the line
-		// 3. Calculate where the line intercepts the bounding box (there will be two points)
-		// 4. Determine which of the points is the closest and set the nodes half edge vertex as this point
-
-		vertex := currentNode.halfEdge.twinEdge.originVertex
-
-		// Initialise boundary vertices
-		vertexBoundingA := getVertex()
-		vertexBoundingA.x = -1.0
-		vertexBoundingB := getVertex()
-
-		// Consider ignoring if vertex lies outside bounding box - maybe add dummy vertex
-		if vertex.x < 0 || vertex.x > boundingBox.width || vertex.y < 0 || vertex.y > boundingBox.height {
-			fakeVertex := dcel.addIsolatedVertex(vertex.x, vertex.y)
-			currentNode.halfEdge.originVertex = fakeVertex
-		} else {
-
-			xMidpoint := (currentNode.breakpoint.leftSite.x + currentNode.breakpoint.rightSite.x) / 2
-			yMidpoint := (currentNode.breakpoint.leftSite.y + currentNode.breakpoint.rightSite.y) / 2
-
-			gradient := (yMidpoint - vertex.y) / (xMidpoint - vertex.x)
-			b := yMidpoint - (gradient * xMidpoint)
-			bottomBoundInterceptX := (-1.0 * b) / gradient
-			rightBoundInterceptY := (gradient * boundingBox.width) + b
-			topBoundInterceptX := (boundingBox.height - b) / gradient
+// connectEdgesToBoundary terminates every half infinite edge left when the sweep finishes. Each of these
+// is a half-edge whose origin was never set by a circle event, so its origin is placed where the edge
+// leaves the bounding box.
+func connectEdgesToBoundary(boundingBox boundingBox, dcel *doublyConnectedEdgeList) {
+	for _, halfEdge := range dcel.edges {
+		if halfEdge.originVertex == nil {
+			connectEdgeToBoundary(boundingBox, dcel, halfEdge)
+		}
+	}
+}
 
-			// TODO - handle case of parallel line
+// connectEdgeToBoundary gives a half-edge without an origin one where its edge leaves the bounding box
+func connectEdgeToBoundary(boundingBox boundingBox, dcel *doublyConnectedEdgeList, halfEdge *halfEdge) {
+	// Steps:
+	// 1. Anchor the line of the edge at the midpoint between the two sites, which always lies on it. This
+	//    is exact, unlike a voronoi vertex which may be far away when the sites are nearly collinear.
+	// 2. Walk back along the line away from the other end of the edge (if it has one)
+	// 3. Stop where the line leaves the bounding box - if it leaves before reaching the other end then
+	//    the edge lies entirely outside the box, so the half-edge is given a zero length
 
-			if b >= 0 && b <= boundingBox.height {
-				vertexBoundingA.x = 0
-				vertexBoundingA.y = b
-			}
-			if bottomBoundInterceptX >= 0 && bottomBoundInterceptX <= boundingBox.width {
-				vertexBoundingB.x = bottomBoundInterceptX
-				vertexBoundingB.y = 0
-			}
-			if rightBoundInterceptY >= 0 && rightBoundInterceptY <= boundingBox.height {
-				if vertexBoundingA.x < 0 {
-					vertexBoundingA.x = boundingBox.width
-					vertexBoundingA.y = rightBoundInterceptY
-				} else {
-					vertexBoundingB.x = boundingBox.width
-					vertexBoundingB.y = rightBoundInterceptY
-				}
-			}
-			if topBoundInterceptX >= 0 && topBoundInterceptX <= boundingBox.width {
-				if vertexBoundingA.x < 0 {
-					vertexBoundingA.x = topBoundInterceptX
-					vertexBoundingA.y = boundingBox.height
-				} else {
-					vertexBoundingB.x = topBoundInterceptX
-					vertexBoundingB.y = boundingBox.height
-				}
-			}
+	// The half-edge has its site on its right, so it travels in the direction of the vector from the twin's
+	// site to its own site rotated 90 degrees anticlockwise. Its origin lies back the opposite way.
+	xMidpoint := (halfEdge.site.x + halfEdge.twinEdge.site.x) / 2
+	yMidpoint := (halfEdge.site.y + halfEdge.twinEdge.site.y) / 2
+	dx := halfEdge.site.y - halfEdge.twinEdge.site.y
+	dy := halfEdge.twinEdge.site.x - halfEdge.site.x
 
-			// Distance to A from vertex and distance to A from midpoint
-			distanceVertexToA := math.Sqrt(math.Pow((vertex.x-vertexBoundingA.x), 2) + math.Pow((vertex.y-vertexBoundingA.y), 2))
-			distanceMidpointToA := math.Sqrt(math.Pow((xMidpoint-vertexBoundingA.x), 2) +
-				math.Pow((yMidpoint-vertexBoundingA.y), 2))
+	// Position of the other end of the edge along the line
+	vertex := halfEdge.twinEdge.originVertex
+	tVertex := math.Inf(-1)
+	if vertex != nil {
+		tVertex = ((vertex.x-xMidpoint)*dx + (vertex.y-yMidpoint)*dy) / (dx*dx + dy*dy)
+	}
 
-			// Add the boundary vertex which is closer to midpoint than node vertex to the dcel
-			// and connect the halfedge on the node to it
-			newVertex := getVertexPointer()
-			if distanceMidpointToA < distanceVertexToA {
-				newVertex = dcel.addIsolatedVertex(vertexBoundingA.x, vertexBoundingA.y)
-			} else {
-				newVertex = dcel.addIsolatedVertex(vertexBoundingB.x, vertexBoundingB.y)
-			}
-			currentNode.halfEdge.originVertex = newVertex
-		}
+	x, y := xMidpoint, yMidpoint
+	if _, tExit, ok := boundingBox.clipLine(xMidpoint, yMidpoint, dx, dy); ok && tExit > tVertex {
+		x += tExit * dx
+		y += tExit * dy
+	} else if vertex != nil {
+		x, y = vertex.x, vertex.y
+	}
+	halfEdge.originVertex = dcel.addIsolatedVertex(x, y)
+}
 
-		// Run on child nodes
-		if currentNode.left != nil {
-			connectEdgesToBoundary(currentNode.left, boundingBox, dcel)
+// clipLine returns the range of t for which the line (x + t*dx, y + t*dy) lies inside the bounding box
+// (Liang-Barsky). ok is false if the line misses the box.
+func (boundingBox boundingBox) clipLine(x, y, dx, dy float64) (tEnter, tExit float64, ok bool) {
+	tEnter, tExit = math.Inf(-1), math.Inf(1)
+	clip := func(p, q float64) bool {
+		if p == 0 {
+			// Line is parallel to this side - it is either always inside or always outside it
+			return q >= 0
 		}
-		if currentNode.right != nil {
-			connectEdgesToBoundary(currentNode.right, boundingBox, dcel)
+		t := q / p
+		if p < 0 {
+			tEnter = math.Max(tEnter, t)
+		} else {
+			tExit = math.Min(tExit, t)
 		}
+		return tEnter <= tExit
 	}
+	ok = clip(-dx, x) && clip(dx, boundingBox.width-x) && clip(-dy, y) && clip(dy, boundingBox.height-y)
+	return tEnter, tExit, ok && !math.IsInf(tEnter, 0) && !math.IsInf(tExit, 0)
+}
+
+// clamp returns the point of the bounding box closest to p
+func (boundingBox boundingBox) clamp(p vertex) vertex {
+	return vertex{x: math.Min(math.Max(p.x, 0), boundingBox.width), y: math.Min(math.Max(p.y, 0), boundingBox.height)}
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Builder - computes diagrams one after another, allocating the beachline, edges, vertices and events
+// from slabs which are kept and reused after Reset rather than garbage collected. Diagrams share the
+// memory of the builder that computed them so they must not be used after it is reset. The zero value is
+// ready to use. A Builder is not safe for concurrent use.
+type Builder struct {
+	arena  arena
+	merger siteMerger
+	queue  *eventQueue
+}
+
+// Compute generates the voronoi diagram of the sites within the bounding box, as the package level Compute
+// does. The diagram stays valid until the next call to Reset.
+func (builder *Builder) Compute(siteList []site, boundingBox boundingBox, options Options) (*Diagram, error) {
+	return builder.ComputeContext(context.Background(), siteList, boundingBox, options)
+}
+
+// ComputeContext is Compute with a context, as the package level ComputeContext
+func (builder *Builder) ComputeContext(ctx context.Context, siteList []site, boundingBox boundingBox,
+	options Options) (*Diagram, error) {
+	limits := newSweepLimits(ctx, options.Limits)
+	if err := limits.checkContext(); err != nil {
+		return nil, err
+	}
+	if err := limits.checkSites(siteList); err != nil {
+		return nil, err
+	}
+	if err := validateInput(siteList, boundingBox); err != nil {
+		return nil, err
+	}
+	if err := validateWeights(siteList, options); err != nil {
+		return nil, err
+	}
+	if err := validateAnisotropy(options); err != nil {
+		return nil, err
+	}
+	if options.Periodic {
+		siteList = wrapSites(siteList, boundingBox)
+	}
+
+	mergedSites, siteIDs, err := builder.merger.merge(siteList, options.DuplicateTolerance,
+		options.DuplicatePolicy)
+	if err != nil {
+		return nil, err
+	}
+	if len(mergedSites) == 1 {
+		reason := "is the only site so there are no edges"
+		if len(siteList) > 1 {
+			reason = "is the only site left after merging duplicates so there are no edges"
+		}
+		return nil, &InputError{Index: 0, Site: siteList[0], Reason: reason, Err: ErrDegenerateInput}
+	}
+
+	diagram := &Diagram{sites: mergedSites, siteIDs: siteIDs, boundingBox: boundingBox, metric: options.Metric,
+		weighting: options.Weighting}
+	if options.Weighting != Unweighted {
+		diagram.weights = mergedWeights(options.Weights, siteIDs, len(mergedSites))
+		diagram.curveTolerance = options.CurveTolerance
+		if diagram.curveTolerance == 0 {
+			diagram.curveTolerance = 1e-3 * math.Max(boundingBox.width, boundingBox.height)
+		}
+	}
+	if options.Anisotropy != ([2][2]float64{}) {
+		diagram.anisotropy, _ = newAnisotropy(options.Anisotropy)
+	}
+	if options.Periodic {
+		err = builder.computePeriodic(limits, diagram, options)
+	} else if diagram.anisotropy != nil {
+		err = builder.computeAnisotropic(limits, diagram, options)
+	} else {
+		err = builder.computeDCEL(limits, diagram, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := limits.checkDCEL(diagram.dcel); err != nil {
+		return nil, err
+	}
+	if err := diagram.dcel.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNumericalFailure, err)
+	}
+	return diagram, nil
+}
+
+// computeDCEL finds the edges of the diagram of its sites under its metric and weighting
+func (builder *Builder) computeDCEL(limits *sweepLimits, diagram *Diagram, options Options) error {
+	var err error
+	if diagram.weighting != Unweighted {
+		if diagram.dcel, diagram.fixedCells, diagram.curves, err = weightedDiagram(limits, diagram); err != nil {
+			return err
+		}
+		// The empty circles are those of the unweighted diagram
+		diagram.unweighted = &Diagram{sites: diagram.sites, siteIDs: diagram.siteIDs,
+			boundingBox: diagram.boundingBox, metric: Euclidean}
+		return builder.computeDCEL(limits, diagram.unweighted, options)
+	}
+	switch diagram.metric {
+	case Euclidean:
+		if diagram.dcel, err = builder.parallelSweep(limits, diagram.sites, options.Parallelism); err != nil {
+			return err
+		}
+		// Add bounding box and connect half infinite edges to it
+		connectEdgesToBoundary(diagram.boundingBox, diagram.dcel)
+	case Manhattan, Chebyshev:
+		if diagram.dcel, diagram.fixedCells, err = metricDiagram(limits, diagram.sites, diagram.boundingBox,
+			diagram.metric); err != nil {
+			return err
+		}
+	default:
+		return &InputError{Index: -1, Reason: fmt.Sprintf("unknown metric %d", diagram.metric), Err: ErrInvalidInput}
+	}
+	return nil
+}
+
+// sweep runs fortunesAlgorithm over the sites using the builder's event queue and arena
+func (builder *Builder) sweep(limits *sweepLimits, siteList []site) (*doublyConnectedEdgeList, error) {
+	if builder.queue == nil {
+		builder.queue = newEventQueue(siteList)
+		builder.queue.arena = &builder.arena
+	} else {
+		builder.queue.reset(siteList)
+	}
+	return fortunesAlgorithm(builder.queue, limits)
+}
+
+// Reset makes all of the memory used by the diagrams computed so far available to the next, invalidating them
+func (builder *Builder) Reset() {
+	builder.arena.reset()
+	if builder.queue != nil {
+		builder.queue.reset(nil)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestBuilderReuse(t *testing.T) {
+	source := rand.New(rand.NewSource(3))
+	box := boundingBox{height: 1000, width: 1000}
+	var builder Builder
+	for run := 0; run < 20; run++ {
+		siteList := make([]site, 1+source.Intn(200))
+		for i := range siteList {
+			siteList[i] = site{x: float64(source.Intn(1000)), y: float64(source.Intn(1000))}
+		}
+		options := Options{DuplicatePolicy: KeepFirstDuplicate}
+		if run%5 == 4 {
+			builder.Reset()
+		}
+		diagram, err := builder.Compute(siteList, box, options)
+		want, wantErr := Compute(siteList, box, options)
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("run %d: builder returned %v, Compute returned %v", run, err, wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if len(diagram.dcel.edges) != len(want.dcel.edges) {
+			t.Fatalf("run %d: builder made %d half-edges, Compute made %d", run, len(diagram.dcel.edges),
+				len(want.dcel.edges))
+		}
+		for i, halfEdge := range diagram.dcel.edges {
+			if *halfEdge.originVertex != *want.dcel.edges[i].originVertex || *halfEdge.site != *want.dcel.edges[i].site {
+				t.Fatalf("run %d: half-edge %d differs", run, i)
+			}
+		}
+		checkEdgesAreBisectors(t, diagram.dcel, diagram.Sites())
+	}
+}
+
+// reportAllocsPerSite runs compute b.N times and reports the heap allocations it makes per site
+func reportAllocsPerSite(b *testing.B, sites int, compute func()) {
+	b.ReportAllocs()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compute()
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(b.N*sites), "allocs/site")
+}
+
+func BenchmarkBuilder(b *testing.B) {
+	siteList := uniformSites(10000, rand.New(rand.NewSource(1)))
+
+	b.Run("heap", func(b *testing.B) {
+		// Every value allocated on its own, as the sweep did before it had an arena
+		reportAllocsPerSite(b, len(siteList), func() {
+			dcel, err := fortunesAlgorithm(newEventQueue(siteList), nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			connectEdgesToBoundary(benchmarkBox, dcel)
+		})
+	})
+
+	b.Run("compute", func(b *testing.B) {
+		reportAllocsPerSite(b, len(siteList), func() {
+			if _, err := Compute(siteList, benchmarkBox, Options{}); err != nil {
+				b.Fatal(err)
+			}
+		})
+	})
+
+	b.Run("builder", func(b *testing.B) {
+		var builder Builder
+		reportAllocsPerSite(b, len(siteList), func() {
+			builder.Reset()
+			if _, err := builder.Compute(siteList, benchmarkBox, Options{}); err != nil {
+				b.Fatal(err)
+			}
+		})
+	})
+}
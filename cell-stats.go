@@ -0,0 +1,205 @@
+package main
+
+import (
+	"math"
+)
+
+// CellStats - measurements of a cell of a diagram, clipped to the bounding box
+type CellStats struct {
+	Area, Perimeter float64
+	Centroid        vertex
+	Min, Max        vertex  // Corners of the smallest box around the cell
+	InscribedRadius float64 // Radius of the largest circle inside the lines of its sides (inside it if convex)
+	TouchesBoundary bool
+	Neighbours      []CellNeighbour // Anticlockwise around the cell
+}
+
+// CellNeighbour - a cell sharing an edge with another, and the length of that edge inside the bounding box
+type CellNeighbour struct {
+	ID           SiteID
+	SharedLength float64
+}
+
+// cellCorner - a corner of a cell polygon, and what lies across the side leaving it anticlockwise: the site
+// of the neighbouring cell, or -1 for the bounding box
+type cellCorner struct {
+	vertex
+	across SiteID
+}
+
+// CellStats returns the measurements of every cell, indexed by SiteID. A site outside the bounding box may
+// have an empty cell, measured as all zeros. When the box wraps around each cell is measured whole, running
+// over the sides of the box, so its centroid may be outside it.
+func (diagram *Diagram) CellStats() []CellStats {
+	polygons := diagram.cellPolygons()
+	minimumLength := 1e-9 * math.Max(diagram.boundingBox.width, diagram.boundingBox.height)
+	stats := make([]CellStats, len(polygons))
+	for id, polygon := range polygons {
+		if len(polygon) < 3 {
+			continue
+		}
+		cell := &stats[id]
+		cell.Min, cell.Max = polygon[0].vertex, polygon[0].vertex
+		var twiceArea, xSum, ySum float64
+		for k, corner := range polygon {
+			next := polygon[(k+1)%len(polygon)]
+			cross := corner.x*next.y - next.x*corner.y
+			twiceArea += cross
+			xSum += (corner.x + next.x) * cross
+			ySum += (corner.y + next.y) * cross
+			if corner.across == SiteID(id) {
+				// Joins the rings of a weighted cell of several, going there and back
+				continue
+			}
+
+			length := math.Hypot(next.x-corner.x, next.y-corner.y)
+			cell.Perimeter += length
+			if length > minimumLength {
+				if corner.across < 0 {
+					cell.TouchesBoundary = true
+				} else if n := len(cell.Neighbours); n > 0 && cell.Neighbours[n-1].ID == corner.across {
+					// Under the Manhattan and Chebyshev metrics a border can bend
+					cell.Neighbours[n-1].SharedLength += length
+				} else {
+					cell.Neighbours = append(cell.Neighbours, CellNeighbour{ID: corner.across, SharedLength: length})
+				}
+			}
+			cell.Min = vertex{x: math.Min(cell.Min.x, corner.x), y: math.Min(cell.Min.y, corner.y)}
+			cell.Max = vertex{x: math.Max(cell.Max.x, corner.x), y: math.Max(cell.Max.y, corner.y)}
+		}
+		if n := len(cell.Neighbours); n > 1 && cell.Neighbours[0].ID == cell.Neighbours[n-1].ID {
+			cell.Neighbours[0].SharedLength += cell.Neighbours[n-1].SharedLength
+			cell.Neighbours = cell.Neighbours[:n-1]
+		}
+		cell.Area = twiceArea / 2
+		if twiceArea > 0 {
+			cell.Centroid = vertex{x: xSum / (3 * twiceArea), y: ySum / (3 * twiceArea)}
+		}
+		cell.InscribedRadius = inscribedRadius(polygon, SiteID(id))
+	}
+	return stats
+}
+
+// cellPolygons returns the cell of every site as an anticlockwise polygon, indexed by SiteID. Each cell is
+// the bounding box cut down by the bisector between its site and each of its neighbours in the dcel, unless
+// the diagram has another metric, is weighted or is periodic, when the cells were kept from computing it.
+func (diagram *Diagram) cellPolygons() [][]cellCorner {
+	if diagram.fixedCells != nil {
+		return diagram.fixedCells
+	}
+	neighbours := diagram.dcelNeighbours()
+	width, height := diagram.boundingBox.width, diagram.boundingBox.height
+	polygons := make([][]cellCorner, len(diagram.sites))
+	for id := range diagram.sites {
+		polygon := []cellCorner{{vertex{0, 0}, -1}, {vertex{width, 0}, -1}, {vertex{width, height}, -1},
+			{vertex{0, height}, -1}}
+		for _, neighbour := range neighbours[id] {
+			polygon = clipToBisector(polygon, &diagram.sites[id], &diagram.sites[neighbour], neighbour)
+		}
+		polygons[id] = polygon
+	}
+	return polygons
+}
+
+// dcelNeighbours returns the sites sharing an edge of the dcel with each site, indexed by SiteID - its
+// neighbours in the delaunay triangulation
+func (diagram *Diagram) dcelNeighbours() [][]SiteID {
+	ids := make(map[*site]SiteID, len(diagram.sites))
+	for i := range diagram.sites {
+		ids[&diagram.sites[i]] = SiteID(i)
+	}
+	neighbours := make([][]SiteID, len(diagram.sites))
+	for _, halfEdge := range diagram.dcel.edges {
+		id := ids[halfEdge.site]
+		neighbours[id] = append(neighbours[id], ids[halfEdge.twinEdge.site])
+	}
+	return neighbours
+}
+
+// clipToBisector keeps the part of a convex polygon closer to s than to the neighbouring site, the new
+// side along the bisector having the neighbour across it (Sutherland-Hodgman)
+func clipToBisector(polygon []cellCorner, s, neighbour *site, id SiteID) []cellCorner {
+	// Positive on the neighbour's side of the bisector
+	dx, dy := neighbour.x-s.x, neighbour.y-s.y
+	xMidpoint, yMidpoint := (s.x+neighbour.x)/2, (s.y+neighbour.y)/2
+	side := func(p vertex) float64 {
+		return (p.x-xMidpoint)*dx + (p.y-yMidpoint)*dy
+	}
+
+	clipped := make([]cellCorner, 0, len(polygon)+1)
+	for k, corner := range polygon {
+		next := polygon[(k+1)%len(polygon)]
+		cornerSide, nextSide := side(corner.vertex), side(next.vertex)
+		if cornerSide <= 0 {
+			clipped = append(clipped, corner)
+		}
+		if (cornerSide <= 0) != (nextSide <= 0) {
+			t := cornerSide / (cornerSide - nextSide)
+			crossing := vertex{x: corner.x + t*(next.x-corner.x), y: corner.y + t*(next.y-corner.y)}
+			if cornerSide <= 0 {
+				clipped = append(clipped, cellCorner{crossing, id})
+			} else {
+				clipped = append(clipped, cellCorner{crossing, corner.across})
+			}
+		}
+	}
+	return clipped
+}
+
+// inscribedRadius finds the largest circle inside a convex polygon. Its centre and radius are the best
+// solution of the linear program keeping the centre at least the radius inside every side, which lies
+// where the circle touches three sides, so each three sides are tried in turn. Sides joining the rings of a
+// weighted cell, which have its own site across them, are not sides of the cell.
+func inscribedRadius(polygon []cellCorner, id SiteID) float64 {
+	// Each side as a unit normal pointing into the polygon and an offset: distance inside = a x + b y - c
+	type line struct{ a, b, c float64 }
+	var lines []line
+	for k, corner := range polygon {
+		next := polygon[(k+1)%len(polygon)]
+		length := math.Hypot(next.x-corner.x, next.y-corner.y)
+		if length == 0 || corner.across == id {
+			continue
+		}
+		a, b := -(next.y-corner.y)/length, (next.x-corner.x)/length
+		lines = append(lines, line{a, b, a*corner.x + b*corner.y})
+	}
+
+	best := 0.0
+	for i := 0; i < len(lines); i++ {
+		for j := i + 1; j < len(lines); j++ {
+			for k := j + 1; k < len(lines); k++ {
+				// Solve a x + b y - r = c for the three sides with Cramer's rule
+				as := [3]float64{lines[i].a, lines[j].a, lines[k].a}
+				bs := [3]float64{lines[i].b, lines[j].b, lines[k].b}
+				cs := [3]float64{lines[i].c, lines[j].c, lines[k].c}
+				ones := [3]float64{-1, -1, -1}
+				determinant := determinant3(as, bs, ones)
+				if math.Abs(determinant) < 1e-12 {
+					continue
+				}
+				x := determinant3(cs, bs, ones) / determinant
+				y := determinant3(as, cs, ones) / determinant
+				r := determinant3(as, bs, cs) / determinant
+				if r <= best {
+					continue
+				}
+				inside := true
+				for _, l := range lines {
+					if l.a*x+l.b*y-l.c < r*(1-1e-9) {
+						inside = false
+						break
+					}
+				}
+				if inside {
+					best = r
+				}
+			}
+		}
+	}
+	return best
+}
+
+// determinant3 - the determinant of the 3x3 matrix with the given columns
+func determinant3(a, b, c [3]float64) float64 {
+	return a[0]*(b[1]*c[2]-b[2]*c[1]) - b[0]*(a[1]*c[2]-a[2]*c[1]) + c[0]*(a[1]*b[2]-a[2]*b[1])
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCellStatsGrid(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	diagram, err := Compute([]site{{x: 25, y: 25}, {x: 75, y: 25}, {x: 25, y: 75}, {x: 75, y: 75}}, box, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := diagram.CellStats()
+	for id, cell := range stats {
+		s := diagram.sites[id]
+		want := vertex{x: math.Floor(s.x/50) * 50, y: math.Floor(s.y/50) * 50}
+		if !nearlyEqual(cell.Area, 2500) || !nearlyEqual(cell.Perimeter, 200) ||
+			!nearlyEqual(cell.InscribedRadius, 25) || !cell.TouchesBoundary ||
+			!nearlyEqual(cell.Centroid.x, s.x) || !nearlyEqual(cell.Centroid.y, s.y) || cell.Min != want ||
+			cell.Max != (vertex{x: want.x + 50, y: want.y + 50}) {
+			t.Errorf("cell %d: got %+v", id, cell)
+		}
+		if len(cell.Neighbours) != 2 {
+			t.Fatalf("cell %d: got neighbours %v, want 2", id, cell.Neighbours)
+		}
+		for _, neighbour := range cell.Neighbours {
+			if !nearlyEqual(neighbour.SharedLength, 50) {
+				t.Errorf("cell %d: shares %v with %d, want 50", id, neighbour.SharedLength, neighbour.ID)
+			}
+		}
+	}
+}
+
+func TestCellStats(t *testing.T) {
+	for _, distribution := range siteDistributions {
+		siteList := distribution.generate(1000, rand.New(rand.NewSource(2)))
+		diagram, err := Compute(siteList, benchmarkBox, Options{DuplicatePolicy: KeepFirstDuplicate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats := diagram.CellStats()
+		shared := map[[2]SiteID]float64{}
+		totalArea := 0.0
+		for id, cell := range stats {
+			s := diagram.sites[id]
+			totalArea += cell.Area
+			if cell.Area <= 0 || cell.InscribedRadius <= 0 {
+				t.Fatalf("%s cell %d: got %+v", distribution.name, id, cell)
+			}
+			// The site is in its own cell, and no closer to any side than the inscribed circle allows
+			if s.x < cell.Min.x || s.x > cell.Max.x || s.y < cell.Min.y || s.y > cell.Max.y {
+				t.Errorf("%s cell %d: site outside %v - %v", distribution.name, id, cell.Min, cell.Max)
+			}
+			if cell.InscribedRadius*math.Pi*cell.InscribedRadius > cell.Area*(1+1e-9) {
+				t.Errorf("%s cell %d: inscribed circle larger than the cell", distribution.name, id)
+			}
+			if diagram.Locate(cell.Centroid) != SiteID(id) {
+				t.Errorf("%s cell %d: centroid %v outside the cell", distribution.name, id, cell.Centroid)
+			}
+			for _, neighbour := range cell.Neighbours {
+				shared[[2]SiteID{SiteID(id), neighbour.ID}] = neighbour.SharedLength
+			}
+		}
+		if !nearlyEqual(totalArea, benchmarkBox.width*benchmarkBox.height) {
+			t.Errorf("%s: cells cover %v, want %v", distribution.name, totalArea,
+				benchmarkBox.width*benchmarkBox.height)
+		}
+		for pair, length := range shared {
+			if other := shared[[2]SiteID{pair[1], pair[0]}]; math.Abs(other-length) > 1e-6 {
+				t.Errorf("%s: cell %d shares %v with %d, which shares %v back", distribution.name, pair[0],
+					length, pair[1], other)
+			}
+		}
+	}
+}
+
+func nearlyEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
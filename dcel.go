@@ -1,39 +1,82 @@
 package main
 
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
 type vertex struct {
 	x, y float64
 }
 
+// The site recorded on a half-edge is the one whose cell lies to its right, so following nextEdge
+// pointers walks around a cell in clockwise order.
 type halfEdge struct {
 	originVertex *vertex
 	twinEdge     *halfEdge
 	nextEdge     *halfEdge
+	site         *site
 }
 
 type doublyConnectedEdgeList struct {
 	vertices []*vertex
 	edges    []*halfEdge
+	arena    *arena // Where vertices, half-edges and the beachline nodes of the sweep are allocated
 }
 
 func (dcel *doublyConnectedEdgeList) addIsolatedVertex(x, y float64) *vertex {
-	newVertex := vertex{x: x, y: y}
-	dcel.vertices = append(dcel.vertices, &newVertex)
-	return &newVertex
+	newVertex := dcel.arena.newVertex()
+	*newVertex = vertex{x: x, y: y}
+	dcel.vertices = append(dcel.vertices, newVertex)
+	return newVertex
 }
 
-func (dcel *doublyConnectedEdgeList) addIsolatedEdge() *halfEdge {
-	initialHalfEdge := halfEdge{originVertex: nil, twinEdge: nil, nextEdge: nil}
-	twinHalfEdge := halfEdge{originVertex: nil, twinEdge: &initialHalfEdge, nextEdge: nil}
-	initialHalfEdge.twinEdge = &twinHalfEdge
-	dcel.edges = append(dcel.edges, &initialHalfEdge)
-	dcel.edges = append(dcel.edges, &twinHalfEdge)
-	return &initialHalfEdge
+// addIsolatedEdge creates a half-edge pair separating two sites. The returned half-edge has site on its
+// right and the twin has twinSite on its right.
+func (dcel *doublyConnectedEdgeList) addIsolatedEdge(site, twinSite *site) *halfEdge {
+	initialHalfEdge, twinHalfEdge := dcel.arena.newHalfEdge(), dcel.arena.newHalfEdge()
+	*initialHalfEdge = halfEdge{originVertex: nil, twinEdge: twinHalfEdge, nextEdge: nil, site: site}
+	*twinHalfEdge = halfEdge{originVertex: nil, twinEdge: initialHalfEdge, nextEdge: nil, site: twinSite}
+	dcel.edges = append(dcel.edges, initialHalfEdge, twinHalfEdge)
+	return initialHalfEdge
 }
 
-func getVertex() vertex {
-	return vertex{}
+// validate checks the structural invariants of a dcel that has been connected to the bounding box.
+// Every half-edge must have a twin pointing back at it, a finite origin vertex and a site on either
+// side, and any next pointer must continue from where the half-edge ends around the same cell.
+func (dcel *doublyConnectedEdgeList) validate() error {
+	for i, halfEdge := range dcel.edges {
+		switch {
+		case halfEdge.twinEdge == nil:
+			return fmt.Errorf("half-edge %d has no twin", i)
+		case halfEdge.twinEdge.twinEdge != halfEdge:
+			return fmt.Errorf("half-edge %d is not the twin of its twin", i)
+		case halfEdge.originVertex == nil:
+			return fmt.Errorf("half-edge %d has no origin vertex", i)
+		case !isFinite(halfEdge.originVertex.x) || !isFinite(halfEdge.originVertex.y):
+			return fmt.Errorf("half-edge %d has non-finite origin (%v, %v)", i, halfEdge.originVertex.x,
+				halfEdge.originVertex.y)
+		case halfEdge.site == nil || halfEdge.twinEdge.site == nil:
+			return fmt.Errorf("half-edge %d does not separate two sites", i)
+		case halfEdge.site == halfEdge.twinEdge.site:
+			return fmt.Errorf("half-edge %d has the same site on both sides", i)
+		}
+		if next := halfEdge.nextEdge; next != nil {
+			if next.originVertex != halfEdge.twinEdge.originVertex {
+				return fmt.Errorf("half-edge %d is not followed by an edge leaving its end vertex", i)
+			}
+			if next.site != halfEdge.site {
+				return fmt.Errorf("half-edge %d is followed by an edge of a different cell", i)
+			}
+		}
+	}
+	if len(dcel.edges)%2 != 0 {
+		return errors.New("dcel has an odd number of half-edges")
+	}
+	return nil
 }
 
-func getVertexPointer() *vertex {
-	return &vertex{}
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
 }
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DynamicDiagram - a voronoi diagram which sites can be inserted into and removed from. It keeps the
+// delaunay triangulation of its sites, which a change only alters close to the site: inserting replaces the
+// triangles whose circles contain the new site (the Bowyer-Watson cavity), removing refills the hole left by
+// the triangles around the site. Only the cells whose neighbours changed have their part of the dcel rebuilt.
+// While the sites are all collinear there are no triangles to repair, so every change recomputes the diagram.
+type DynamicDiagram struct {
+	boundingBox boundingBox
+	graph       delaunayGraph // Indexed by SiteID. Removed sites keep their id but have no neighbours.
+	removed     []bool
+	live        int
+	triangles   int
+	start       int // Where the walk to the site nearest a new one begins
+
+	dcel        *doublyConnectedEdgeList
+	ids         map[*site]SiteID
+	cellEdges   [][]*halfEdge      // The half-edges with each site on their right
+	vertexOf    map[[3]int]*vertex // The vertex of each triangle, keyed by its sites anticlockwise from the lowest
+	triangleOf  map[*vertex][3]int
+	edgeSlots   map[*halfEdge]int // Where each half-edge pair starts in dcel.edges, so it can be removed
+	vertexSlots map[*vertex]int
+}
+
+// NewDynamicDiagram computes the diagram of the sites within the bounding box. Each site's SiteID is its
+// index in the list. Duplicate sites are rejected.
+func NewDynamicDiagram(siteList []site, boundingBox boundingBox) (*DynamicDiagram, error) {
+	if err := validateInput(siteList, boundingBox); err != nil {
+		return nil, err
+	}
+	if _, _, err := mergeDuplicateSites(siteList, 0, RejectDuplicates); err != nil {
+		return nil, err
+	}
+	dynamic := &DynamicDiagram{boundingBox: boundingBox, ids: map[*site]SiteID{}}
+	for _, s := range siteList {
+		dynamic.addSite(s)
+	}
+	if err := dynamic.rebuild(); err != nil {
+		return nil, err
+	}
+	return dynamic, nil
+}
+
+// Site returns the site with the given id, or false if there is no such site or it has been removed
+func (dynamic *DynamicDiagram) Site(id SiteID) (site, bool) {
+	if id < 0 || int(id) >= len(dynamic.graph.sites) || dynamic.removed[id] {
+		return site{}, false
+	}
+	return *dynamic.graph.sites[id], true
+}
+
+// Insert adds a site to the diagram, returning its id and the ids of the cells which changed: the new cell
+// and each of its neighbours
+func (dynamic *DynamicDiagram) Insert(s site) (SiteID, []SiteID, error) {
+	if !isFinite(s.x) || !isFinite(s.y) {
+		return -1, nil, &InputError{Index: -1, Site: s,
+			Reason: fmt.Sprintf("site (%v, %v) has a non-finite coordinate", s.x, s.y), Err: ErrInvalidInput}
+	}
+	nearest := -1
+	if dynamic.triangles > 0 {
+		nearest = dynamic.nearest(s)
+	} else {
+		for i, other := range dynamic.graph.sites {
+			if !dynamic.removed[i] && *other == s {
+				nearest = i
+			}
+		}
+	}
+	if nearest >= 0 && *dynamic.graph.sites[nearest] == s {
+		return -1, nil, &InputError{Index: -1, Site: s,
+			Reason: fmt.Sprintf("site (%v, %v) duplicates site %d", s.x, s.y, nearest), Err: ErrDegenerateInput}
+	}
+
+	id := dynamic.addSite(s)
+	if dynamic.triangles > 0 {
+		if changed, ok := dynamic.insertSite(id, nearest); ok {
+			dynamic.repair(changed)
+			return SiteID(id), siteIDsOf(changed), nil
+		}
+	}
+	if err := dynamic.rebuild(); err != nil {
+		dynamic.removeLastSite()
+		return -1, nil, err
+	}
+	return SiteID(id), dynamic.liveSiteIDs(), nil
+}
+
+// Remove takes a site out of the diagram, returning the ids of the cells which changed: the former
+// neighbours of its cell, which grow to cover it
+func (dynamic *DynamicDiagram) Remove(id SiteID) ([]SiteID, error) {
+	if _, ok := dynamic.Site(id); !ok {
+		return nil, &InputError{Index: -1, Reason: fmt.Sprintf("site %d does not exist", id),
+			Err: ErrInvalidInput}
+	}
+	r := int(id)
+	if dynamic.triangles > 0 && dynamic.live > 3 {
+		if changed, ok := dynamic.removeSite(r); ok {
+			dynamic.repair(append(changed, r))
+			return siteIDsOf(changed), nil
+		}
+	}
+	dynamic.removed[r] = true
+	dynamic.live--
+	if err := dynamic.rebuild(); err != nil {
+		dynamic.removed[r] = false
+		dynamic.live++
+		return nil, err
+	}
+	return dynamic.liveSiteIDs(), nil
+}
+
+func (dynamic *DynamicDiagram) addSite(s site) int {
+	id := len(dynamic.graph.sites)
+	newSite := &site{x: s.x, y: s.y}
+	dynamic.graph.sites = append(dynamic.graph.sites, newSite)
+	dynamic.graph.neighbours = append(dynamic.graph.neighbours, nil)
+	dynamic.removed = append(dynamic.removed, false)
+	dynamic.cellEdges = append(dynamic.cellEdges, nil)
+	dynamic.ids[newSite] = SiteID(id)
+	dynamic.live++
+	return id
+}
+
+// removeLastSite undoes addSite when the diagram could not be rebuilt with the site
+func (dynamic *DynamicDiagram) removeLastSite() {
+	last := len(dynamic.graph.sites) - 1
+	delete(dynamic.ids, dynamic.graph.sites[last])
+	dynamic.graph.sites = dynamic.graph.sites[:last]
+	dynamic.graph.neighbours = dynamic.graph.neighbours[:last]
+	dynamic.removed = dynamic.removed[:last]
+	dynamic.cellEdges = dynamic.cellEdges[:last]
+	dynamic.live--
+}
+
+// rebuild sweeps all of the sites and builds the dcel from scratch. The diagram is unchanged if the sweep
+// fails.
+func (dynamic *DynamicDiagram) rebuild() error {
+	var live []int
+	for i := range dynamic.graph.sites {
+		if !dynamic.removed[i] {
+			live = append(live, i)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool {
+		return siteLeftOf(dynamic.graph.sites[live[i]], dynamic.graph.sites[live[j]])
+	})
+	sorted := &delaunayGraph{sites: make([]*site, len(live)), neighbours: make([][]int, len(live))}
+	for k, i := range live {
+		sorted.sites[k] = dynamic.graph.sites[i]
+	}
+	if len(live) > 1 {
+		if err := sorted.triangulateStrip(nil, 0, len(live)); err != nil {
+			return err
+		}
+	}
+
+	for i := range dynamic.graph.neighbours {
+		dynamic.graph.neighbours[i] = nil
+		dynamic.cellEdges[i] = nil
+	}
+	for k, neighbours := range sorted.neighbours {
+		ids := make([]int, len(neighbours))
+		for n, j := range neighbours {
+			ids[n] = live[j]
+		}
+		dynamic.graph.neighbours[live[k]] = ids
+	}
+	dynamic.triangles = 0
+	for _, i := range live {
+		for _, j := range dynamic.graph.neighbours[i] {
+			if m, ok := dynamic.graph.face(i, j); ok && j > i && m > i {
+				dynamic.triangles++
+			}
+		}
+	}
+	if len(live) > 0 {
+		dynamic.start = live[0]
+	}
+
+	dynamic.dcel = &doublyConnectedEdgeList{}
+	dynamic.vertexOf, dynamic.triangleOf = map[[3]int]*vertex{}, map[*vertex][3]int{}
+	dynamic.edgeSlots, dynamic.vertexSlots = map[*halfEdge]int{}, map[*vertex]int{}
+	dynamic.repair(live)
+	return nil
+}
+
+// nearest walks from neighbour to closer neighbour until it reaches the site nearest s. In a delaunay
+// triangulation every other site has a neighbour closer to s, so the walk cannot get stuck.
+func (dynamic *DynamicDiagram) nearest(s site) int {
+	current := dynamic.start
+	if dynamic.removed[current] {
+		for current = range dynamic.graph.sites {
+			if !dynamic.removed[current] {
+				break
+			}
+		}
+	}
+	distance := func(i int) float64 {
+		return math.Hypot(dynamic.graph.sites[i].x-s.x, dynamic.graph.sites[i].y-s.y)
+	}
+	for {
+		next, nextDistance := current, distance(current)
+		for _, j := range dynamic.graph.neighbours[current] {
+			if d := distance(j); d < nextDistance {
+				next, nextDistance = j, d
+			}
+		}
+		if next == current {
+			return current
+		}
+		current = next
+	}
+}
+
+// faceKey identifies the face to the left of the edge from a to b: a triangle by its sites anticlockwise
+// from the lowest, or the outside of the hull by the edge with -1 in place of a third site
+func (dynamic *DynamicDiagram) faceKey(a, b int) [3]int {
+	c, ok := dynamic.graph.face(a, b)
+	if !ok {
+		return [3]int{a, b, -1}
+	}
+	return triangleKey(a, b, c)
+}
+
+func triangleKey(a, b, c int) [3]int {
+	switch {
+	case b < a && b < c:
+		return [3]int{b, c, a}
+	case c < a && c < b:
+		return [3]int{c, a, b}
+	}
+	return [3]int{a, b, c}
+}
+
+// insertSite connects the new site p to the triangulation. Every face whose circle contains p is removed
+// - treating the outside of each hull edge as a face whose circle is the half-plane beyond it - starting
+// from the faces around the nearest site, which is always one of p's neighbours. p is then joined to each
+// site of the removed faces. ok is false if rounding left the result in doubt, in which case nothing has
+// changed, as the new triangles are all checked before the triangulation is touched.
+func (dynamic *DynamicDiagram) insertSite(p, nearest int) (changed []int, ok bool) {
+	graph := &dynamic.graph
+	sites := graph.sites
+	inCavity := func(key [3]int) bool {
+		a, b := sites[key[0]], sites[key[1]]
+		if key[2] >= 0 {
+			return inCircle(a, b, sites[key[2]], sites[p]) > 0
+		}
+		turn := orientation(a, b, sites[p])
+		along := (sites[p].x-a.x)*(b.x-a.x) + (sites[p].y-a.y)*(b.y-a.y)
+		return turn > 0 || (turn == 0 && along > 0 && along < (b.x-a.x)*(b.x-a.x)+(b.y-a.y)*(b.y-a.y))
+	}
+	// The faces across each side of a face, and whether that side is an edge of the triangulation
+	type side struct {
+		face     [3]int
+		from, to int
+	}
+	sidesOf := func(key [3]int) []side {
+		if key[2] >= 0 {
+			return []side{{dynamic.faceKey(key[1], key[0]), key[0], key[1]},
+				{dynamic.faceKey(key[2], key[1]), key[1], key[2]},
+				{dynamic.faceKey(key[0], key[2]), key[2], key[0]}}
+		}
+		a, b := key[0], key[1]
+		return []side{{dynamic.faceKey(b, a), a, b},
+			{dynamic.faceKey(graph.succ(a, b), a), -1, -1},
+			{dynamic.faceKey(b, graph.pred(b, a)), -1, -1}}
+	}
+
+	cavity := map[[3]int]bool{}
+	var stack [][3]int
+	for _, b := range graph.neighbours[nearest] {
+		if key := dynamic.faceKey(nearest, b); !cavity[key] && inCavity(key) {
+			cavity[key] = true
+			stack = append(stack, key)
+		}
+	}
+	if len(stack) == 0 {
+		return nil, false
+	}
+	checked := map[[3]int]bool{}
+	boundary := map[int]bool{}
+	removedTriangles := 0
+	for len(stack) > 0 {
+		key := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if key[2] >= 0 {
+			removedTriangles++
+		}
+		for _, site := range key {
+			if site >= 0 {
+				boundary[site] = true
+			}
+		}
+		for _, side := range sidesOf(key) {
+			if !cavity[side.face] && !checked[side.face] {
+				checked[side.face] = true
+				if inCavity(side.face) {
+					cavity[side.face] = true
+					stack = append(stack, side.face)
+				}
+			}
+		}
+	}
+	// Edges between two faces of the cavity are cut, while each edge between the cavity and a face outside
+	// it makes a new triangle with p
+	var cut, sides [][2]int
+	for key := range cavity {
+		for _, side := range sidesOf(key) {
+			if side.from < 0 {
+				continue
+			}
+			if cavity[side.face] {
+				cut = append(cut, [2]int{side.from, side.to})
+			} else {
+				sides = append(sides, [2]int{side.from, side.to})
+			}
+		}
+	}
+
+	// Every new triangle must turn anticlockwise between neighbours of p next to each other, and be delaunay
+	// against the site across from p. Only the outside of the hull may separate the others.
+	ring := make([]int, 0, len(boundary))
+	for site := range boundary {
+		ring = append(ring, site)
+	}
+	sort.Slice(ring, func(i, j int) bool { return graph.angle(p, ring[i]) < graph.angle(p, ring[j]) })
+	next := map[int]int{}
+	for k, b := range ring {
+		next[b] = ring[(k+1)%len(ring)]
+	}
+	for _, side := range sides {
+		a, b := side[0], side[1]
+		if next[a] != b || orientation(sites[a], sites[b], sites[p]) <= 0 {
+			return nil, false
+		}
+		if opposite, ok := graph.face(b, a); ok && inCircle(sites[a], sites[b], sites[p], sites[opposite]) > 0 {
+			return nil, false
+		}
+	}
+	if len(ring)-len(sides) > 1 {
+		return nil, false
+	}
+
+	for _, edge := range cut {
+		// Each edge is seen from both sides
+		if graph.position(edge[0], edge[1]) >= 0 {
+			graph.deleteEdge(edge[0], edge[1])
+		}
+	}
+	for _, site := range ring {
+		graph.insertEdge(p, site)
+	}
+	dynamic.triangles += len(sides) - removedTriangles
+	dynamic.start = p
+	return append([]int{p}, graph.neighbours[p]...), true
+}
+
+// removeSite takes the site r out of the triangulation and fills the hole with delaunay triangles, each
+// cut off as an ear of the polygon of r's neighbours whose circle contains none of the others (Devillers).
+// When r is on the hull its neighbours form a chain rather than a polygon, and the ears stop when the
+// chain is convex. ok is false if rounding left the result in doubt, in which case nothing has changed, as
+// the ears are all found before the triangulation is touched.
+func (dynamic *DynamicDiagram) removeSite(r int) (changed []int, ok bool) {
+	graph := &dynamic.graph
+	sites := graph.sites
+	neighbours := append([]int(nil), graph.neighbours[r]...)
+	polygon, closed, triangles := neighbours, true, 0
+	for k, b := range neighbours {
+		if _, isTriangle := graph.face(r, b); isTriangle {
+			triangles++
+			continue
+		}
+		// The outside of the hull lies between b and the next neighbour anticlockwise
+		if !closed {
+			return nil, false
+		}
+		polygon = append(append([]int(nil), neighbours[k+1:]...), neighbours[:k+1]...)
+		closed = false
+	}
+
+	var diagonals [][2]int
+	cut := map[[2]int]bool{}
+	for (closed && len(polygon) > 3) || (!closed && len(polygon) > 2) {
+		ear := -1
+		for i := range polygon {
+			if !closed && (i == 0 || i == len(polygon)-1) {
+				continue
+			}
+			u, v, w := polygon[(i+len(polygon)-1)%len(polygon)], polygon[i], polygon[(i+1)%len(polygon)]
+			if orientation(sites[u], sites[v], sites[w]) <= 0 {
+				continue
+			}
+			empty := true
+			for _, x := range polygon {
+				if x != u && x != v && x != w && inCircle(sites[u], sites[v], sites[w], sites[x]) > 0 {
+					empty = false
+					break
+				}
+			}
+			if empty {
+				ear = i
+				break
+			}
+		}
+		if ear < 0 {
+			if closed {
+				return nil, false
+			}
+			// What is left of the chain is convex, and is now part of the hull
+			break
+		}
+		u, w := polygon[(ear+len(polygon)-1)%len(polygon)], polygon[(ear+1)%len(polygon)]
+		if graph.position(u, w) >= 0 || cut[[2]int{u, w}] || cut[[2]int{w, u}] {
+			return nil, false
+		}
+		diagonals = append(diagonals, [2]int{u, w})
+		cut[[2]int{u, w}] = true
+		polygon = append(polygon[:ear:ear], polygon[ear+1:]...)
+	}
+
+	for _, b := range neighbours {
+		graph.deleteEdge(r, b)
+	}
+	for _, diagonal := range diagonals {
+		graph.insertEdge(diagonal[0], diagonal[1])
+	}
+	dynamic.removed[r] = true
+	dynamic.live--
+	dynamic.triangles += len(diagonals) - triangles
+	if closed {
+		dynamic.triangles++
+	}
+	if dynamic.start == r {
+		dynamic.start = neighbours[0]
+	}
+	return neighbours, true
+}
+
+// repair rebuilds the part of the dcel belonging to the changed cells: their half-edges, the vertices at
+// the ends of them, and the next pointers of the cells around them which led onto the old half-edges
+func (dynamic *DynamicDiagram) repair(changed []int) {
+	graph, dcel := &dynamic.graph, dynamic.dcel
+	var ends []*vertex
+	for _, a := range changed {
+		for _, halfEdge := range dynamic.cellEdges[a] {
+			b := dynamic.ids[halfEdge.twinEdge.site]
+			dynamic.cellEdges[b] = removeHalfEdge(dynamic.cellEdges[b], halfEdge.twinEdge)
+			ends = append(ends, halfEdge.originVertex, halfEdge.twinEdge.originVertex)
+			dynamic.removeEdge(halfEdge)
+		}
+		dynamic.cellEdges[a] = nil
+	}
+	// Vertices on the boundary belong to one half-edge, while triangle vertices stay as long as the
+	// triangle does
+	for _, end := range ends {
+		if _, ok := dynamic.vertexSlots[end]; !ok {
+			continue
+		}
+		if key, ok := dynamic.triangleOf[end]; ok {
+			if dynamic.isTriangle(key) {
+				continue
+			}
+			delete(dynamic.vertexOf, key)
+			delete(dynamic.triangleOf, end)
+		}
+		dynamic.removeVertex(end)
+	}
+
+	var halfEdges []*halfEdge
+	for _, a := range changed {
+		if dynamic.removed[a] {
+			continue
+		}
+		for _, b := range graph.neighbours[a] {
+			if dynamic.halfEdge(a, b) == nil {
+				halfEdge := dcel.addIsolatedEdge(graph.sites[a], graph.sites[b])
+				dynamic.edgeSlots[halfEdge] = len(dcel.edges) - 2
+				dynamic.cellEdges[a] = append(dynamic.cellEdges[a], halfEdge)
+				dynamic.cellEdges[b] = append(dynamic.cellEdges[b], halfEdge.twinEdge)
+				halfEdges = append(halfEdges, halfEdge, halfEdge.twinEdge)
+			}
+		}
+	}
+	// Each half-edge starts at the vertex of the triangle to the left of the edge between its sites. Hull
+	// edges are connected to the boundary after their other ends are known.
+	for _, halfEdge := range halfEdges {
+		a, b := int(dynamic.ids[halfEdge.site]), int(dynamic.ids[halfEdge.twinEdge.site])
+		if c, ok := graph.face(a, b); ok {
+			halfEdge.originVertex = dynamic.triangleVertex(triangleKey(a, b, c))
+		}
+	}
+	for _, halfEdge := range halfEdges {
+		if halfEdge.originVertex == nil {
+			connectEdgeToBoundary(dynamic.boundingBox, dcel, halfEdge)
+			dynamic.vertexSlots[halfEdge.originVertex] = len(dcel.vertices) - 1
+		}
+	}
+
+	relink := map[int]bool{}
+	for _, a := range changed {
+		relink[a] = true
+		for _, b := range graph.neighbours[a] {
+			relink[b] = true
+		}
+	}
+	for a := range relink {
+		for _, b := range graph.neighbours[a] {
+			halfEdge := dynamic.halfEdge(a, b)
+			halfEdge.nextEdge = nil
+			if c, ok := graph.face(b, a); ok {
+				halfEdge.nextEdge = dynamic.halfEdge(a, c)
+			}
+		}
+	}
+}
+
+// halfEdge returns the half-edge with a on its right and b on its left, or nil
+func (dynamic *DynamicDiagram) halfEdge(a, b int) *halfEdge {
+	for _, halfEdge := range dynamic.cellEdges[a] {
+		if halfEdge.twinEdge.site == dynamic.graph.sites[b] {
+			return halfEdge
+		}
+	}
+	return nil
+}
+
+func (dynamic *DynamicDiagram) isTriangle(key [3]int) bool {
+	for _, site := range key {
+		if dynamic.removed[site] {
+			return false
+		}
+	}
+	c, ok := dynamic.graph.face(key[0], key[1])
+	return ok && c == key[2]
+}
+
+func (dynamic *DynamicDiagram) triangleVertex(key [3]int) *vertex {
+	if triangleVertex, ok := dynamic.vertexOf[key]; ok {
+		return triangleVertex
+	}
+	// The same order as delaunayGraph.voronoi, so the vertex is in the same place
+	sites := dynamic.graph.sites
+	x, y, _ := circumcenter(sites[key[1]], sites[key[0]], sites[key[2]])
+	triangleVertex := dynamic.dcel.addIsolatedVertex(x, y)
+	dynamic.vertexSlots[triangleVertex] = len(dynamic.dcel.vertices) - 1
+	dynamic.vertexOf[key] = triangleVertex
+	dynamic.triangleOf[triangleVertex] = key
+	return triangleVertex
+}
+
+// removeEdge takes a half-edge pair out of the dcel, moving the last pair into its place
+func (dynamic *DynamicDiagram) removeEdge(halfEdge *halfEdge) {
+	if _, ok := dynamic.edgeSlots[halfEdge]; !ok {
+		halfEdge = halfEdge.twinEdge
+	}
+	edges, slot := dynamic.dcel.edges, dynamic.edgeSlots[halfEdge]
+	last := len(edges) - 2
+	edges[slot], edges[slot+1] = edges[last], edges[last+1]
+	dynamic.edgeSlots[edges[slot]] = slot
+	delete(dynamic.edgeSlots, halfEdge)
+	dynamic.dcel.edges = edges[:last]
+}
+
+// removeVertex takes a vertex out of the dcel, moving the last vertex into its place
+func (dynamic *DynamicDiagram) removeVertex(removed *vertex) {
+	vertices, slot := dynamic.dcel.vertices, dynamic.vertexSlots[removed]
+	last := len(vertices) - 1
+	vertices[slot] = vertices[last]
+	dynamic.vertexSlots[vertices[slot]] = slot
+	delete(dynamic.vertexSlots, removed)
+	dynamic.dcel.vertices = vertices[:last]
+}
+
+func removeHalfEdge(halfEdges []*halfEdge, removed *halfEdge) []*halfEdge {
+	for k, halfEdge := range halfEdges {
+		if halfEdge == removed {
+			halfEdges[k] = halfEdges[len(halfEdges)-1]
+			return halfEdges[:len(halfEdges)-1]
+		}
+	}
+	return halfEdges
+}
+
+func (dynamic *DynamicDiagram) liveSiteIDs() []SiteID {
+	ids := make([]SiteID, 0, dynamic.live)
+	for i, removed := range dynamic.removed {
+		if !removed {
+			ids = append(ids, SiteID(i))
+		}
+	}
+	return ids
+}
+
+func siteIDsOf(sites []int) []SiteID {
+	ids := make([]SiteID, len(sites))
+	for k, site := range sites {
+		ids[k] = SiteID(site)
+	}
+	return ids
+}
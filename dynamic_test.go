@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// dynamicEdges keys the edges of a dynamic diagram by the ids of the sites either side, like diagramEdges
+func dynamicEdges(t *testing.T, dynamic *DynamicDiagram) map[[2]SiteID][2]vertex {
+	if err := dynamic.dcel.validate(); err != nil {
+		t.Fatal(err)
+	}
+	minimumLength := 1e-9 * math.Max(dynamic.boundingBox.width, dynamic.boundingBox.height)
+	edges := map[[2]SiteID][2]vertex{}
+	for _, halfEdge := range dynamic.dcel.edges {
+		a, b := dynamic.ids[halfEdge.site], dynamic.ids[halfEdge.twinEdge.site]
+		start, end := *halfEdge.originVertex, *halfEdge.twinEdge.originVertex
+		if a > b || math.Hypot(end.x-start.x, end.y-start.y) < minimumLength {
+			continue
+		}
+		if _, ok := edges[[2]SiteID{a, b}]; ok {
+			t.Fatalf("sites %d and %d share more than one edge", a, b)
+		}
+		edges[[2]SiteID{a, b}] = [2]vertex{start, end}
+	}
+	return edges
+}
+
+// checkDynamic compares a dynamic diagram with the diagram computed from its sites
+func checkDynamic(t *testing.T, dynamic *DynamicDiagram) {
+	t.Helper()
+	ids := dynamic.liveSiteIDs()
+	siteList := make([]site, len(ids))
+	for k, id := range ids {
+		siteList[k], _ = dynamic.Site(id)
+	}
+	want, err := Compute(siteList, dynamic.boundingBox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEdges := map[[2]SiteID][2]vertex{}
+	for pair, edge := range diagramEdges(t, want) {
+		wantEdges[[2]SiteID{ids[pair[0]], ids[pair[1]]}] = edge
+	}
+	edges := dynamicEdges(t, dynamic)
+	if len(edges) != len(wantEdges) {
+		t.Fatalf("%d sites: got %d edges, want %d", len(ids), len(edges), len(wantEdges))
+	}
+	tolerance := 1e-9 * math.Max(dynamic.boundingBox.width, dynamic.boundingBox.height)
+	for pair, wantEdge := range wantEdges {
+		edge, ok := edges[pair]
+		if !ok {
+			t.Fatalf("%d sites: no edge between sites %d and %d", len(ids), pair[0], pair[1])
+		}
+		for k := range edge {
+			if math.Hypot(edge[k].x-wantEdge[k].x, edge[k].y-wantEdge[k].y) > tolerance*math.Max(1,
+				math.Hypot(wantEdge[k].x, wantEdge[k].y)/dynamic.boundingBox.width) {
+				t.Fatalf("%d sites: edge between sites %d and %d is %v, want %v", len(ids), pair[0], pair[1],
+					edge, wantEdge)
+			}
+		}
+	}
+}
+
+func TestDynamicDiagram(t *testing.T) {
+	source := rand.New(rand.NewSource(4))
+	for _, distribution := range siteDistributions {
+		siteList := distribution.generate(200, rand.New(rand.NewSource(1)))
+		dynamic, err := NewDynamicDiagram(siteList[:100], benchmarkBox)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkDynamic(t, dynamic)
+		for _, s := range siteList[100:] {
+			before := dynamicEdges(t, dynamic)
+			var changed []SiteID
+			if live := dynamic.liveSiteIDs(); source.Intn(3) == 0 {
+				if changed, err = dynamic.Remove(live[source.Intn(len(live))]); err != nil {
+					t.Fatal(err)
+				}
+			} else if _, changed, err = dynamic.Insert(s); err != nil {
+				t.Fatalf("%s: %v", distribution.name, err)
+			}
+			checkDynamic(t, dynamic)
+
+			// Edges between cells which did not change are untouched
+			isChanged := map[SiteID]bool{}
+			for _, id := range changed {
+				isChanged[id] = true
+			}
+			after := dynamicEdges(t, dynamic)
+			for pair, edge := range before {
+				if !isChanged[pair[0]] && !isChanged[pair[1]] && after[pair] != edge {
+					t.Fatalf("%s: edge between unchanged sites %d and %d moved", distribution.name, pair[0],
+						pair[1])
+				}
+			}
+		}
+	}
+}
+
+func TestDynamicDiagramDegenerate(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	dynamic, err := NewDynamicDiagram([]site{{x: 10, y: 50}, {x: 30, y: 50}}, box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Collinear sites, then a triangle, then back down to collinear
+	for _, s := range []site{{x: 50, y: 50}, {x: 70, y: 50}, {x: 40, y: 80}, {x: 40, y: 20}} {
+		if _, _, err := dynamic.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+		checkDynamic(t, dynamic)
+	}
+	for _, id := range []SiteID{5, 4, 0} {
+		if _, err := dynamic.Remove(id); err != nil {
+			t.Fatal(err)
+		}
+		checkDynamic(t, dynamic)
+	}
+
+	if _, _, err := dynamic.Insert(site{x: 30, y: 50}); !errors.Is(err, ErrDegenerateInput) {
+		t.Errorf("inserting a duplicate site returned %v, want ErrDegenerateInput", err)
+	}
+	if _, err := dynamic.Remove(0); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("removing a removed site returned %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestDynamicDiagramRemoveFallback(t *testing.T) {
+	// A site inside a pentagon, neighbouring each corner
+	siteList := []site{{x: 50, y: 50}, {x: 80, y: 50}, {x: 59.6, y: 79.5}, {x: 25.7, y: 67.6}, {x: 25.3, y: 32},
+		{x: 59.1, y: 21.9}}
+	dynamic, err := NewDynamicDiagram(siteList, boundingBox{width: 100, height: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dynamic.graph.neighbours[0]) != 5 {
+		t.Fatalf("the middle site has neighbours %v, want every corner", dynamic.graph.neighbours[0])
+	}
+	// Joining the corners to each other as well, as rounding can leave edges where a diagonal would go, puts
+	// every ear in doubt, so the removal has to fall back to rebuilding the diagram. The extra edges go after
+	// the corner before, leaving the triangles around the middle site as they were.
+	ring := dynamic.graph.neighbours[0]
+	for k, u := range ring {
+		before := ring[(k+len(ring)-1)%len(ring)]
+		for _, w := range ring {
+			if w != u && w != before && w != ring[(k+1)%len(ring)] {
+				neighbours := dynamic.graph.neighbours[u]
+				at := dynamic.graph.position(u, before) + 1
+				dynamic.graph.neighbours[u] = append(append(append([]int(nil), neighbours[:at]...), w),
+					neighbours[at:]...)
+			}
+		}
+	}
+	neighbours := append([]int(nil), dynamic.graph.neighbours[0]...)
+	if _, ok := dynamic.removeSite(0); ok {
+		t.Fatal("removed the middle site though every ear was in doubt")
+	}
+	if dynamic.live != 6 || dynamic.removed[0] || len(dynamic.graph.neighbours[0]) != len(neighbours) {
+		t.Fatalf("a failed removal left %d live sites and neighbours %v", dynamic.live, dynamic.graph.neighbours[0])
+	}
+	for _, id := range []SiteID{0, 3} {
+		if _, err := dynamic.Remove(id); err != nil {
+			t.Fatal(err)
+		}
+		if live := dynamic.liveSiteIDs(); dynamic.live != len(live) {
+			t.Fatalf("counted %d live sites, want %d", dynamic.live, len(live))
+		}
+		checkDynamic(t, dynamic)
+	}
+}
+
+func TestDynamicDiagramInsertFallback(t *testing.T) {
+	siteList := []site{{x: 76, y: 51}, {x: 50, y: 48}, {x: 86, y: 61}, {x: 33, y: 26}, {x: 16, y: 80}, {x: 24, y: 55}}
+	dynamic, err := NewDynamicDiagram(siteList, boundingBox{width: 100, height: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flipping the edge between the first two sites to the other diagonal of their triangles leaves two
+	// triangles which are not delaunay, so the triangles made around a new site fail their checks
+	graph := &dynamic.graph
+	c, ok := graph.face(0, 1)
+	d, ok2 := graph.face(1, 0)
+	if !ok || !ok2 {
+		t.Fatalf("the first two sites are on the hull: neighbours %v", graph.neighbours)
+	}
+	graph.deleteEdge(0, 1)
+	graph.insertEdge(c, d)
+
+	neighbours := make([][]int, len(graph.neighbours))
+	for i := range neighbours {
+		neighbours[i] = append([]int(nil), graph.neighbours[i]...)
+	}
+	triangles, start := dynamic.triangles, dynamic.start
+	s := site{x: 27, y: 35}
+	nearest := dynamic.nearest(s)
+	p := dynamic.addSite(s)
+	if _, ok := dynamic.insertSite(p, nearest); ok {
+		t.Fatal("inserted a site into triangles which are not delaunay")
+	}
+	// Undoing addSite must leave nothing referring to the new site
+	dynamic.removeLastSite()
+	if !reflect.DeepEqual(graph.neighbours, neighbours) || dynamic.triangles != triangles || dynamic.start != start {
+		t.Fatalf("a failed insertion left neighbours %v, %d triangles and start %d", graph.neighbours,
+			dynamic.triangles, dynamic.start)
+	}
+
+	if _, _, err := dynamic.Insert(s); err != nil {
+		t.Fatal(err)
+	}
+	if live := dynamic.liveSiteIDs(); dynamic.live != len(live) {
+		t.Fatalf("counted %d live sites, want %d", dynamic.live, len(live))
+	}
+	checkDynamic(t, dynamic)
+}
+
+func BenchmarkDynamicDiagram(b *testing.B) {
+	siteList := uniformSites(10000, rand.New(rand.NewSource(1)))
+	dynamic, err := NewDynamicDiagram(siteList, benchmarkBox)
+	if err != nil {
+		b.Fatal(err)
+	}
+	source := rand.New(rand.NewSource(2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id, _, err := dynamic.Insert(site{x: source.Float64() * 1000, y: source.Float64() * 1000})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := dynamic.Remove(id); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// EmptyCircle - a circle centred in the bounding box with no site inside it. Under the Manhattan metric the
+// circle is a diamond, under the Chebyshev metric a square, and under an anisotropy an ellipse.
+type EmptyCircle struct {
+	Center vertex
+	Radius float64
+	Sites  []SiteID // The sites on the circle, which hold it in place
+}
+
+// LargestEmptyCircle returns the largest circle centred in the bounding box with no site inside it
+func (diagram *Diagram) LargestEmptyCircle() EmptyCircle {
+	return diagram.LargestEmptyCircles(1)[0]
+}
+
+// LargestEmptyCircles returns the k largest empty circles, largest first - the holes furthest from any site.
+// Inside the box the distance to the nearest site only peaks at a voronoi vertex, and along the sides of
+// the box only where an edge crosses them or at a corner, so those are the only centres to try. Each is
+// returned once, so there may be fewer than k. The weights of a weighted diagram do not change which
+// circles are empty, so they are found from the unweighted diagram of its sites, computed along with it.
+func (diagram *Diagram) LargestEmptyCircles(k int) []EmptyCircle {
+	if diagram.unweighted != nil {
+		return diagram.unweighted.LargestEmptyCircles(k)
+	}
+	box := diagram.boundingBox
+	centres := &circleCentres{sitesAt: map[vertex][]SiteID{}}
+	// The ends of the clipped edges are the vertices inside the box and the crossings of its sides
+	for _, segment := range clippedSegments(diagram) {
+		centres.add(segment.p, segment.sites[:]...)
+		centres.add(segment.q, segment.sites[:]...)
+	}
+	for _, corner := range []vertex{{0, 0}, {box.width, 0}, {box.width, box.height}, {0, box.height}} {
+		centres.add(corner, diagram.Locate(corner))
+	}
+	return diagram.largestCircles(centres, k)
+}
+
+// LargestEmptyCirclesIn returns the k largest empty circles centred in a polygon inside the bounding box,
+// largest first, as LargestEmptyCircles does for the box. The centres tried are the voronoi vertices inside
+// the polygon, the crossings of the edges with its sides and its corners. The returned error wraps
+// ErrInvalidInput or ErrDegenerateInput if the region is not a simple polygon inside the box.
+func (diagram *Diagram) LargestEmptyCirclesIn(region Shape, k int) ([]EmptyCircle, error) {
+	if diagram.unweighted != nil {
+		return diagram.unweighted.LargestEmptyCirclesIn(region, k)
+	}
+	if len(region) < 3 {
+		return nil, &InputError{Index: -1, Reason: "a region needs three or more corners", Err: ErrInvalidInput}
+	}
+	if err := validateShapes([]Shape{region}); err != nil {
+		return nil, err
+	}
+	box := diagram.boundingBox
+	for _, corner := range region {
+		if corner.x < 0 || corner.x > box.width || corner.y < 0 || corner.y > box.height {
+			return nil, &InputError{Index: -1, Site: site(corner), Reason: "the region has a corner outside the " +
+				"bounding box", Err: ErrInvalidInput}
+		}
+	}
+
+	centres := &circleCentres{sitesAt: map[vertex][]SiteID{}}
+	sides := shapeSides(region)
+	for _, segment := range clippedSegments(diagram) {
+		for _, end := range []vertex{segment.p, segment.q} {
+			if insidePolygon(end, region) {
+				centres.add(end, segment.sites[:]...)
+			}
+		}
+		d := vertex{x: segment.q.x - segment.p.x, y: segment.q.y - segment.p.y}
+		for _, side := range sides {
+			e := vertex{x: side[1].x - side[0].x, y: side[1].y - side[0].y}
+			f := vertex{x: side[0].x - segment.p.x, y: side[0].y - segment.p.y}
+			denominator := d.x*e.y - d.y*e.x
+			if denominator == 0 {
+				// Along the side, where the edge ends on it
+				for _, end := range []vertex{segment.p, segment.q} {
+					if distanceToSegment(end, side[0], side[1]) == 0 {
+						centres.add(end, segment.sites[:]...)
+					}
+				}
+				continue
+			}
+			t, u := (f.x*e.y-f.y*e.x)/denominator, (f.x*d.y-f.y*d.x)/denominator
+			if t >= 0 && t <= 1 && u >= 0 && u <= 1 {
+				centres.add(vertex{x: segment.p.x + t*d.x, y: segment.p.y + t*d.y}, segment.sites[:]...)
+			}
+		}
+	}
+	for _, corner := range region {
+		centres.add(corner, diagram.Locate(corner))
+	}
+	return diagram.largestCircles(centres, k), nil
+}
+
+// circleCentres - the points to try as the centres of empty circles, with the sites nearest each
+type circleCentres struct {
+	centres []vertex
+	sitesAt map[vertex][]SiteID
+}
+
+func (centres *circleCentres) add(p vertex, ids ...SiteID) {
+	if _, ok := centres.sitesAt[p]; !ok {
+		centres.centres = append(centres.centres, p)
+	}
+	centres.sitesAt[p] = append(centres.sitesAt[p], ids...)
+}
+
+// largestCircles returns the k largest of the circles around the centres reaching their nearest sites
+func (diagram *Diagram) largestCircles(centres *circleCentres, k int) []EmptyCircle {
+	circles := make([]EmptyCircle, 0, len(centres.centres))
+	for _, centre := range centres.centres {
+		circle := EmptyCircle{Center: centre, Radius: math.Inf(1)}
+		ids := centres.sitesAt[centre]
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for n, id := range ids {
+			if n > 0 && id == ids[n-1] {
+				continue
+			}
+			circle.Radius = math.Min(circle.Radius, diagram.siteDistance(id, centre))
+			circle.Sites = append(circle.Sites, id)
+		}
+		circles = append(circles, circle)
+	}
+	sort.Slice(circles, func(i, j int) bool {
+		if circles[i].Radius != circles[j].Radius {
+			return circles[i].Radius > circles[j].Radius
+		}
+		return pointLeftOf(circles[i].Center, circles[j].Center)
+	})
+	if k = max(k, 0); k < len(circles) {
+		circles = circles[:k]
+	}
+	return circles
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestLargestEmptyCircleCorner(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	diagram, err := Compute([]site{{x: 10, y: 20}, {x: 30, y: 20}}, box, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	circle := diagram.LargestEmptyCircle()
+	if circle.Center != (vertex{x: 100, y: 100}) || !nearlyEqual(circle.Radius, math.Hypot(70, 80)) ||
+		len(circle.Sites) != 1 || circle.Sites[0] != 1 {
+		t.Errorf("got %+v", circle)
+	}
+}
+
+func TestLargestEmptyCircles(t *testing.T) {
+	for _, distribution := range siteDistributions {
+		siteList := distribution.generate(300, rand.New(rand.NewSource(3)))
+		diagram, err := Compute(siteList, benchmarkBox, Options{DuplicatePolicy: KeepFirstDuplicate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nearest := func(p vertex) float64 {
+			distance := math.Inf(1)
+			for _, s := range diagram.sites {
+				distance = math.Min(distance, math.Hypot(s.x-p.x, s.y-p.y))
+			}
+			return distance
+		}
+
+		circles := diagram.LargestEmptyCircles(10)
+		if len(circles) != 10 {
+			t.Fatalf("%s: got %d circles, want 10", distribution.name, len(circles))
+		}
+		for k, circle := range circles {
+			// Empty, held by at least one site and ranked largest first
+			if math.Abs(nearest(circle.Center)-circle.Radius) > 1e-6 || len(circle.Sites) == 0 ||
+				(k > 0 && circle.Radius > circles[k-1].Radius) {
+				t.Fatalf("%s: got circle %d %+v, nearest site %v away", distribution.name, k, circle,
+					nearest(circle.Center))
+			}
+			for _, id := range circle.Sites {
+				s := diagram.sites[id]
+				if math.Abs(math.Hypot(s.x-circle.Center.x, s.y-circle.Center.y)-circle.Radius) > 1e-6 {
+					t.Errorf("%s: site %d is not on circle %+v", distribution.name, id, circle)
+				}
+			}
+		}
+
+		// No point of the box is further from every site than the largest circle's radius
+		for x := 0.0; x <= benchmarkBox.width; x += 5 {
+			for y := 0.0; y <= benchmarkBox.height; y += 5 {
+				if distance := nearest(vertex{x: x, y: y}); distance > circles[0].Radius+1e-6 {
+					t.Fatalf("%s: (%v, %v) is %v from the nearest site, largest circle is %+v", distribution.name,
+						x, y, distance, circles[0])
+				}
+			}
+		}
+	}
+}
+
+func TestLargestEmptyCirclesIn(t *testing.T) {
+	siteList := uniformSites(200, rand.New(rand.NewSource(7)))
+	diagram, err := Compute(siteList, benchmarkBox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nearest := func(p vertex) float64 {
+		distance := math.Inf(1)
+		for _, s := range diagram.sites {
+			distance = math.Min(distance, math.Hypot(s.x-p.x, s.y-p.y))
+		}
+		return distance
+	}
+	// An L shape, which is not convex
+	region := Shape{{100, 100}, {900, 100}, {900, 400}, {400, 400}, {400, 900}, {100, 900}}
+	circles, err := diagram.LargestEmptyCirclesIn(region, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(circles) != 5 {
+		t.Fatalf("got %d circles, want 5", len(circles))
+	}
+	for k, circle := range circles {
+		inside := insidePolygon(circle.Center, region)
+		for _, side := range shapeSides(region) {
+			inside = inside || distanceToSegment(circle.Center, side[0], side[1]) < 1e-9
+		}
+		if !inside || math.Abs(nearest(circle.Center)-circle.Radius) > 1e-6 || len(circle.Sites) == 0 ||
+			(k > 0 && circle.Radius > circles[k-1].Radius) {
+			t.Fatalf("got circle %d %+v, nearest site %v away", k, circle, nearest(circle.Center))
+		}
+	}
+	for x := 100.0; x <= 900; x += 5 {
+		for y := 100.0; y <= 900; y += 5 {
+			if p := (vertex{x: x, y: y}); (x <= 400 || y <= 400) && nearest(p) > circles[0].Radius+1e-6 {
+				t.Fatalf("%v is %v from the nearest site, largest circle is %+v", p, nearest(p), circles[0])
+			}
+		}
+	}
+
+	// Along a thin strip the circles are centred where edges cross its sides
+	strip := Shape{{100, 500}, {900, 500}, {900, 501}, {100, 501}}
+	if circles, err = diagram.LargestEmptyCirclesIn(strip, 1); err != nil {
+		t.Fatal(err)
+	}
+	for x := 100.0; x <= 900; x += 0.5 {
+		for _, p := range []vertex{{x: x, y: 500}, {x: x, y: 501}} {
+			if nearest(p) > circles[0].Radius+1e-6 {
+				t.Fatalf("%v is %v from the nearest site, largest circle in the strip is %+v", p, nearest(p),
+					circles[0])
+			}
+		}
+	}
+
+	// The whole box gives the same circles as the box itself
+	box := Shape{{0, 0}, {benchmarkBox.width, 0}, {benchmarkBox.width, benchmarkBox.height}, {0, benchmarkBox.height}}
+	if circles, err := diagram.LargestEmptyCirclesIn(box, 1); err != nil || !nearlyEqual(circles[0].Radius,
+		diagram.LargestEmptyCircle().Radius) {
+		t.Errorf("got %+v and %v in the whole box, want %+v", circles, err, diagram.LargestEmptyCircle())
+	}
+	if _, err := diagram.LargestEmptyCirclesIn(Shape{{-10, 10}, {50, 10}, {50, 50}}, 1); !errors.Is(err,
+		ErrInvalidInput) {
+		t.Errorf("a region outside the box: got %v, want ErrInvalidInput", err)
+	}
+}
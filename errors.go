@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidInput - a site coordinate or the bounding box is not a usable number
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrDegenerateInput - the sites do not describe a voronoi diagram (e.g. too few or duplicated sites)
+	ErrDegenerateInput = errors.New("degenerate input")
+	// ErrNumericalFailure - floating point error broke the sweep or produced a non-finite diagram
+	ErrNumericalFailure = errors.New("numerical failure")
+	// ErrLimitExceeded - computing the diagram would take more than one of the Limits allows
+	ErrLimitExceeded = errors.New("limit exceeded")
+	// ErrNoPath - no path joins two points along the edges of a roadmap
+	ErrNoPath = errors.New("no path")
+)
+
+// InputError - reports which input site was rejected and why
+type InputError struct {
+	Index  int // Index of the offending site in the input, or -1 if the input as a whole was rejected
+	Site   site
+	Reason string
+	Err    error // ErrInvalidInput or ErrDegenerateInput
+}
+
+func (e *InputError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%v: %s", e.Err, e.Reason)
+	}
+	return fmt.Sprintf("%v: site %d (%v, %v) %s", e.Err, e.Index, e.Site.x, e.Site.y, e.Reason)
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// EventError - reports the event being handled when the sweep failed
+type EventError struct {
+	EventType EventType
+	Location  vertex // The site, or the centre of the circle
+	Err       error
+}
+
+func (e *EventError) Error() string {
+	return fmt.Sprintf("%v: handling %s event at (%v, %v)", e.Err, e.EventType, e.Location.x, e.Location.y)
+}
+
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+// LimitError - reports which of the Limits was exceeded
+type LimitError struct {
+	Limit string // "sites", "events" or "dcel elements"
+	Max   int
+	Err   error // ErrLimitExceeded
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: more than %d %s", e.Err, e.Max, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sort"
+)
+
+// EventType - the kind of event handled by the sweep
+type EventType int
+
+const (
+	// SiteEvent - the sweepline reaches a site and a new arc appears on the beachline
+	SiteEvent EventType = iota
+	// CircleEvent - an arc shrinks to nothing, leaving a voronoi vertex at the centre of the circle
+	CircleEvent
+)
+
+func (eventType EventType) String() string {
+	if eventType == SiteEvent {
+		return "site"
+	}
+	return "circle"
+}
+
+// circleEvent - the sweepline reaching the bottom of the circle through the sites of three consecutive arcs.
+// Events are never removed from the queue part way through, instead they are marked as a false alarm when
+// the arcs change and skipped once they reach the front of the queue.
+type circleEvent struct {
+	center     vertex
+	y          float64 // Bottom of the circle - the sweepline position at which the event happens
+	leafNode   *node
+	falseAlarm bool
+}
+
+// event - the next event for the sweep to handle, with either site or circle set
+type event struct {
+	eventType EventType
+	site      *site
+	circle    *circleEvent
+}
+
+// eventQueue - all the site events are known before the sweep starts so they are sorted once up front,
+// while circle events are discovered during the sweep and kept in a priority queue. Events are handled
+// from the top down, and events at the same height from left to right.
+type eventQueue struct {
+	sites    []*site
+	nextSite int
+	circles  priorityQueue[*circleEvent]
+	arena    *arena // Where circle events are allocated
+}
+
+// newEventQueue creates a queue holding a site event for each of the input sites
+func newEventQueue(siteList []site) *eventQueue {
+	queue := &eventQueue{
+		circles: newPriorityQueue(func(a, b *circleEvent) bool {
+			// The queue pops its greatest item, so a is less than b when b should happen first
+			return b.y > a.y || (b.y == a.y && b.center.x < a.center.x)
+		}),
+	}
+	queue.reset(siteList)
+	return queue
+}
+
+// reset - empty the queue and fill it with a site event for each of the input sites, reusing the memory
+// of the previous sweep. The sorting puts duplicate sites next to each other and only the first is kept.
+func (queue *eventQueue) reset(siteList []site) {
+	sites := queue.sites[:0]
+	for i := range siteList {
+		sites = append(sites, &siteList[i])
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		return siteBefore(sites[i], sites[j])
+	})
+
+	unique := sites[:0]
+	for _, site := range sites {
+		if len(unique) == 0 || *unique[len(unique)-1] != *site {
+			unique = append(unique, site)
+		}
+	}
+	clear(sites[len(unique):])
+
+	queue.sites = unique
+	queue.nextSite = 0
+	queue.circles.clear()
+}
+
+func siteBefore(a, b *site) bool {
+	return a.y > b.y || (a.y == b.y && a.x < b.x)
+}
+
+// pushCircle - add a circle event to the queue
+func (queue *eventQueue) pushCircle(circle *circleEvent) {
+	queue.circles.push(circle)
+}
+
+// pop - remove and return the next event. ok is false once the queue is empty.
+func (queue *eventQueue) pop() (next event, ok bool) {
+	// Discard false alarms so the front of the circle queue is a real event
+	for queue.circles.len() > 0 && queue.circles.peek().falseAlarm {
+		queue.circles.pop()
+	}
+
+	hasSite := queue.nextSite < len(queue.sites)
+	hasCircle := queue.circles.len() > 0
+	switch {
+	case hasCircle && hasSite:
+		// An arc vanishing at the same point as a new site appears is handled first
+		circle, site := queue.circles.peek(), queue.sites[queue.nextSite]
+		if circle.y > site.y || (circle.y == site.y && circle.center.x <= site.x) {
+			return event{eventType: CircleEvent, circle: queue.circles.pop()}, true
+		}
+		fallthrough
+	case hasSite:
+		queue.nextSite++
+		return event{eventType: SiteEvent, site: queue.sites[queue.nextSite-1]}, true
+	case hasCircle:
+		return event{eventType: CircleEvent, circle: queue.circles.pop()}, true
+	}
+	return event{}, false
+}
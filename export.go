@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// EdgeWeight - what the edges of an exported adjacency graph are weighted by
+type EdgeWeight int
+
+const (
+	// NoWeight - edges have no weight
+	NoWeight EdgeWeight = iota
+	// SharedBorderWeight - the length of the border between the two cells inside the bounding box
+	SharedBorderWeight
+	// SiteDistanceWeight - the distance between the two sites, under the metric of the diagram
+	SiteDistanceWeight
+)
+
+// adjacency - an edge of the adjacency graph, between two sites whose cells share a border
+type adjacency struct {
+	a, b   SiteID
+	weight float64
+}
+
+// adjacencies returns each pair of neighbouring sites once, lowest id first, in order. They are the
+// neighbours CellStats finds, so borders of zero length - where four or more cells meet at a point, or
+// outside the bounding box - don't make the sites neighbours.
+func (diagram *Diagram) adjacencies(weight EdgeWeight) []adjacency {
+	shared := map[[2]SiteID]float64{}
+	for id, cell := range diagram.CellStats() {
+		for _, neighbour := range cell.Neighbours {
+			// A border can be met more than once going around a cell, as a cell may be in several pieces
+			if SiteID(id) < neighbour.ID {
+				shared[[2]SiteID{SiteID(id), neighbour.ID}] += neighbour.SharedLength
+			}
+		}
+	}
+	edges := make([]adjacency, 0, len(shared))
+	for pair, length := range shared {
+		edges = append(edges, adjacency{a: pair[0], b: pair[1]})
+		if weight == SharedBorderWeight {
+			edges[len(edges)-1].weight = length
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].a < edges[j].a || (edges[i].a == edges[j].a && edges[i].b < edges[j].b)
+	})
+	if weight == SiteDistanceWeight {
+		for k := range edges {
+			edges[k].weight = diagram.siteDistance(edges[k].a, vertex(diagram.sites[edges[k].b]))
+		}
+	}
+	return edges
+}
+
+// WriteDOT writes the adjacency graph of the sites in Graphviz DOT format. Each node is pinned at its site
+// and each edge carries its weight, unless weight is NoWeight.
+func (diagram *Diagram) WriteDOT(w io.Writer, weight EdgeWeight) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "graph voronoi {")
+	for id, s := range diagram.sites {
+		fmt.Fprintf(out, "\t%d [pos=\"%s,%s!\"];\n", id, formatFloat(s.x), formatFloat(s.y))
+	}
+	for _, edge := range diagram.adjacencies(weight) {
+		if weight == NoWeight {
+			fmt.Fprintf(out, "\t%d -- %d;\n", edge.a, edge.b)
+		} else {
+			fmt.Fprintf(out, "\t%d -- %d [weight=%s];\n", edge.a, edge.b, formatFloat(edge.weight))
+		}
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+// WriteGraphML writes the adjacency graph of the sites in GraphML format, with the position of each site
+// as node data and the weight of each edge as edge data, unless weight is NoWeight
+func (diagram *Diagram) WriteGraphML(w io.Writer, weight EdgeWeight) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(out, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(out, `  <key id="x" for="node" attr.name="x" attr.type="double"/>`)
+	fmt.Fprintln(out, `  <key id="y" for="node" attr.name="y" attr.type="double"/>`)
+	if weight != NoWeight {
+		fmt.Fprintln(out, `  <key id="weight" for="edge" attr.name="weight" attr.type="double"/>`)
+	}
+	fmt.Fprintln(out, `  <graph id="voronoi" edgedefault="undirected">`)
+	for id, s := range diagram.sites {
+		fmt.Fprintf(out, "    <node id=\"n%d\"><data key=\"x\">%s</data><data key=\"y\">%s</data></node>\n", id,
+			formatFloat(s.x), formatFloat(s.y))
+	}
+	for _, edge := range diagram.adjacencies(weight) {
+		if weight == NoWeight {
+			fmt.Fprintf(out, "    <edge source=\"n%d\" target=\"n%d\"/>\n", edge.a, edge.b)
+		} else {
+			fmt.Fprintf(out, "    <edge source=\"n%d\" target=\"n%d\"><data key=\"weight\">%s</data></edge>\n",
+				edge.a, edge.b, formatFloat(edge.weight))
+		}
+	}
+	fmt.Fprintln(out, "  </graph>")
+	fmt.Fprintln(out, "</graphml>")
+	return out.Flush()
+}
+
+// WriteEdgeCSV writes the adjacency graph of the sites as a CSV edge list with a header row, and a weight
+// column unless weight is NoWeight
+func (diagram *Diagram) WriteEdgeCSV(w io.Writer, weight EdgeWeight) error {
+	out := csv.NewWriter(w)
+	header := []string{"source", "target"}
+	if weight != NoWeight {
+		header = append(header, "weight")
+	}
+	out.Write(header)
+	for _, edge := range diagram.adjacencies(weight) {
+		record := []string{strconv.Itoa(int(edge.a)), strconv.Itoa(int(edge.b))}
+		if weight != NoWeight {
+			record = append(record, formatFloat(edge.weight))
+		}
+		out.Write(record)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
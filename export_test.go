@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	// A square of sites has a zero length border between opposite corners, which is not an adjacency, and
+	// the borders of the site outside the box are clipped away
+	siteList := []site{{x: 25, y: 25}, {x: 75, y: 25}, {x: 25, y: 75}, {x: 75, y: 75}, {x: 50, y: 150}}
+	diagram, err := Compute(siteList, box, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []adjacency{{a: 0, b: 1, weight: 50}, {a: 0, b: 2, weight: 50}, {a: 1, b: 3, weight: 50},
+		{a: 2, b: 3, weight: 50}}
+	got := diagram.adjacencies(SharedBorderWeight)
+	if len(got) != len(want) {
+		t.Fatalf("got adjacencies %v, want %v", got, want)
+	}
+	for k := range want {
+		if got[k].a != want[k].a || got[k].b != want[k].b || !nearlyEqual(got[k].weight, want[k].weight) {
+			t.Fatalf("got adjacencies %v, want %v", got, want)
+		}
+	}
+
+	var dot strings.Builder
+	if err := diagram.WriteDOT(&dot, NoWeight); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot.String(), "\t4 [pos=\"50,150!\"];\n") ||
+		!strings.Contains(dot.String(), "\t2 -- 3;\n") || strings.Count(dot.String(), " -- ") != len(want) {
+		t.Errorf("got DOT\n%s", dot.String())
+	}
+
+	// Sites 1 and 2 share a border, but all of it is outside the box
+	diagram, err = Compute([]site{{x: 50, y: 50}, {x: 20, y: 200}, {x: 80, y: 200}, {x: 50, y: 300}}, box, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diagram.adjacencies(NoWeight); len(got) != 0 {
+		t.Errorf("got adjacencies %v outside the box", got)
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	diagram, err := Compute(uniformSites(300, rand.New(rand.NewSource(1))), benchmarkBox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := diagram.adjacencies(SiteDistanceWeight)
+
+	var graphML strings.Builder
+	if err := diagram.WriteGraphML(&graphML, SiteDistanceWeight); err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		Nodes []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"graph>node"`
+		Edges []struct {
+			Source string `xml:"source,attr"`
+			Target string `xml:"target,attr"`
+			Weight string `xml:"data"`
+		} `xml:"graph>edge"`
+	}
+	if err := xml.Unmarshal([]byte(graphML.String()), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Nodes) != len(diagram.sites) || len(parsed.Edges) != len(want) {
+		t.Fatalf("GraphML has %d nodes and %d edges, want %d and %d", len(parsed.Nodes), len(parsed.Edges),
+			len(diagram.sites), len(want))
+	}
+	for k, edge := range parsed.Edges {
+		if edge.Source != "n"+strconv.Itoa(int(want[k].a)) || edge.Target != "n"+strconv.Itoa(int(want[k].b)) ||
+			edge.Weight != formatFloat(want[k].weight) {
+			t.Fatalf("GraphML edge %d is %+v, want %+v", k, edge, want[k])
+		}
+	}
+
+	var edgeList strings.Builder
+	if err := diagram.WriteEdgeCSV(&edgeList, SiteDistanceWeight); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(edgeList.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(want)+1 || strings.Join(records[0], ",") != "source,target,weight" {
+		t.Fatalf("CSV has %d rows starting %v", len(records), records[0])
+	}
+	for k, record := range records[1:] {
+		weight, _ := strconv.ParseFloat(record[2], 64)
+		if record[0] != strconv.Itoa(int(want[k].a)) || record[1] != strconv.Itoa(int(want[k].b)) ||
+			weight != want[k].weight {
+			t.Fatalf("CSV row %d is %v, want %+v", k, record, want[k])
+		}
+	}
+}
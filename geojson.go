@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// geodesicStep - the longest a cell side runs, in degrees, between the points it is drawn through
+const geodesicStep = 1.0
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Properties geoJSONProperties `json:"properties"`
+	Geometry   geoJSONGeometry   `json:"geometry"`
+}
+
+type geoJSONProperties struct {
+	ID  SiteID  `json:"id"`
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// WriteGeoJSON writes the cells as a GeoJSON feature collection, one feature per site in order of id with the
+// site's position among its properties. Sides are followed along their great circles through a point at least
+// every degree, and cells crossing the antimeridian are cut along it into a MultiPolygon (RFC 7946).
+func (diagram *SphericalDiagram) WriteGeoJSON(w io.Writer) error {
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	for id, s := range diagram.sites {
+		feature := geoJSONFeature{Type: "Feature", Properties: geoJSONProperties{ID: SiteID(id), Lat: s.Lat,
+			Lon: s.Lon}}
+		pieces := diagram.cellPieces(SiteID(id))
+		if len(pieces) == 1 {
+			feature.Geometry = geoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{pieces[0]}}
+		} else {
+			polygons := make([][][][2]float64, len(pieces))
+			for k, piece := range pieces {
+				polygons[k] = [][][2]float64{piece}
+			}
+			feature.Geometry = geoJSONGeometry{Type: "MultiPolygon", Coordinates: polygons}
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+	return json.NewEncoder(w).Encode(collection)
+}
+
+// cellPieces returns the outline of a cell as closed anticlockwise rings of [longitude, latitude], one for
+// each piece the antimeridian cuts it into
+func (diagram *SphericalDiagram) cellPieces(id SiteID) [][][2]float64 {
+	ring := unwrapLongitudes(diagram.geodesicOutline(id))
+	if len(ring) < 3 {
+		return nil
+	}
+	// A cell around a pole winds once round the globe, so its outline is closed along the top or bottom
+	winding := ring[len(ring)-1][0] - ring[0][0] + wrapLongitude(ring[0][0]-ring[len(ring)-1][0])
+	if math.Abs(winding) > 180 {
+		first := ring[0]
+		ring = append(ring, [2]float64{first[0] + winding, first[1]}, [2]float64{first[0] + winding,
+			math.Copysign(90, winding)}, [2]float64{first[0], math.Copysign(90, winding)})
+	}
+
+	west, east := math.Inf(1), math.Inf(-1)
+	for _, p := range ring {
+		west, east = math.Min(west, p[0]), math.Max(east, p[0])
+	}
+	var pieces [][][2]float64
+	for turn := math.Floor((west + 180) / 360); turn*360-180 < east; turn++ {
+		piece := clipLongitudes(ring, turn*360-180, turn*360+180)
+		area := 0.0
+		for k, p := range piece {
+			next := piece[(k+1)%len(piece)]
+			area += p[0]*next[1] - next[0]*p[1]
+		}
+		if len(piece) < 3 || area <= 1e-12 {
+			continue
+		}
+		for k := range piece {
+			piece[k][0] -= turn * 360
+		}
+		pieces = append(pieces, append(piece, piece[0]))
+	}
+	return pieces
+}
+
+// geodesicOutline returns points along the sides of a cell, close enough together to be joined by straight
+// lines on a map. A side through a pole passes through it exactly.
+func (diagram *SphericalDiagram) geodesicOutline(id SiteID) []vector3 {
+	cell := diagram.cells[id]
+	var outline []vector3
+	for k, corner := range cell {
+		from, to := corner.point, cell[(k+1)%len(cell)].point
+		stops := []vector3{from}
+		plane := from.cross(to)
+		for _, pole := range []vector3{{z: 1}, {z: -1}} {
+			if plane.length() > 0 && math.Abs(plane.unit().dot(pole)) < 1e-12 && from.cross(pole).dot(plane) > 0 &&
+				pole.cross(to).dot(plane) > 0 {
+				stops = append(stops, pole)
+			}
+		}
+		stops = append(stops, to)
+		for j := 1; j < len(stops); j++ {
+			a, b := stops[j-1], stops[j]
+			angle := a.angle(b)
+			steps := max(1, int(math.Ceil(angle*180/math.Pi/geodesicStep)))
+			for step := 0; step < steps; step++ {
+				t := float64(step) / float64(steps)
+				// Spherical linear interpolation
+				if angle == 0 {
+					outline = append(outline, a)
+					continue
+				}
+				outline = append(outline, a.scale(math.Sin((1-t)*angle)/math.Sin(angle)).
+					add(b.scale(math.Sin(t*angle)/math.Sin(angle))))
+			}
+		}
+	}
+	return outline
+}
+
+// unwrapLongitudes returns the points as [longitude, latitude] with each longitude within 180 degrees of the
+// one before, so that no side jumps across the map. At a pole the outline runs along the top or bottom of
+// the map from the longitude it arrived at to the one it leaves at - westwards at the north pole and
+// eastwards at the south, as the cell is on the left.
+func unwrapLongitudes(outline []vector3) [][2]float64 {
+	polar := func(p vector3) bool { return math.Hypot(p.x, p.y) < 1e-12 }
+	var ring [][2]float64
+	add := func(lon, lat float64) {
+		if len(ring) > 0 {
+			lon = ring[len(ring)-1][0] + wrapLongitude(lon-ring[len(ring)-1][0])
+		}
+		ring = append(ring, [2]float64{lon, lat})
+	}
+	for k, p := range outline {
+		if !polar(p) {
+			position := p.latLon()
+			add(position.Lon, position.Lat)
+			continue
+		}
+		var arrive, leave LatLon
+		for j := len(outline) - 1; j > 0; j-- {
+			if previous := outline[(k+j)%len(outline)]; !polar(previous) {
+				arrive = previous.latLon()
+				break
+			}
+		}
+		for j := 1; j < len(outline); j++ {
+			if next := outline[(k+j)%len(outline)]; !polar(next) {
+				leave = next.latLon()
+				break
+			}
+		}
+		lat := math.Copysign(90, p.z)
+		add(arrive.Lon, lat)
+		turn := math.Mod(leave.Lon-arrive.Lon+720, 360)
+		if lat > 0 && turn > 0 {
+			turn -= 360
+		}
+		ring = append(ring, [2]float64{ring[len(ring)-1][0] + turn, lat})
+	}
+	return ring
+}
+
+// wrapLongitude returns the longitude turned by whole turns to between -180 and 180
+func wrapLongitude(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
+
+// clipLongitudes keeps the part of a ring between two longitudes (Sutherland-Hodgman). Cells are convex, so
+// what is left is one piece.
+func clipLongitudes(ring [][2]float64, west, east float64) [][2]float64 {
+	for _, side := range []struct{ at, outside float64 }{{west, -1}, {east, 1}} {
+		var clipped [][2]float64
+		for k, p := range ring {
+			next := ring[(k+1)%len(ring)]
+			pSide, nextSide := side.outside*(p[0]-side.at), side.outside*(next[0]-side.at)
+			if pSide <= 0 {
+				clipped = append(clipped, p)
+			}
+			if (pSide <= 0) != (nextSide <= 0) {
+				t := pSide / (pSide - nextSide)
+				clipped = append(clipped, [2]float64{side.at, p[1] + t*(next[1]-p[1])})
+			}
+		}
+		ring = clipped
+	}
+	return ring
+}
+
+// renderSphericalVoronoi draws the cell sides and sites on an equirectangular map of the globe, width pixels
+// across and half that high, with longitude -180 at the left and the north pole at the top
+func renderSphericalVoronoi(diagram *SphericalDiagram, width int) *gg.Context {
+	height := width / 2
+	toMap := func(p [2]float64) (x, y float64) {
+		return (p[0] + 180) / 360 * float64(width), (90 - p[1]) / 180 * float64(height)
+	}
+	voronoi := gg.NewContext(width, height)
+	voronoi.SetRGB(1, 1, 1)
+	voronoi.Clear()
+	voronoi.SetLineWidth(2)
+
+	voronoi.SetRGB(0.3, 0.7, 0.8)
+	for id := range diagram.cells {
+		ring := unwrapLongitudes(diagram.geodesicOutline(SiteID(id)))
+		for k := 1; k <= len(ring); k++ {
+			p, q := ring[k-1], ring[k%len(ring)]
+			if k == len(ring) {
+				q[0] = p[0] + wrapLongitude(q[0]-p[0])
+			}
+			if math.Abs(p[1]) == 90 && p[1] == q[1] {
+				// Along the top or bottom of the map, which is a single point
+				continue
+			}
+			// Drawn again a turn either way, for the sides that run off the map
+			shift := -360 * math.Floor((p[0]+180)/360)
+			for _, turn := range []float64{shift - 360, shift, shift + 360} {
+				x0, y0 := toMap([2]float64{p[0] + turn, p[1]})
+				x1, y1 := toMap([2]float64{q[0] + turn, q[1]})
+				voronoi.DrawLine(x0, y0, x1, y1)
+				voronoi.Stroke()
+			}
+		}
+	}
+
+	voronoi.SetRGB(0.9, 0.5, 0.6)
+	for _, s := range diagram.sites {
+		x, y := toMap([2]float64{wrapLongitude(s.Lon), s.Lat})
+		voronoi.DrawPoint(x, y, 2.0)
+		voronoi.Stroke()
+	}
+	return voronoi
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// SiteID - index of a site in a diagram after duplicates have been merged. Each site has one cell.
+type SiteID int
+
+// DuplicatePolicy - what to do with sites that lie within the duplicate tolerance of an earlier site
+type DuplicatePolicy int
+
+const (
+	// RejectDuplicates - fail with ErrDegenerateInput
+	RejectDuplicates DuplicatePolicy = iota
+	// KeepFirstDuplicate - keep the position of the first of the sites and drop the rest
+	KeepFirstDuplicate
+	// AverageDuplicates - replace the sites with one at their mean position
+	AverageDuplicates
+)
+
+// validateInput rejects bounding boxes with no area and sites with non-finite coordinates
+func validateInput(siteList []site, boundingBox boundingBox) error {
+	if !isFinite(boundingBox.width) || !isFinite(boundingBox.height) ||
+		boundingBox.width <= 0 || boundingBox.height <= 0 {
+		return &InputError{Index: -1, Reason: "bounding box must have a finite, positive width and height",
+			Err: ErrInvalidInput}
+	}
+	if len(siteList) == 0 {
+		return &InputError{Index: -1, Reason: "no sites", Err: ErrDegenerateInput}
+	}
+	for i, site := range siteList {
+		if !isFinite(site.x) || !isFinite(site.y) {
+			return &InputError{Index: i, Site: site, Reason: "has a non-finite coordinate", Err: ErrInvalidInput}
+		}
+	}
+	return nil
+}
+
+// mergeDuplicateSites groups together sites which lie within tolerance of each other (a tolerance of zero
+// only groups identical sites). Each site is compared against the first site of the groups made so far, so
+// groups don't chain together. It returns the merged sites and, for each input site, the id of the merged
+// site it became part of.
+func mergeDuplicateSites(siteList []site, tolerance float64, policy DuplicatePolicy) ([]site, []SiteID, error) {
+	var merger siteMerger
+	return merger.merge(siteList, tolerance, policy)
+}
+
+// siteMerger - the working memory of mergeDuplicateSites, kept so a Builder can reuse it
+type siteMerger struct {
+	buckets      map[[2]float64][2]SiteID // The first and last group started in each bucket
+	nextInBucket []SiteID                 // The group started after this one in the same bucket, or -1
+	firstIndex   []int
+	sums         []site
+	counts       []int
+}
+
+func (merger *siteMerger) merge(siteList []site, tolerance float64, policy DuplicatePolicy) ([]site, []SiteID,
+	error) {
+	if !isFinite(tolerance) || tolerance < 0 {
+		return nil, nil, &InputError{Index: -1, Reason: "duplicate tolerance must be finite and not negative",
+			Err: ErrInvalidInput}
+	}
+
+	// Bucket the first site of each group into a grid of tolerance sized cells, so only the neighbouring
+	// cells need to be searched. With a zero tolerance the buckets hold exact positions.
+	bucketOf := func(x, y float64) [2]float64 {
+		if tolerance == 0 {
+			return [2]float64{x, y}
+		}
+		return [2]float64{math.Floor(x / tolerance), math.Floor(y / tolerance)}
+	}
+	if merger.buckets == nil {
+		merger.buckets = map[[2]float64][2]SiteID{}
+	}
+	clear(merger.buckets)
+	merger.nextInBucket = merger.nextInBucket[:0]
+	merger.firstIndex = merger.firstIndex[:0]
+	merger.sums = merger.sums[:0]
+	merger.counts = merger.counts[:0]
+	findGroup := func(site site) (SiteID, bool) {
+		bucket := bucketOf(site.x, site.y)
+		for dx := -1.0; dx <= 1; dx++ {
+			for dy := -1.0; dy <= 1; dy++ {
+				if tolerance == 0 && (dx != 0 || dy != 0) {
+					continue
+				}
+				groups, ok := merger.buckets[[2]float64{bucket[0] + dx, bucket[1] + dy}]
+				for id := groups[0]; ok && id >= 0; id = merger.nextInBucket[id] {
+					first := siteList[merger.firstIndex[id]]
+					if math.Hypot(site.x-first.x, site.y-first.y) <= tolerance {
+						return id, true
+					}
+				}
+			}
+		}
+		return 0, false
+	}
+
+	siteIDs := make([]SiteID, len(siteList))
+	for i, site := range siteList {
+		id, found := findGroup(site)
+		if !found {
+			id = SiteID(len(merger.firstIndex))
+			merger.firstIndex = append(merger.firstIndex, i)
+			merger.sums = append(merger.sums, site)
+			merger.counts = append(merger.counts, 1)
+			merger.nextInBucket = append(merger.nextInBucket, -1)
+			bucket := bucketOf(site.x, site.y)
+			if groups, ok := merger.buckets[bucket]; ok {
+				merger.nextInBucket[groups[1]] = id
+				merger.buckets[bucket] = [2]SiteID{groups[0], id}
+			} else {
+				merger.buckets[bucket] = [2]SiteID{id, id}
+			}
+		} else {
+			if policy == RejectDuplicates {
+				firstIndex := merger.firstIndex[id]
+				reason := fmt.Sprintf("duplicates site %d", firstIndex)
+				if tolerance > 0 {
+					reason = fmt.Sprintf("is within %v of site %d", tolerance, firstIndex)
+				}
+				return nil, nil, &InputError{Index: i, Site: site, Reason: reason, Err: ErrDegenerateInput}
+			}
+			merger.sums[id].x += site.x
+			merger.sums[id].y += site.y
+			merger.counts[id]++
+		}
+		siteIDs[i] = id
+	}
+
+	merged := make([]site, len(merger.firstIndex))
+	for id, index := range merger.firstIndex {
+		merged[id] = siteList[index]
+		if count := merger.counts[id]; policy == AverageDuplicates && count > 1 {
+			merged[id] = site{x: merger.sums[id].x / float64(count), y: merger.sums[id].y / float64(count)}
+		}
+	}
+	return merged, siteIDs, nil
+}
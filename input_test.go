@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMergeDuplicateSites(t *testing.T) {
+	siteList := []site{{x: 0, y: 0}, {x: 10, y: 0}, {x: 0.5, y: 0}, {x: 10, y: 0}, {x: 1.5, y: 0}}
+	tests := []struct {
+		name      string
+		tolerance float64
+		policy    DuplicatePolicy
+		want      []site
+		wantIDs   []SiteID
+	}{
+		{"exact keep first", 0, KeepFirstDuplicate,
+			[]site{{x: 0, y: 0}, {x: 10, y: 0}, {x: 0.5, y: 0}, {x: 1.5, y: 0}}, []SiteID{0, 1, 2, 1, 3}},
+		{"tolerance keep first", 1, KeepFirstDuplicate,
+			[]site{{x: 0, y: 0}, {x: 10, y: 0}, {x: 1.5, y: 0}}, []SiteID{0, 1, 0, 1, 2}},
+		{"tolerance average", 1, AverageDuplicates,
+			[]site{{x: 0.25, y: 0}, {x: 10, y: 0}, {x: 1.5, y: 0}}, []SiteID{0, 1, 0, 1, 2}},
+	}
+	for _, test := range tests {
+		merged, siteIDs, err := mergeDuplicateSites(siteList, test.tolerance, test.policy)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(merged, test.want) || !reflect.DeepEqual(siteIDs, test.wantIDs) {
+			t.Errorf("%s: got %v %v, want %v %v", test.name, merged, siteIDs, test.want, test.wantIDs)
+		}
+	}
+
+	_, _, err := mergeDuplicateSites(siteList, 1, RejectDuplicates)
+	var inputError *InputError
+	if !errors.Is(err, ErrDegenerateInput) || !errors.As(err, &inputError) || inputError.Index != 2 {
+		t.Errorf("reject: got %v, want site 2 rejected", err)
+	}
+}
+
+func TestComputeMergesDuplicates(t *testing.T) {
+	siteList := []site{{x: 10, y: 10}, {x: 50, y: 60}, {x: 10, y: 10}, {x: 80, y: 20}}
+	diagram, err := Compute(siteList, boundingBox{height: 100, width: 100},
+		Options{DuplicatePolicy: KeepFirstDuplicate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []SiteID{0, 1, 0, 2}; !reflect.DeepEqual(diagram.SiteIDs(), want) {
+		t.Errorf("got site ids %v, want %v", diagram.SiteIDs(), want)
+	}
+	checkEdgesAreBisectors(t, diagram.dcel, diagram.Sites())
+}
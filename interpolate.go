@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// NaturalNeighbour - a site whose cell would lose some of its area to a point inserted into the diagram,
+// and the share of the point's cell it would lose (its Sibson coordinate)
+type NaturalNeighbour struct {
+	ID     SiteID
+	Weight float64
+}
+
+// NaturalNeighbours returns the natural neighbours of p in order of id, weighted by how much of the cell p
+// would take from each if it were inserted. The weights add up to one. Cells are clipped to the bounding
+// box, so near the sides of the box the weights describe the clipped cells. The returned error wraps
+// ErrInvalidInput if p is outside the bounding box, or the diagram is not euclidean, is weighted or
+// anisotropic, or wraps around.
+func (diagram *Diagram) NaturalNeighbours(p vertex) ([]NaturalNeighbour, error) {
+	if diagram.metric != Euclidean {
+		return nil, &InputError{Index: -1, Reason: fmt.Sprintf("natural neighbours need a euclidean diagram, not %v",
+			diagram.metric), Err: ErrInvalidInput}
+	}
+	if diagram.period != (vertex{}) {
+		return nil, &InputError{Index: -1, Reason: "natural neighbours need a bounding box which does not wrap",
+			Err: ErrInvalidInput}
+	}
+	if diagram.weighting != Unweighted {
+		return nil, &InputError{Index: -1, Reason: fmt.Sprintf("natural neighbours need an unweighted diagram, "+
+			"not %v", diagram.weighting), Err: ErrInvalidInput}
+	}
+	if diagram.anisotropy != nil {
+		return nil, &InputError{Index: -1, Reason: "natural neighbours need an isotropic diagram",
+			Err: ErrInvalidInput}
+	}
+	id := diagram.Locate(p)
+	if id < 0 {
+		return nil, &InputError{Index: -1, Reason: fmt.Sprintf("(%v, %v) is outside the bounding box", p.x, p.y),
+			Err: ErrInvalidInput}
+	}
+	if diagram.sites[id] == site(p) {
+		return []NaturalNeighbour{{ID: id, Weight: 1}}, nil
+	}
+	diagram.cellsOnce.Do(func() {
+		diagram.cells, diagram.cellNeighbours = diagram.cellPolygons(), diagram.dcelNeighbours()
+	})
+
+	// The cell p would have is the box cut down by the bisectors with its natural neighbours, which are
+	// joined to each other in the delaunay triangulation. Starting from the cell p is in, the neighbours of
+	// every site across a side of p's cell are tried until no more sides appear.
+	query := site(p)
+	isCandidate, expanded := map[SiteID]bool{id: true}, map[SiteID]bool{id: true}
+	candidates := []SiteID{id}
+	var polygon []cellCorner
+	for expand := []SiteID{id}; len(expand) > 0; {
+		for _, across := range expand {
+			for _, neighbour := range diagram.cellNeighbours[across] {
+				if !isCandidate[neighbour] {
+					isCandidate[neighbour] = true
+					candidates = append(candidates, neighbour)
+				}
+			}
+		}
+		box := diagram.boundingBox
+		polygon = []cellCorner{{vertex{0, 0}, -1}, {vertex{box.width, 0}, -1},
+			{vertex{box.width, box.height}, -1}, {vertex{0, box.height}, -1}}
+		for _, candidate := range candidates {
+			polygon = clipToBisector(polygon, &query, &diagram.sites[candidate], candidate)
+		}
+		expand = expand[:0]
+		for _, corner := range polygon {
+			if corner.across >= 0 && !expanded[corner.across] {
+				expanded[corner.across] = true
+				expand = append(expand, corner.across)
+			}
+		}
+	}
+
+	// Each neighbour loses the part of its cell closer to p than to its own site
+	var neighbours []NaturalNeighbour
+	seen := map[SiteID]bool{}
+	total := 0.0
+	for _, corner := range polygon {
+		if corner.across < 0 || seen[corner.across] {
+			continue
+		}
+		seen[corner.across] = true
+		stolen := polygonArea(clipToBisector(diagram.cells[corner.across], &query,
+			&diagram.sites[corner.across], -1))
+		if stolen > 0 {
+			neighbours = append(neighbours, NaturalNeighbour{ID: corner.across, Weight: stolen})
+			total += stolen
+		}
+	}
+	sort.Slice(neighbours, func(i, j int) bool { return neighbours[i].ID < neighbours[j].ID })
+	for k := range neighbours {
+		neighbours[k].Weight /= total
+	}
+	return neighbours, nil
+}
+
+// Interpolate returns the natural neighbour (Sibson) interpolation at p of values given at each site,
+// indexed by SiteID. Returned errors wrap ErrInvalidInput if p is outside the bounding box or there is not
+// one value per site.
+func (diagram *Diagram) Interpolate(p vertex, values []float64) (float64, error) {
+	if len(values) != len(diagram.sites) {
+		return 0, &InputError{Index: -1, Reason: fmt.Sprintf("got %d values for %d sites", len(values),
+			len(diagram.sites)), Err: ErrInvalidInput}
+	}
+	neighbours, err := diagram.NaturalNeighbours(p)
+	if err != nil {
+		return 0, err
+	}
+	value := 0.0
+	for _, neighbour := range neighbours {
+		value += neighbour.Weight * values[neighbour.ID]
+	}
+	return value, nil
+}
+
+// InterpolateGrid samples the interpolation of values at the centres of a grid of columns by rows cells
+// covering the bounding box. Rows run from the top of the box down, as in an image.
+func (diagram *Diagram) InterpolateGrid(values []float64, columns, rows int) ([][]float64, error) {
+	if columns <= 0 || rows <= 0 {
+		return nil, &InputError{Index: -1, Reason: fmt.Sprintf("grid of %d by %d has no cells", columns, rows),
+			Err: ErrInvalidInput}
+	}
+	box := diagram.boundingBox
+	grid := make([][]float64, rows)
+	for j := range grid {
+		grid[j] = make([]float64, columns)
+		y := box.height - (float64(j)+0.5)*box.height/float64(rows)
+		for i := range grid[j] {
+			x := (float64(i) + 0.5) * box.width / float64(columns)
+			value, err := diagram.Interpolate(vertex{x: x, y: y}, values)
+			if err != nil {
+				return nil, err
+			}
+			grid[j][i] = value
+		}
+	}
+	return grid, nil
+}
+
+// RenderInterpolation rasterizes the interpolation of values into a greyscale image of columns by rows
+// pixels, black at the lowest value sampled and white at the highest
+func (diagram *Diagram) RenderInterpolation(values []float64, columns, rows int) (*image.Gray16, error) {
+	grid, err := diagram.InterpolateGrid(values, columns, rows)
+	if err != nil {
+		return nil, err
+	}
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, row := range grid {
+		for _, value := range row {
+			low, high = math.Min(low, value), math.Max(high, value)
+		}
+	}
+	img := image.NewGray16(image.Rect(0, 0, columns, rows))
+	for j, row := range grid {
+		for i, value := range row {
+			shade := 0.0
+			if high > low {
+				shade = (value - low) / (high - low)
+			}
+			img.SetGray16(i, j, color.Gray16{Y: uint16(math.Round(shade * math.MaxUint16))})
+		}
+	}
+	return img, nil
+}
+
+// polygonArea - the area of an anticlockwise polygon (shoelace formula)
+func polygonArea(polygon []cellCorner) float64 {
+	twiceArea := 0.0
+	for k, corner := range polygon {
+		next := polygon[(k+1)%len(polygon)]
+		twiceArea += corner.x*next.y - next.x*corner.y
+	}
+	return twiceArea / 2
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	siteList := uniformSites(500, rand.New(rand.NewSource(1)))
+	diagram, err := Compute(siteList, benchmarkBox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linear := func(p vertex) float64 { return 2*p.x - 3*p.y + 7 }
+	values := make([]float64, len(diagram.sites))
+	for id, s := range diagram.sites {
+		values[id] = linear(vertex(s))
+	}
+
+	source := rand.New(rand.NewSource(2))
+	for k := 0; k < 200; k++ {
+		p := vertex{x: 200 + source.Float64()*600, y: 200 + source.Float64()*600}
+		neighbours, err := diagram.NaturalNeighbours(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := 0.0
+		for _, neighbour := range neighbours {
+			total += neighbour.Weight
+		}
+		if len(neighbours) < 3 || !nearlyEqual(total, 1) {
+			t.Fatalf("(%v, %v): got natural neighbours %v", p.x, p.y, neighbours)
+		}
+		// Away from the sides of the box the interpolation reproduces linear functions exactly
+		if value, err := diagram.Interpolate(p, values); err != nil || math.Abs(value-linear(p)) > 1e-6 {
+			t.Fatalf("(%v, %v): interpolated %v (%v), want %v", p.x, p.y, value, err, linear(p))
+		}
+	}
+
+	// At a site the interpolation is the site's value
+	if value, err := diagram.Interpolate(vertex(diagram.sites[7]), values); err != nil || value != values[7] {
+		t.Errorf("at site 7: interpolated %v (%v), want %v", value, err, values[7])
+	}
+	if _, err := diagram.Interpolate(vertex{x: -1, y: 10}, values); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("interpolating outside the box returned %v, want ErrInvalidInput", err)
+	}
+	if _, err := diagram.Interpolate(vertex{x: 1, y: 10}, values[1:]); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("interpolating too few values returned %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestRenderInterpolation(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	diagram, err := Compute([]site{{x: 20, y: 20}, {x: 80, y: 30}, {x: 50, y: 80}}, box, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []float64{0, 10, 20}
+	img, err := diagram.RenderInterpolation(values, 40, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 40 || bounds.Dy() != 20 {
+		t.Fatalf("got image %v, want 40 by 20", bounds)
+	}
+	// The top of the image is the top of the box, nearest the site with the highest value
+	if top, bottom := img.Gray16At(20, 0).Y, img.Gray16At(4, 19).Y; top <= bottom {
+		t.Errorf("top of the image is %d, bottom left is %d", top, bottom)
+	}
+	if _, err := diagram.InterpolateGrid(values, 0, 20); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("interpolating an empty grid returned %v, want ErrInvalidInput", err)
+	}
+}
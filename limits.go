@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Limits - bounds on the work done computing a diagram. Zero means no limit.
+type Limits struct {
+	MaxSites    int // Input sites, counted before duplicates are merged
+	MaxEvents   int // Site and circle events handled by the sweep
+	MaxDCELSize int // Vertices plus half-edges in the diagram
+}
+
+// contextCheckInterval - the number of events handled between checks of whether the context is done
+const contextCheckInterval = 256
+
+// sweepLimits - the context and limits a sweep checks as it goes. The event count is shared by the sweeps
+// of the strips in a parallel sweep. A nil sweepLimits never stops a sweep.
+type sweepLimits struct {
+	ctx    context.Context
+	limits Limits
+	events atomic.Int64
+}
+
+func newSweepLimits(ctx context.Context, limits Limits) *sweepLimits {
+	return &sweepLimits{ctx: ctx, limits: limits}
+}
+
+// checkSites - fail if there are more input sites than allowed
+func (limits *sweepLimits) checkSites(siteList []site) error {
+	if limits == nil || limits.limits.MaxSites <= 0 || len(siteList) <= limits.limits.MaxSites {
+		return nil
+	}
+	return &LimitError{Limit: "sites", Max: limits.limits.MaxSites, Err: ErrLimitExceeded}
+}
+
+// checkEvent - count an event about to be handled, failing if that is more than allowed, the dcel has grown
+// too large or the context is done
+func (limits *sweepLimits) checkEvent(dcel *doublyConnectedEdgeList) error {
+	if limits == nil {
+		return nil
+	}
+	events := limits.events.Add(1)
+	if limits.limits.MaxEvents > 0 && events > int64(limits.limits.MaxEvents) {
+		return &LimitError{Limit: "events", Max: limits.limits.MaxEvents, Err: ErrLimitExceeded}
+	}
+	if err := limits.checkDCEL(dcel); err != nil {
+		return err
+	}
+	if events%contextCheckInterval == 0 {
+		return limits.ctx.Err()
+	}
+	return nil
+}
+
+// checkDCEL - fail if the dcel has grown too large
+func (limits *sweepLimits) checkDCEL(dcel *doublyConnectedEdgeList) error {
+	if limits == nil || limits.limits.MaxDCELSize <= 0 ||
+		len(dcel.vertices)+len(dcel.edges) <= limits.limits.MaxDCELSize {
+		return nil
+	}
+	return &LimitError{Limit: "dcel elements", Max: limits.limits.MaxDCELSize, Err: ErrLimitExceeded}
+}
+
+// restart - start counting events again, for a sweep which replaces the ones already done
+func (limits *sweepLimits) restart() {
+	if limits != nil {
+		limits.events.Store(0)
+	}
+}
+
+// checkContext - fail if the context is done
+func (limits *sweepLimits) checkContext() error {
+	if limits == nil {
+		return nil
+	}
+	return limits.ctx.Err()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestComputeContextCancelled(t *testing.T) {
+	siteList := uniformSites(100000, rand.New(rand.NewSource(1)))
+	for _, parallelism := range []int{0, 4} {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := ComputeContext(ctx, siteList[:10], benchmarkBox, Options{Parallelism: parallelism})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("parallelism %d: got %v, want context.Canceled", parallelism, err)
+		}
+
+		// The deadline passes part way through the sweep
+		ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+		_, err = ComputeContext(ctx, siteList, benchmarkBox, Options{Parallelism: parallelism})
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("parallelism %d: got %v, want context.DeadlineExceeded", parallelism, err)
+		}
+	}
+}
+
+func TestComputeLimits(t *testing.T) {
+	siteList := uniformSites(1000, rand.New(rand.NewSource(1)))
+	tests := []struct {
+		limits Limits
+		want   string // The limit exceeded, or empty for none
+	}{
+		{Limits{MaxSites: 999}, "sites"},
+		{Limits{MaxEvents: 1500}, "events"},
+		{Limits{MaxDCELSize: 2000}, "dcel elements"},
+		{Limits{MaxSites: 1000, MaxEvents: 5000, MaxDCELSize: 10000}, ""},
+	}
+	for _, test := range tests {
+		for _, parallelism := range []int{0, 4} {
+			_, err := Compute(siteList, benchmarkBox, Options{Limits: test.limits, Parallelism: parallelism})
+			var limitError *LimitError
+			switch {
+			case test.want == "" && err != nil:
+				t.Errorf("%+v parallelism %d: %v", test.limits, parallelism, err)
+			case test.want != "" && (!errors.Is(err, ErrLimitExceeded) || !errors.As(err, &limitError) ||
+				limitError.Limit != test.want):
+				t.Errorf("%+v parallelism %d: got %v, want %s limit exceeded", test.limits, parallelism, err,
+					test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// locator - a trapezoidal map of the edges of a diagram, with the search structure built alongside it, for
+// finding which cell a point lies in (de Berg et al. chapter 6). Edges are inserted in random order, giving
+// O(n) expected size and O(log n) expected query time. Points with the same x are ordered by y, which is
+// the same as shearing the plane very slightly so that no two points share an x coordinate.
+type locator struct {
+	root        *searchNode
+	boundingBox boundingBox
+	sites       []site
+	metric      Metric
+	period      vertex
+	weighting   Weighting
+	weights     []float64
+	cells       *siteIndex // Of a weighted diagram, searched in place of the trapezoidal map
+	anisotropy  *anisotropy
+}
+
+// mapSegment - an edge of the diagram clipped to the bounding box, from its left end p to its right end q,
+// with the sites of the cells either side
+type mapSegment struct {
+	p, q  vertex
+	sites [2]SiteID
+}
+
+// trapezoid - a face of the trapezoidal map, between the segments top and bottom (nil for the sides of the
+// bounding box) and the vertical lines through leftp and rightp. Neighbours share its left or right side -
+// the upper ones share its top and the lower ones its bottom. The trapezoid lies in the cell of one of
+// its candidate sites.
+type trapezoid struct {
+	top, bottom            *mapSegment
+	leftp, rightp          vertex
+	upperLeft, lowerLeft   *trapezoid
+	upperRight, lowerRight *trapezoid
+	leaf                   *searchNode
+	candidates             []SiteID
+}
+
+// searchNode - a node of the search structure. X nodes send points left or right of their point, y nodes
+// send points above their segment left and points below right, and leaves hold a trapezoid.
+type searchNode struct {
+	point       vertex
+	segment     *mapSegment
+	trapezoid   *trapezoid
+	left, right *searchNode
+}
+
+// Locate returns the id of the site whose cell contains p, or -1 if p lies outside the bounding box. The
+// search structure is built the first time it is called, after which it is safe to call concurrently.
+func (diagram *Diagram) Locate(p vertex) SiteID {
+	diagram.locatorOnce.Do(func() {
+		diagram.locator = newLocator(diagram)
+	})
+	return diagram.locator.locate(p)
+}
+
+func newLocator(diagram *Diagram) *locator {
+	box := diagram.boundingBox
+	if diagram.weighting != Unweighted {
+		return &locator{boundingBox: box, sites: diagram.sites, weighting: diagram.weighting,
+			weights: diagram.weights, cells: newCellIndex(diagram)}
+	}
+	segments := clippedSegments(diagram)
+
+	// The first trapezoid is everything, bounded above and below by the sides of the box
+	everything := &trapezoid{leftp: vertex{x: math.Inf(-1)}, rightp: vertex{x: math.Inf(1)}}
+	locator := &locator{root: &searchNode{trapezoid: everything}, boundingBox: box, sites: diagram.sites,
+		metric: diagram.metric, period: diagram.period, anisotropy: diagram.anisotropy}
+	everything.leaf = locator.root
+	shuffle := rand.New(rand.NewSource(1))
+	shuffle.Shuffle(len(segments), func(i, j int) { segments[i], segments[j] = segments[j], segments[i] })
+	for _, segment := range segments {
+		locator.insert(segment)
+	}
+
+	// Each trapezoid lies in a cell either side of its top or bottom segment, or if it has neither in one of
+	// the cells meeting at the points that bound it on the left and right. Points on the vertical lines
+	// through those points belong to the trapezoids left of them, so they need the cells at both too.
+	cellsAt := map[vertex][]SiteID{}
+	for _, segment := range segments {
+		cellsAt[segment.p] = append(cellsAt[segment.p], segment.sites[:]...)
+		cellsAt[segment.q] = append(cellsAt[segment.q], segment.sites[:]...)
+	}
+	locator.forEachTrapezoid(func(trapezoid *trapezoid) {
+		for _, segment := range []*mapSegment{trapezoid.top, trapezoid.bottom} {
+			if segment != nil {
+				trapezoid.candidates = append(trapezoid.candidates, segment.sites[:]...)
+			}
+		}
+		trapezoid.candidates = append(append(trapezoid.candidates, cellsAt[trapezoid.leftp]...),
+			cellsAt[trapezoid.rightp]...)
+		if len(trapezoid.candidates) == 0 {
+			// There are no edges in the box, so one cell covers all of it
+			centre := vertex{x: box.width / 2, y: box.height / 2}
+			trapezoid.candidates = []SiteID{locator.nearest(nil, centre)}
+		}
+	})
+	return locator
+}
+
+// clippedSegments returns the edges of a diagram clipped to the bounding box, left end first, leaving out
+// any of zero length and any which overlap another
+func clippedSegments(diagram *Diagram) []*mapSegment {
+	box := diagram.boundingBox
+	ids := make(map[*site]SiteID, len(diagram.sites))
+	for i := range diagram.sites {
+		ids[&diagram.sites[i]] = SiteID(i)
+	}
+
+	// Half-edges are added to the dcel in twin pairs
+	snap := newPointSnapper(1e-9 * math.Max(box.width, box.height))
+	seen := map[[2]vertex]bool{}
+	var segments []*mapSegment
+	for k := 0; k+1 < len(diagram.dcel.edges); k += 2 {
+		halfEdge := diagram.dcel.edges[k]
+		start, end := *halfEdge.originVertex, *halfEdge.twinEdge.originVertex
+		dx, dy := end.x-start.x, end.y-start.y
+		tEnter, tExit, ok := box.clipLine(start.x, start.y, dx, dy)
+		if !ok || tEnter >= 1 || tExit <= 0 || (dx == 0 && dy == 0) {
+			continue
+		}
+		// Clipped ends are put exactly on the sides of the box
+		origin := start
+		if tEnter > 0 {
+			start = box.clamp(vertex{x: origin.x + tEnter*dx, y: origin.y + tEnter*dy})
+		}
+		if tExit < 1 {
+			end = box.clamp(vertex{x: origin.x + tExit*dx, y: origin.y + tExit*dy})
+		}
+		// Where more than three cells meet the sweep can leave several vertices a rounding error apart, with
+		// edges between them that overlap, and edges which should end on the sides of the box can stop just
+		// short of them. Ends that close together are made the same point.
+		start, end = snap.point(snap.toSides(box, start)), snap.point(snap.toSides(box, end))
+		if pointLeftOf(end, start) {
+			start, end = end, start
+		}
+		if start == end || seen[[2]vertex{start, end}] {
+			continue
+		}
+		seen[[2]vertex{start, end}] = true
+		segments = append(segments, &mapSegment{p: start, q: end,
+			sites: [2]SiteID{ids[halfEdge.site], ids[halfEdge.twinEdge.site]}})
+	}
+	return segments
+}
+
+func (locator *locator) locate(p vertex) SiteID {
+	if !(p.x >= 0 && p.x <= locator.boundingBox.width && p.y >= 0 && p.y <= locator.boundingBox.height) {
+		return -1
+	}
+	if locator.cells != nil {
+		column, row := locator.cells.bucket(p)
+		return locator.nearest(locator.cells.buckets[row*locator.cells.columns+column], p)
+	}
+	return locator.nearest(locator.find(p, p).candidates, p)
+}
+
+// nearest returns whichever of the candidate sites (or all of the sites if there are no candidates) is
+// nearest p, under the weighting or anisotropy of the diagram if it has one
+func (locator *locator) nearest(candidates []SiteID, p vertex) SiteID {
+	if locator.weighting != Unweighted {
+		return nearestWeightedSite(locator.weighting, locator.sites, locator.weights, candidates, p)
+	}
+	if locator.anisotropy != nil {
+		return locator.anisotropy.nearest(locator.sites, candidates, p)
+	}
+	return nearestSite(locator.metric, locator.period, locator.sites, candidates, p)
+}
+
+// nearestSite returns whichever of the candidate sites (or all of the sites if there are no candidates) is
+// closest to p under the metric, going around the bounding box if it wraps with the given period
+func nearestSite(metric Metric, period vertex, siteList []site, candidates []SiteID, p vertex) SiteID {
+	nearest := SiteID(-1)
+	consider := func(id SiteID) {
+		if nearest < 0 || metric.closer(p, nearestImage(p, vertex(siteList[id]), period),
+			nearestImage(p, vertex(siteList[nearest]), period)) {
+			nearest = id
+		}
+	}
+	if candidates == nil {
+		for id := range siteList {
+			consider(SiteID(id))
+		}
+	}
+	for _, id := range candidates {
+		consider(id)
+	}
+	return nearest
+}
+
+// find returns the trapezoid containing p. When p lies on a segment (as the left end of a segment being
+// inserted may) the trapezoid is the one q is on the same side of.
+func (locator *locator) find(p, q vertex) *trapezoid {
+	node := locator.root
+	for node.trapezoid == nil {
+		if node.segment == nil {
+			if pointLeftOf(p, node.point) {
+				node = node.left
+			} else {
+				node = node.right
+			}
+			continue
+		}
+		side := cross(node.segment.p, node.segment.q, p)
+		if side == 0 {
+			side = cross(node.segment.p, node.segment.q, q)
+		}
+		if side > 0 {
+			node = node.left
+		} else {
+			node = node.right
+		}
+	}
+	return node.trapezoid
+}
+
+// insert adds a segment to the map. The trapezoids it crosses are split into the parts above and below
+// it, and the parts which are no longer separated by a vertical line are merged.
+func (locator *locator) insert(segment *mapSegment) {
+	crossed := []*trapezoid{locator.find(segment.p, segment.q)}
+	for last := crossed[0]; pointLeftOf(last.rightp, segment.q); last = crossed[len(crossed)-1] {
+		if cross(segment.p, segment.q, last.rightp) > 0 {
+			crossed = append(crossed, last.lowerRight)
+		} else {
+			crossed = append(crossed, last.upperRight)
+		}
+	}
+
+	first, final := crossed[0], crossed[len(crossed)-1]
+	upper := &trapezoid{top: first.top, bottom: segment, leftp: segment.p}
+	lower := &trapezoid{top: segment, bottom: first.bottom, leftp: segment.p}
+	uppers, lowers := []*trapezoid{upper}, []*trapezoid{lower}
+
+	// The part of the first trapezoid left of the segment
+	var left *trapezoid
+	if first.leftp != segment.p {
+		left = &trapezoid{top: first.top, bottom: first.bottom, leftp: first.leftp, rightp: segment.p,
+			upperLeft: first.upperLeft, lowerLeft: first.lowerLeft, upperRight: upper, lowerRight: lower}
+		replaceRightNeighbour(first.upperLeft, first, left)
+		replaceRightNeighbour(first.lowerLeft, first, left)
+		upper.upperLeft, lower.lowerLeft = left, left
+	} else {
+		upper.upperLeft, lower.lowerLeft = first.upperLeft, first.lowerLeft
+		replaceRightNeighbour(first.upperLeft, first, upper)
+		replaceRightNeighbour(first.lowerLeft, first, lower)
+	}
+
+	// Between each pair of crossed trapezoids the vertical line through the right point of the first is
+	// cut by the segment. On the side of the segment the point is on it still separates two trapezoids,
+	// on the other side the trapezoids either side of it merge.
+	for j := 0; j+1 < len(crossed); j++ {
+		current, next, point := crossed[j], crossed[j+1], crossed[j].rightp
+		if cross(segment.p, segment.q, point) > 0 {
+			upper.rightp = point
+			nextUpper := &trapezoid{top: next.top, bottom: segment, leftp: point, upperLeft: next.upperLeft,
+				lowerLeft: upper}
+			replaceRightNeighbour(next.upperLeft, next, nextUpper)
+			upper.lowerRight = nextUpper
+			if current.upperRight != next {
+				upper.upperRight = current.upperRight
+				replaceLeftNeighbour(current.upperRight, current, upper)
+			}
+			upper = nextUpper
+		} else {
+			lower.rightp = point
+			nextLower := &trapezoid{top: segment, bottom: next.bottom, leftp: point, lowerLeft: next.lowerLeft,
+				upperLeft: lower}
+			replaceRightNeighbour(next.lowerLeft, next, nextLower)
+			lower.upperRight = nextLower
+			if current.lowerRight != next {
+				lower.lowerRight = current.lowerRight
+				replaceLeftNeighbour(current.lowerRight, current, lower)
+			}
+			lower = nextLower
+		}
+		uppers = append(uppers, upper)
+		lowers = append(lowers, lower)
+	}
+	upper.rightp, lower.rightp = segment.q, segment.q
+
+	// The part of the last trapezoid right of the segment
+	var right *trapezoid
+	if final.rightp != segment.q {
+		right = &trapezoid{top: final.top, bottom: final.bottom, leftp: segment.q, rightp: final.rightp,
+			upperRight: final.upperRight, lowerRight: final.lowerRight, upperLeft: upper, lowerLeft: lower}
+		replaceLeftNeighbour(final.upperRight, final, right)
+		replaceLeftNeighbour(final.lowerRight, final, right)
+		upper.upperRight, lower.lowerRight = right, right
+	} else {
+		upper.upperRight, lower.lowerRight = final.upperRight, final.lowerRight
+		replaceLeftNeighbour(final.upperRight, final, upper)
+		replaceLeftNeighbour(final.lowerRight, final, lower)
+	}
+
+	// Replace the leaves of the crossed trapezoids with nodes splitting them by the segment (and the
+	// ends of the segment for the first and last)
+	leafOf := func(trapezoid *trapezoid) *searchNode {
+		if trapezoid.leaf == nil {
+			trapezoid.leaf = &searchNode{trapezoid: trapezoid}
+		}
+		return trapezoid.leaf
+	}
+	for j, trapezoid := range crossed {
+		node := &searchNode{segment: segment, left: leafOf(uppers[j]), right: leafOf(lowers[j])}
+		if j == len(crossed)-1 && right != nil {
+			node = &searchNode{point: segment.q, left: node, right: leafOf(right)}
+		}
+		if j == 0 && left != nil {
+			node = &searchNode{point: segment.p, left: leafOf(left), right: node}
+		}
+		*trapezoid.leaf = *node
+	}
+}
+
+func replaceRightNeighbour(trapezoid, old, replacement *trapezoid) {
+	if trapezoid == nil {
+		return
+	}
+	if trapezoid.upperRight == old {
+		trapezoid.upperRight = replacement
+	}
+	if trapezoid.lowerRight == old {
+		trapezoid.lowerRight = replacement
+	}
+}
+
+func replaceLeftNeighbour(trapezoid, old, replacement *trapezoid) {
+	if trapezoid == nil {
+		return
+	}
+	if trapezoid.upperLeft == old {
+		trapezoid.upperLeft = replacement
+	}
+	if trapezoid.lowerLeft == old {
+		trapezoid.lowerLeft = replacement
+	}
+}
+
+// forEachTrapezoid calls visit once for each trapezoid in the map
+func (locator *locator) forEachTrapezoid(visit func(*trapezoid)) {
+	seen := map[*searchNode]bool{}
+	stack := []*searchNode{locator.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+		if node.trapezoid != nil {
+			visit(node.trapezoid)
+		} else {
+			stack = append(stack, node.left, node.right)
+		}
+	}
+}
+
+// pointSnapper - replaces each point with the first point it was given within tolerance of it
+type pointSnapper struct {
+	tolerance float64
+	cells     map[[2]int64][]vertex
+}
+
+func newPointSnapper(tolerance float64) *pointSnapper {
+	return &pointSnapper{tolerance: tolerance, cells: map[[2]int64][]vertex{}}
+}
+
+func (snapper *pointSnapper) point(p vertex) vertex {
+	cellX, cellY := int64(math.Floor(p.x/snapper.tolerance)), int64(math.Floor(p.y/snapper.tolerance))
+	for i := cellX - 1; i <= cellX+1; i++ {
+		for j := cellY - 1; j <= cellY+1; j++ {
+			for _, q := range snapper.cells[[2]int64{i, j}] {
+				if math.Abs(p.x-q.x) <= snapper.tolerance && math.Abs(p.y-q.y) <= snapper.tolerance {
+					return q
+				}
+			}
+		}
+	}
+	snapper.cells[[2]int64{cellX, cellY}] = append(snapper.cells[[2]int64{cellX, cellY}], p)
+	return p
+}
+
+// toSides moves p onto any side of the box it is within tolerance of
+func (snapper *pointSnapper) toSides(box boundingBox, p vertex) vertex {
+	snapTo := func(value, side float64) float64 {
+		if math.Abs(value-side) <= snapper.tolerance {
+			return side
+		}
+		return value
+	}
+	return vertex{x: snapTo(snapTo(p.x, 0), box.width), y: snapTo(snapTo(p.y, 0), box.height)}
+}
+
+// pointLeftOf - the order of points in the trapezoidal map
+func pointLeftOf(a, b vertex) bool {
+	return a.x < b.x || (a.x == b.x && a.y < b.y)
+}
+
+// cross - positive when c is to the left of the line from a to b, negative when it is to the right
+func cross(a, b, c vertex) float64 {
+	return (b.x-a.x)*(c.y-a.y) - (b.y-a.y)*(c.x-a.x)
+}
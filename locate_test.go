@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestLocate(t *testing.T) {
+	source := rand.New(rand.NewSource(5))
+	for _, distribution := range siteDistributions {
+		for _, parallelism := range []int{1, 4} {
+			siteList := distribution.generate(2000, rand.New(rand.NewSource(1)))
+			diagram, err := Compute(siteList, benchmarkBox, Options{Parallelism: parallelism})
+			if err != nil {
+				t.Fatalf("%s: %v", distribution.name, err)
+			}
+			queries := make([]vertex, 2000)
+			for i := range queries {
+				queries[i] = vertex{x: source.Float64() * benchmarkBox.width, y: source.Float64() * benchmarkBox.height}
+			}
+			// Points on the sides and at the corners of the box
+			queries = append(queries, vertex{}, vertex{x: benchmarkBox.width, y: benchmarkBox.height},
+				vertex{x: benchmarkBox.width / 3}, vertex{x: benchmarkBox.width, y: benchmarkBox.height / 3})
+			for _, p := range queries {
+				// Brute force, allowing for points on a cell boundary
+				got, want := diagram.Locate(p), nearestSite(Euclidean, vertex{}, diagram.sites, nil, p)
+				if got < 0 || distanceTo(diagram.sites[got], p)-distanceTo(diagram.sites[want], p) > 1e-9 {
+					t.Fatalf("%s, parallelism %d: Locate(%v) = %d, want %d", distribution.name, parallelism, p,
+						got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestLocateFewSites(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	for _, siteList := range [][]site{
+		{{x: 30, y: 50}, {x: 70, y: 50}},                 // One vertical edge
+		{{x: 50, y: 30}, {x: 50, y: 70}},                 // One horizontal edge
+		{{x: 10, y: 10}, {x: 20, y: 10}, {x: 30, y: 10}}, // Parallel vertical edges
+		{{x: 50, y: 50}, {x: 500, y: 500}},               // No edges inside the box
+	} {
+		diagram, err := Compute(siteList, box, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for x := 0.5; x < 100; x += 7 {
+			for y := 0.5; y < 100; y += 7 {
+				p := vertex{x: x, y: y}
+				got, want := diagram.Locate(p), nearestSite(Euclidean, vertex{}, diagram.sites, nil, p)
+				if got != want {
+					t.Fatalf("sites %v: Locate(%v) = %d, want %d", siteList, p, got, want)
+				}
+			}
+		}
+		if got := diagram.Locate(vertex{x: -1, y: 50}); got != -1 {
+			t.Errorf("sites %v: Locate outside the box = %d, want -1", siteList, got)
+		}
+	}
+}
+
+func distanceTo(s site, p vertex) float64 {
+	return math.Hypot(s.x-p.x, s.y-p.y)
+}
+
+func BenchmarkLocate(b *testing.B) {
+	for _, size := range []int{1e3, 1e5} {
+		diagram, err := Compute(uniformSites(size, rand.New(rand.NewSource(1))), benchmarkBox, Options{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		diagram.Locate(vertex{})
+		source := rand.New(rand.NewSource(2))
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				diagram.Locate(vertex{x: source.Float64() * benchmarkBox.width, y: source.Float64() * benchmarkBox.height})
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// MedialAxis - the medial axis of a polygon, the centres of the circles inside it which touch its outline in
+// two or more places, as a graph. Its nodes are the ends and junctions of the axis, joined by branches.
+type MedialAxis struct {
+	Nodes    []MedialPoint
+	Branches []MedialBranch
+}
+
+// MedialPoint - a point of the medial axis, with the radius of the largest circle centred there inside the
+// polygon
+type MedialPoint struct {
+	Point  vertex
+	Radius float64
+}
+
+// MedialBranch - a polyline of the medial axis between two of its nodes, including them both. A branch which
+// loops back to where it started has the same node at both ends.
+type MedialBranch struct {
+	From, To int
+	Points   []MedialPoint
+}
+
+// medialSegment - an edge of the diagram of the points along the outline which is kept in the axis
+type medialSegment struct {
+	p, q vertex
+}
+
+// ComputeMedialAxis approximates the medial axis of a polygon from the voronoi diagram of points no further
+// apart than spacing along its outline, keeping the edges which lie wholly inside it. Every edge lies between
+// two of the points, and is pruned if they are less than stability apart going round the outline. This cuts
+// the axis back from the corners, where it comes from points just either side of a corner, and removes the
+// branches running into wiggles of the outline smaller than stability. Parallelism and Limits in the options
+// apply to the sweep, with the points checked against MaxSites before they are placed as for ComputeShapes;
+// the metric must be plain euclidean and the box may not wrap around. Returned errors wrap ErrInvalidInput or
+// ErrDegenerateInput if the input is rejected, and otherwise are those of Compute.
+func ComputeMedialAxis(polygon Shape, spacing, stability float64, options Options) (*MedialAxis, error) {
+	if !isFinite(spacing) || spacing <= 0 || !isFinite(stability) || stability < 0 {
+		return nil, &InputError{Index: -1, Reason: "spacing must be finite and positive and stability not negative",
+			Err: ErrInvalidInput}
+	}
+	if !options.plainEuclidean() {
+		return nil, &InputError{Index: -1, Reason: "needs a plain euclidean diagram in a box which does not wrap",
+			Err: ErrInvalidInput}
+	}
+	if len(polygon) < 3 {
+		return nil, &InputError{Index: 0, Reason: "a polygon needs three or more corners", Err: ErrInvalidInput}
+	}
+	if err := validateShapes([]Shape{polygon}); err != nil {
+		return nil, err
+	}
+	twiceArea := 0.0
+	for k, a := range polygon {
+		b := polygon[(k+1)%len(polygon)]
+		twiceArea += a.x*b.y - b.x*a.y
+	}
+	if twiceArea == 0 {
+		return nil, &InputError{Index: 0, Site: site(polygon[0]), Reason: "has no area", Err: ErrDegenerateInput}
+	}
+
+	// The bounding box starts at the origin, so the points are moved into one around the polygon
+	lo, hi := polygon[0], polygon[0]
+	for _, corner := range polygon {
+		lo = vertex{x: math.Min(lo.x, corner.x), y: math.Min(lo.y, corner.y)}
+		hi = vertex{x: math.Max(hi.x, corner.x), y: math.Max(hi.y, corner.y)}
+	}
+	if err := checkOutlineSamples(outlineSamples(polygon, spacing), options.Limits); err != nil {
+		return nil, err
+	}
+	offset := vertex{x: lo.x - spacing, y: lo.y - spacing}
+	points, along := sampleOutline(polygon, spacing)
+	perimeter := 0.0
+	for _, side := range shapeSides(polygon) {
+		perimeter += math.Hypot(side[1].x-side[0].x, side[1].y-side[0].y)
+	}
+	samples := make([]site, len(points))
+	for k, p := range points {
+		samples[k] = site{x: p.x - offset.x, y: p.y - offset.y}
+	}
+	box := boundingBox{width: hi.x - lo.x + 2*spacing, height: hi.y - lo.y + 2*spacing}
+	diagram, err := Compute(samples, box, Options{DuplicatePolicy: KeepFirstDuplicate,
+		Parallelism: options.Parallelism, Limits: options.Limits})
+	if err != nil {
+		return nil, err
+	}
+	position := make([]float64, len(diagram.sites))
+	for k, id := range diagram.siteIDs {
+		position[id] = along[k]
+	}
+
+	var segments []medialSegment
+	for _, segment := range clippedSegments(diagram) {
+		apart := math.Abs(position[segment.sites[0]] - position[segment.sites[1]])
+		if math.Min(apart, perimeter-apart) < stability {
+			continue
+		}
+		p := vertex{x: segment.p.x + offset.x, y: segment.p.y + offset.y}
+		q := vertex{x: segment.q.x + offset.x, y: segment.q.y + offset.y}
+		if !insidePolygon(p, polygon) || !insidePolygon(q, polygon) {
+			continue
+		}
+		crosses := false
+		for _, side := range shapeSides(polygon) {
+			crosses = crosses || segmentDistance([2]vertex{p, q}, side) == 0
+		}
+		if !crosses {
+			segments = append(segments, medialSegment{p: p, q: q})
+		}
+	}
+	return medialGraph(polygon, segments), nil
+}
+
+// medialGraph joins the kept edges into branches between the points where other than two of them meet, and
+// then any loops left over
+func medialGraph(polygon Shape, segments []medialSegment) *MedialAxis {
+	incident := map[vertex][]int{}
+	for k, segment := range segments {
+		incident[segment.p] = append(incident[segment.p], k)
+		incident[segment.q] = append(incident[segment.q], k)
+	}
+	axis := &MedialAxis{}
+	nodeAt := map[vertex]int{}
+	point := func(p vertex) MedialPoint {
+		radius := math.Inf(1)
+		for _, side := range shapeSides(polygon) {
+			radius = math.Min(radius, distanceToSegment(p, side[0], side[1]))
+		}
+		return MedialPoint{Point: p, Radius: radius}
+	}
+	node := func(p vertex) int {
+		if index, ok := nodeAt[p]; ok {
+			return index
+		}
+		nodeAt[p] = len(axis.Nodes)
+		axis.Nodes = append(axis.Nodes, point(p))
+		return len(axis.Nodes) - 1
+	}
+
+	used := make([]bool, len(segments))
+	follow := func(start vertex, k int) {
+		branch := MedialBranch{From: node(start), Points: []MedialPoint{axis.Nodes[node(start)]}}
+		at := start
+		for {
+			used[k] = true
+			if at == segments[k].p {
+				at = segments[k].q
+			} else {
+				at = segments[k].p
+			}
+			if len(incident[at]) != 2 || at == start {
+				branch.To = node(at)
+				branch.Points = append(branch.Points, axis.Nodes[branch.To])
+				break
+			}
+			branch.Points = append(branch.Points, point(at))
+			k = incident[at][0]
+			if used[k] {
+				k = incident[at][1]
+			}
+		}
+		axis.Branches = append(axis.Branches, branch)
+	}
+	for k, segment := range segments {
+		for _, end := range []vertex{segment.p, segment.q} {
+			if !used[k] && len(incident[end]) != 2 {
+				follow(end, k)
+			}
+		}
+	}
+	for k, segment := range segments {
+		if !used[k] {
+			follow(segment.p, k)
+		}
+	}
+	return axis
+}
+
+// renderMedialAxis draws the outline of the polygon and its medial axis into an image the size of the bounding
+// box, flipping the y axis as renderVoronoi does
+func renderMedialAxis(boundingBox boundingBox, polygon Shape, axis *MedialAxis) *gg.Context {
+	voronoi := gg.NewContext(int(boundingBox.width), int(boundingBox.height))
+	voronoi.SetRGB(1, 1, 1)
+	voronoi.Clear()
+	voronoi.SetLineWidth(3)
+
+	voronoi.SetRGB(0.9, 0.5, 0.6)
+	for _, corner := range polygon {
+		voronoi.LineTo(corner.x, boundingBox.height-corner.y)
+	}
+	voronoi.ClosePath()
+	voronoi.Stroke()
+
+	voronoi.SetRGB(0.3, 0.7, 0.8)
+	for _, branch := range axis.Branches {
+		for _, p := range branch.Points {
+			voronoi.LineTo(p.Point.x, boundingBox.height-p.Point.y)
+		}
+		voronoi.Stroke()
+	}
+	voronoi.SetRGB(0.2, 0.3, 0.6)
+	for _, node := range axis.Nodes {
+		voronoi.DrawPoint(node.Point.x, boundingBox.height-node.Point.y, 2.0)
+		voronoi.Stroke()
+	}
+	return voronoi
+}
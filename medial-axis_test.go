@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMedialAxisRectangle(t *testing.T) {
+	rectangle := Shape{{10, 10}, {210, 10}, {210, 110}, {10, 110}}
+	axis, err := ComputeMedialAxis(rectangle, 2, 10, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The axis of a rectangle runs along its middle between two junctions, from which it goes out to the corners
+	exact := [][2]vertex{{{60, 60}, {160, 60}}, {{60, 60}, {10, 10}}, {{60, 60}, {10, 110}}, {{160, 60}, {210, 10}},
+		{{160, 60}, {210, 110}}}
+	junctions := 0
+	for _, node := range axis.Nodes {
+		if math.Hypot(node.Point.x-60, node.Point.y-60) < 4 || math.Hypot(node.Point.x-160, node.Point.y-60) < 4 {
+			junctions++
+		}
+	}
+	if junctions == 0 || len(axis.Branches) < 5 {
+		t.Fatalf("got %d branches and %d nodes by the junctions, want five branches meeting at two",
+			len(axis.Branches), junctions)
+	}
+	reached := map[vertex]bool{}
+	for _, branch := range axis.Branches {
+		if branch.Points[0] != axis.Nodes[branch.From] || branch.Points[len(branch.Points)-1] != axis.Nodes[branch.To] {
+			t.Fatalf("branch %d - %d does not run between its nodes", branch.From, branch.To)
+		}
+		for _, p := range branch.Points {
+			nearest := math.Inf(1)
+			for _, segment := range exact {
+				nearest = math.Min(nearest, distanceToSegment(p.Point, segment[0], segment[1]))
+			}
+			if nearest > 2 {
+				t.Fatalf("axis point %v is %v from the exact axis", p.Point, nearest)
+			}
+			want := math.Min(math.Min(p.Point.x-10, 210-p.Point.x), math.Min(p.Point.y-10, 110-p.Point.y))
+			if math.Abs(p.Radius-want) > 1e-9 {
+				t.Fatalf("axis point %v has radius %v, want %v", p.Point, p.Radius, want)
+			}
+			for _, end := range []vertex{{60, 60}, {110, 60}, {160, 60}} {
+				if math.Hypot(p.Point.x-end.x, p.Point.y-end.y) < 2 {
+					reached[end] = true
+				}
+			}
+		}
+	}
+	if len(reached) != 3 {
+		t.Errorf("the axis passes near only %v of the middle of the rectangle", reached)
+	}
+
+	// Without pruning the axis runs on into the corners
+	unpruned, err := ComputeMedialAxis(rectangle, 2, 0, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned, whole := medialReach(axis, vertex{10, 10}), medialReach(unpruned, vertex{10, 10}); whole >= pruned ||
+		whole > 4 {
+		t.Errorf("the axis reaches %v from the corner without pruning and %v with it", whole, pruned)
+	}
+}
+
+func TestMedialAxisInside(t *testing.T) {
+	// An L shape, whose axis bends round the inside corner without crossing the outline
+	shape := Shape{{-50, -50}, {50, -50}, {50, -20}, {-20, -20}, {-20, 50}, {-50, 50}}
+	axis, err := ComputeMedialAxis(shape, 1, 5, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(axis.Branches) == 0 {
+		t.Fatal("got no axis")
+	}
+	for _, branch := range axis.Branches {
+		for k, p := range branch.Points {
+			if !insidePolygon(p.Point, shape) || p.Radius <= 0 {
+				t.Fatalf("axis point %v with radius %v is not inside the polygon", p.Point, p.Radius)
+			}
+			if k > 0 {
+				for _, side := range shapeSides(shape) {
+					if segmentDistance([2]vertex{branch.Points[k-1].Point, p.Point}, side) == 0 {
+						t.Fatalf("the axis crosses the outline at %v", p.Point)
+					}
+				}
+			}
+		}
+	}
+	// The arms meet where the axis is as far from the outside sides as from the inside corner
+	if reach := medialReach(axis, vertex{-50 + 30*(2-math.Sqrt2), -50 + 30*(2-math.Sqrt2)}); reach > 2 {
+		t.Errorf("the axis passes %v from where the arms meet", reach)
+	}
+
+	voronoi := renderMedialAxis(boundingBox{width: 100, height: 100}, shape, axis)
+	if bounds := voronoi.Image().Bounds(); bounds.Dx() != 100 || bounds.Dy() != 100 {
+		t.Errorf("got an image %v, want the size of the box", bounds)
+	}
+}
+
+func TestMedialAxisInput(t *testing.T) {
+	triangle := Shape{{0, 0}, {10, 0}, {0, 10}}
+	for _, test := range []struct {
+		name               string
+		polygon            Shape
+		spacing, stability float64
+		want               error
+	}{
+		{"no spacing", triangle, 0, 1, ErrInvalidInput},
+		{"negative stability", triangle, 1, -1, ErrInvalidInput},
+		{"segment", Shape{{0, 0}, {10, 0}}, 1, 1, ErrInvalidInput},
+		{"self-intersecting", Shape{{0, 0}, {10, 10}, {10, 0}, {0, 10}}, 1, 1, ErrDegenerateInput},
+		{"no area", Shape{{0, 0}, {10, 0}, {5, 0}}, 1, 1, ErrDegenerateInput},
+	} {
+		if _, err := ComputeMedialAxis(test.polygon, test.spacing, test.stability, Options{}); !errors.Is(err,
+			test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+	if _, err := ComputeMedialAxis(triangle, 1e-9, 1, Options{}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("spacing 1e-9: got %v, want ErrLimitExceeded", err)
+	}
+}
+
+// medialReach - how close the points of the axis come to p
+func medialReach(axis *MedialAxis, p vertex) float64 {
+	reach := math.Inf(1)
+	for _, branch := range axis.Branches {
+		for _, point := range branch.Points {
+			reach = math.Min(reach, math.Hypot(point.Point.x-p.x, point.Point.y-p.y))
+		}
+	}
+	return reach
+}
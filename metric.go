@@ -0,0 +1,457 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Metric - how distance between points is measured when deciding which site is nearest. Points the same
+// distance from two sites under the Manhattan or Chebyshev metric can fill whole regions rather than lines.
+// Those ties are broken by euclidean distance, so such a region is split along the straight bisector of the
+// two sites.
+//
+// Locate, CellStats, the exports, the empty circles, the roadmap clearances and the spanning tree and
+// relative neighbourhood graph measure distance with the metric of the diagram, or its anisotropy if it
+// has one. The gabriel graph and natural neighbour interpolation are euclidean notions, and need a
+// euclidean diagram.
+type Metric int
+
+const (
+	// Euclidean - straight line distance, giving straight bisectors and convex cells
+	Euclidean Metric = iota
+	// Manhattan - L1 distance, |dx| + |dy|. Bisectors are polylines of axis aligned and 45 degree pieces.
+	Manhattan
+	// Chebyshev - L∞ distance, max(|dx|, |dy|). Bisectors are polylines of axis aligned and 45 degree pieces.
+	Chebyshev
+)
+
+func (metric Metric) String() string {
+	switch metric {
+	case Manhattan:
+		return "manhattan"
+	case Chebyshev:
+		return "chebyshev"
+	}
+	return "euclidean"
+}
+
+// distance - the distance between two points under the metric
+func (metric Metric) distance(a, b vertex) float64 {
+	dx, dy := math.Abs(b.x-a.x), math.Abs(b.y-a.y)
+	switch metric {
+	case Manhattan:
+		return dx + dy
+	case Chebyshev:
+		return math.Max(dx, dy)
+	}
+	return math.Hypot(dx, dy)
+}
+
+// closer - whether a is nearer to p than b is, breaking ties by euclidean distance
+func (metric Metric) closer(p, a, b vertex) bool {
+	da, db := metric.distance(p, a), metric.distance(p, b)
+	if da != db || metric == Euclidean {
+		return da < db
+	}
+	return Euclidean.distance(p, a) < Euclidean.distance(p, b)
+}
+
+// distanceToSegment - the distance from p to the nearest point of the segment from a to b. Under the
+// Manhattan and Chebyshev metrics the distance along the segment is convex and piecewise linear, so it is
+// least at an end or where a piece changes.
+func (metric Metric) distanceToSegment(p, a, b vertex) float64 {
+	if metric == Euclidean {
+		return distanceToSegment(p, a, b)
+	}
+	dx, dy := b.x-a.x, b.y-a.y
+	nearest := math.Min(metric.distance(p, a), metric.distance(p, b))
+	// Where the segment lines up with p across or along, or at 45 degrees to it
+	for _, piece := range [][2]float64{{p.x - a.x, dx}, {p.y - a.y, dy}, {p.x - a.x + p.y - a.y, dx + dy},
+		{p.x - a.x - p.y + a.y, dx - dy}} {
+		if t := piece[0] / piece[1]; piece[1] != 0 && t > 0 && t < 1 {
+			nearest = math.Min(nearest, metric.distance(p, vertex{x: a.x + t*dx, y: a.y + t*dy}))
+		}
+	}
+	return nearest
+}
+
+// metricDiagram computes the cells of the sites under the Manhattan or Chebyshev metric, clipped to the
+// bounding box, and the dcel of the edges between them. A Chebyshev diagram is a Manhattan diagram turned
+// through 45 degrees, so both are found as Manhattan diagrams.
+//
+// Each cell is found on its own by clipping a box around the bounding box and its site by the bisector with
+// each site near enough to matter. Cells under these metrics need not be convex, but every point of a cell
+// can see its site, so clipping one by a bisector still leaves a single piece.
+func metricDiagram(limits *sweepLimits, siteList []site, boundingBox boundingBox, metric Metric) (
+	*doublyConnectedEdgeList, [][]cellCorner, error) {
+	// Chebyshev distance is half the Manhattan distance between the points turned through 45 degrees
+	forward, back := func(p vertex) vertex { return p }, func(p vertex) vertex { return p }
+	if metric == Chebyshev {
+		forward = func(p vertex) vertex { return vertex{x: p.x + p.y, y: p.y - p.x} }
+		back = func(p vertex) vertex { return vertex{x: (p.x - p.y) / 2, y: (p.x + p.y) / 2} }
+	}
+	sites := make([]site, len(siteList))
+	siteLow, siteHigh := vertex{x: math.Inf(1), y: math.Inf(1)}, vertex{x: math.Inf(-1), y: math.Inf(-1)}
+	for k, s := range siteList {
+		sites[k] = site(forward(vertex(s)))
+		siteLow, siteHigh = vertex{x: math.Min(siteLow.x, sites[k].x), y: math.Min(siteLow.y, sites[k].y)},
+			vertex{x: math.Max(siteHigh.x, sites[k].x), y: math.Max(siteHigh.y, sites[k].y)}
+	}
+	index := newSiteIndex(sites, siteLow, siteHigh)
+	boxLow, boxHigh := vertex{x: math.Inf(1), y: math.Inf(1)}, vertex{x: math.Inf(-1), y: math.Inf(-1)}
+	for _, corner := range []vertex{{0, 0}, {boundingBox.width, 0}, {boundingBox.width, boundingBox.height},
+		{0, boundingBox.height}} {
+		corner = forward(corner)
+		boxLow, boxHigh = vertex{x: math.Min(boxLow.x, corner.x), y: math.Min(boxLow.y, corner.y)},
+			vertex{x: math.Max(boxHigh.x, corner.x), y: math.Max(boxHigh.y, corner.y)}
+	}
+	margin := 0.01 * math.Max(boxHigh.x-boxLow.x, boxHigh.y-boxLow.y)
+
+	cells := make([][]cellCorner, len(sites))
+	for id, s := range sites {
+		if id%contextCheckInterval == 0 {
+			if err := limits.checkContext(); err != nil {
+				return nil, nil, err
+			}
+		}
+		// Each cell starts as a box around the bounding box and its site, with room for the site to be
+		// strictly inside
+		low := vertex{x: math.Min(boxLow.x, s.x) - margin, y: math.Min(boxLow.y, s.y) - margin}
+		high := vertex{x: math.Max(boxHigh.x, s.x) + margin, y: math.Max(boxHigh.y, s.y) + margin}
+		cell := []cellCorner{{low, -1}, {vertex{high.x, low.y}, -1}, {high, -1}, {vertex{low.x, high.y}, -1}}
+		// A site further than twice the furthest corner of the cell cannot cut it
+		index.visitRings(&sites[id], func(ring float64) bool {
+			reach := 0.0
+			for _, corner := range cell {
+				reach = math.Max(reach, Manhattan.distance(vertex(sites[id]), corner.vertex))
+			}
+			return ring <= 2*reach
+		}, func(other SiteID) {
+			if other != SiteID(id) {
+				cell = clipToManhattanBisector(cell, &sites[id], &sites[other], other)
+			}
+		})
+
+		// Back to the plane of the bounding box
+		for k := range cell {
+			cell[k].vertex = back(cell[k].vertex)
+		}
+		cells[id] = clipToBox(cell, boundingBox)
+	}
+
+	dcel, err := dcelFromCells(siteList, cells, boundingBox)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dcel, cells, nil
+}
+
+// clipToManhattanBisector keeps the part of a cell around s which is nearer s than the neighbouring site
+// under the Manhattan metric, the new sides along the bisector having the neighbour across them. The cell
+// must be star shaped around s.
+func clipToManhattanBisector(polygon []cellCorner, s, neighbour *site, id SiteID) []cellCorner {
+	dx, dy := neighbour.x-s.x, neighbour.y-s.y
+	if math.Abs(dx) == math.Abs(dy) {
+		// The bisector runs diagonally between two regions where the sites tie, which the euclidean bisector
+		// splits along the same diagonal
+		return clipToBisector(polygon, s, neighbour, id)
+	}
+
+	// In coordinates (a, b) with the sites further apart across a than along b, the bisector is the line
+	// a = position(b), running diagonally while b is between the sites and straight along b beyond them
+	swap := math.Abs(dy) > math.Abs(dx)
+	toLocal := func(p vertex) (a, b float64) {
+		if swap {
+			return p.y, p.x
+		}
+		return p.x, p.y
+	}
+	fromLocal := func(a, b float64) vertex {
+		if swap {
+			return vertex{x: b, y: a}
+		}
+		return vertex{x: a, y: b}
+	}
+	sa, sb := toLocal(vertex(*s))
+	na, nb := toLocal(vertex(*neighbour))
+	aSign, bSign := math.Copysign(1, na-sa), 0.0
+	if nb != sb {
+		bSign = math.Copysign(1, nb-sb)
+	}
+	aMidpoint, bMidpoint := (sa+na)/2, (sb+nb)/2
+	lo, hi := math.Min(sb, nb), math.Max(sb, nb)
+	position := func(b float64) float64 {
+		return aMidpoint - aSign*bSign*(math.Max(lo, math.Min(hi, b))-bMidpoint)
+	}
+	// Positive on the neighbour's side of the bisector
+	side := func(p vertex) float64 {
+		a, b := toLocal(p)
+		return aSign * (a - position(b))
+	}
+
+	// Most neighbours miss the cell, which they do if no corner, nor point where a side crosses a bend, is
+	// on their side
+	outside := false
+	for k, corner := range polygon {
+		next := polygon[(k+1)%len(polygon)]
+		_, b0 := toLocal(corner.vertex)
+		_, b1 := toLocal(next.vertex)
+		outside = outside || side(corner.vertex) > 0
+		for _, b := range []float64{lo, hi} {
+			if t := (b - b0) / (b1 - b0); b1 != b0 && t > 0 && t < 1 {
+				crossing := vertex{x: corner.x + t*(next.x-corner.x), y: corner.y + t*(next.y-corner.y)}
+				outside = outside || side(crossing) > 0
+			}
+		}
+	}
+	if !outside {
+		return polygon
+	}
+
+	// Sutherland-Hodgman, with each side of the polygon split where the bisector bends, and the bends
+	// between where the polygon leaves the bisector's far side and comes back added to the new sides
+	clipped := make([]cellCorner, 0, len(polygon)+3)
+	lastExit, pendingEntry := math.NaN(), math.NaN()
+	bends := func(from, to float64) {
+		for _, b := range []float64{lo, hi} {
+			if from > to {
+				b = lo + hi - b
+			}
+			if (b-from)*(b-to) < 0 {
+				clipped = append(clipped, cellCorner{fromLocal(position(b), b), id})
+			}
+		}
+	}
+	for k, corner := range polygon {
+		next := polygon[(k+1)%len(polygon)]
+		if side(corner.vertex) <= 0 {
+			clipped = append(clipped, corner)
+		}
+		// Where the side crosses the lines b = lo and b = hi, between which the bisector is straight
+		_, b0 := toLocal(corner.vertex)
+		_, b1 := toLocal(next.vertex)
+		breaks := []float64{0, 1}
+		for _, b := range []float64{lo, hi} {
+			if t := (b - b0) / (b1 - b0); b1 != b0 && t > 0 && t < 1 {
+				breaks = append(breaks, t)
+			}
+		}
+		sort.Float64s(breaks)
+		at := func(t float64) vertex {
+			return vertex{x: corner.x + t*(next.x-corner.x), y: corner.y + t*(next.y-corner.y)}
+		}
+		for j := 1; j < len(breaks); j++ {
+			p, q := at(breaks[j-1]), at(breaks[j])
+			if j == 1 {
+				p = corner.vertex
+			}
+			if j == len(breaks)-1 {
+				q = next.vertex
+			}
+			pSide, qSide := side(p), side(q)
+			if (pSide <= 0) == (qSide <= 0) {
+				continue
+			}
+			t := pSide / (pSide - qSide)
+			_, b := toLocal(vertex{x: p.x + t*(q.x-p.x), y: p.y + t*(q.y-p.y)})
+			// Put exactly on the bisector, so that the cells either side of it agree on where it is
+			crossing := fromLocal(position(b), b)
+			if pSide <= 0 {
+				clipped = append(clipped, cellCorner{crossing, id})
+				lastExit = b
+			} else if math.IsNaN(lastExit) {
+				// The first crossing comes back in, so the bends before it follow the last way out
+				pendingEntry = b
+				clipped = append(clipped, cellCorner{crossing, corner.across})
+			} else {
+				bends(lastExit, b)
+				clipped = append(clipped, cellCorner{crossing, corner.across})
+			}
+		}
+	}
+	if !math.IsNaN(pendingEntry) && !math.IsNaN(lastExit) {
+		bends(lastExit, pendingEntry)
+	}
+	return removeRepeatedCorners(clipped)
+}
+
+// clipToBox keeps the part of a polygon inside the bounding box, with the box across its new sides
+func clipToBox(polygon []cellCorner, boundingBox boundingBox) []cellCorner {
+	// Each side of the box as the coordinate it fixes, and which way is outside
+	for _, side := range []struct {
+		at, outside float64
+		vertical    bool
+	}{{0, -1, true}, {boundingBox.width, 1, true}, {0, -1, false}, {boundingBox.height, 1, false}} {
+		coordinate := func(p vertex) float64 {
+			if side.vertical {
+				return p.x
+			}
+			return p.y
+		}
+		clipped := make([]cellCorner, 0, len(polygon)+1)
+		for k, corner := range polygon {
+			next := polygon[(k+1)%len(polygon)]
+			cornerSide := side.outside * (coordinate(corner.vertex) - side.at)
+			nextSide := side.outside * (coordinate(next.vertex) - side.at)
+			if cornerSide <= 0 {
+				clipped = append(clipped, corner)
+			}
+			if (cornerSide <= 0) != (nextSide <= 0) {
+				t := cornerSide / (cornerSide - nextSide)
+				crossing := vertex{x: corner.x + t*(next.x-corner.x), y: corner.y + t*(next.y-corner.y)}
+				if side.vertical {
+					crossing.x = side.at
+				} else {
+					crossing.y = side.at
+				}
+				if cornerSide <= 0 {
+					clipped = append(clipped, cellCorner{crossing, -1})
+				} else {
+					clipped = append(clipped, cellCorner{crossing, corner.across})
+				}
+			}
+		}
+		polygon = removeRepeatedCorners(clipped)
+	}
+	markBoxSides(polygon, boundingBox)
+	return polygon
+}
+
+// markBoxSides puts the box across the sides of a polygon which run along a side of the box, which is what
+// is beyond them inside it
+func markBoxSides(polygon []cellCorner, boundingBox boundingBox) {
+	for k := range polygon {
+		p, q := polygon[k].vertex, polygon[(k+1)%len(polygon)].vertex
+		if (p.x == q.x && (p.x == 0 || p.x == boundingBox.width)) ||
+			(p.y == q.y && (p.y == 0 || p.y == boundingBox.height)) {
+			polygon[k].across = -1
+		}
+	}
+}
+
+// removeRepeatedCorners drops a corner when the next is at the same point, as the side leaving the point
+// is the next corner's
+func removeRepeatedCorners(polygon []cellCorner) []cellCorner {
+	kept := polygon[:0]
+	for k, corner := range polygon {
+		if corner.vertex != polygon[(k+1)%len(polygon)].vertex {
+			kept = append(kept, corner)
+		}
+	}
+	return kept
+}
+
+// dcelFromCells joins the sides that each pair of neighbouring cells share into half-edge pairs. Corners
+// the cells were clipped to separately are a rounding error apart, so corners that close are merged.
+func dcelFromCells(siteList []site, cells [][]cellCorner, boundingBox boundingBox) (*doublyConnectedEdgeList,
+	error) {
+	snap := newPointSnapper(1e-9 * math.Max(boundingBox.width, boundingBox.height))
+	for id, cell := range cells {
+		for k := range cell {
+			cell[k].vertex = snap.point(snap.toSides(boundingBox, cell[k].vertex))
+		}
+		if cells[id] = removeRepeatedCorners(cell); len(cells[id]) < 3 {
+			cells[id] = nil
+		}
+		markBoxSides(cells[id], boundingBox)
+	}
+
+	dcel := &doublyConnectedEdgeList{}
+	vertices := map[vertex]*vertex{}
+	vertexAt := func(p vertex) *vertex {
+		if v, ok := vertices[p]; ok {
+			return v
+		}
+		vertices[p] = dcel.addIsolatedVertex(p.x, p.y)
+		return vertices[p]
+	}
+	// Each half-edge runs clockwise around the site on its right, so backwards along the anticlockwise
+	// sides of the cell
+	type start struct {
+		site   SiteID
+		origin vertex
+	}
+	leaving := map[start]*halfEdge{}
+	for id, cell := range cells {
+		for k, corner := range cell {
+			next := cell[(k+1)%len(cell)]
+			if corner.across <= SiteID(id) {
+				continue
+			}
+			if corner.across >= SiteID(len(siteList)) {
+				return nil, fmt.Errorf("%w: cell %d has a side next to unknown site %d", ErrNumericalFailure, id,
+					corner.across)
+			}
+			halfEdge := dcel.addIsolatedEdge(&siteList[id], &siteList[corner.across])
+			halfEdge.originVertex, halfEdge.twinEdge.originVertex = vertexAt(next.vertex), vertexAt(corner.vertex)
+			leaving[start{SiteID(id), next.vertex}] = halfEdge
+			leaving[start{corner.across, corner.vertex}] = halfEdge.twinEdge
+		}
+	}
+	ids := make(map[*site]SiteID, len(siteList))
+	for i := range siteList {
+		ids[&siteList[i]] = SiteID(i)
+	}
+	for _, halfEdge := range dcel.edges {
+		halfEdge.nextEdge = leaving[start{ids[halfEdge.site], *halfEdge.twinEdge.originVertex}]
+	}
+	return dcel, nil
+}
+
+// siteIndex - a grid of buckets holding the sites, for visiting them in rings of buckets outwards from a
+// point
+type siteIndex struct {
+	low           vertex
+	width, height float64 // Of a bucket
+	columns, rows int
+	buckets       [][]SiteID
+}
+
+func newSiteIndex(sites []site, low, high vertex) *siteIndex {
+	size := int(math.Ceil(math.Sqrt(float64(len(sites)))))
+	// Sites along a line still spread over the buckets across it
+	extent := math.Max(high.x-low.x, high.y-low.y)
+	if extent == 0 {
+		extent = 1
+	}
+	index := &siteIndex{low: low, width: math.Max(high.x-low.x, extent) / float64(size),
+		height: math.Max(high.y-low.y, extent) / float64(size), columns: size, rows: size,
+		buckets: make([][]SiteID, size*size)}
+	for id, s := range sites {
+		column, row := index.bucket(vertex(s))
+		index.buckets[row*size+column] = append(index.buckets[row*size+column], SiteID(id))
+	}
+	return index
+}
+
+func (index *siteIndex) bucket(p vertex) (column, row int) {
+	column = max(0, min(index.columns-1, int((p.x-index.low.x)/index.width)))
+	row = max(0, min(index.rows-1, int((p.y-index.low.y)/index.height)))
+	return column, row
+}
+
+// visitRings visits the sites in rings of buckets around s, nearest first, for as long as more is true of
+// the least Manhattan distance from s to the next ring
+func (index *siteIndex) visitRings(s *site, more func(ring float64) bool, visit func(SiteID)) {
+	column, row := index.bucket(vertex(*s))
+	for r := 0; r <= max(index.columns, index.rows); r++ {
+		if r > 1 && !more(float64(r-1)*math.Min(index.width, index.height)) {
+			return
+		}
+		// The top and bottom rows of the ring, then the columns down its sides between them
+		for j := row - r; j <= row+r; j++ {
+			step := 1
+			if j != row-r && j != row+r {
+				step = max(1, 2*r)
+			}
+			for i := column - r; i <= column+r; i += step {
+				if i >= 0 && i < index.columns && j >= 0 && j < index.rows {
+					for _, id := range index.buckets[j*index.columns+i] {
+						visit(id)
+					}
+				}
+			}
+		}
+	}
+}
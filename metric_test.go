@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestManhattanBisector(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	diagram, err := Compute([]site{{x: 20, y: 50}, {x: 80, y: 60}}, box, Options{Metric: Manhattan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Up the side at x = 55, across diagonally between the heights of the sites, then up at x = 45
+	want := map[[2]vertex]bool{{{55, 0}, {55, 50}}: true, {{55, 50}, {45, 60}}: true, {{45, 60}, {45, 100}}: true}
+	if len(diagram.dcel.edges) != 2*len(want) {
+		t.Fatalf("got %d half-edges, want %d", len(diagram.dcel.edges), 2*len(want))
+	}
+	for _, halfEdge := range diagram.dcel.edges {
+		start, end := *halfEdge.originVertex, *halfEdge.twinEdge.originVertex
+		if !want[[2]vertex{start, end}] && !want[[2]vertex{end, start}] {
+			t.Errorf("unexpected edge %v - %v", start, end)
+		}
+	}
+	stats := diagram.CellStats()
+	if !nearlyEqual(stats[0].Area, 5050) || !nearlyEqual(stats[1].Area, 4950) || len(stats[0].Neighbours) != 1 ||
+		!nearlyEqual(stats[0].Neighbours[0].SharedLength, 90+10*math.Sqrt2) {
+		t.Errorf("got cells %+v", stats)
+	}
+}
+
+func TestMetricTies(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	for _, test := range []struct {
+		metric   Metric
+		siteList []site
+	}{
+		// The sites tie across two quarter planes, which the diagonal between them splits in half
+		{Manhattan, []site{{x: 25, y: 25}, {x: 75, y: 75}}},
+		// The sites tie above and below, which the vertical line between them splits in half
+		{Chebyshev, []site{{x: 20, y: 50}, {x: 80, y: 50}}},
+	} {
+		diagram, err := Compute(test.siteList, box, Options{Metric: test.metric})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, cell := range diagram.CellStats() {
+			if !nearlyEqual(cell.Area, 5000) {
+				t.Errorf("%v: cell %d has area %v, want 5000", test.metric, id, cell.Area)
+			}
+		}
+	}
+}
+
+func TestMetricDiagram(t *testing.T) {
+	for _, metric := range []Metric{Manhattan, Chebyshev} {
+		for _, distribution := range siteDistributions {
+			siteList := distribution.generate(300, rand.New(rand.NewSource(6)))
+			diagram, err := Compute(siteList, benchmarkBox, Options{DuplicatePolicy: KeepFirstDuplicate,
+				Metric: metric})
+			if err != nil {
+				t.Fatalf("%v %s: %v", metric, distribution.name, err)
+			}
+
+			totalArea := 0.0
+			for _, cell := range diagram.CellStats() {
+				totalArea += cell.Area
+			}
+			if !nearlyEqual(totalArea, benchmarkBox.width*benchmarkBox.height) {
+				t.Errorf("%v %s: cells cover %v, want %v", metric, distribution.name, totalArea,
+					benchmarkBox.width*benchmarkBox.height)
+			}
+
+			// Every point is in the cell of its nearest site
+			source := rand.New(rand.NewSource(7))
+			for k := 0; k < 2000; k++ {
+				p := vertex{x: source.Float64() * benchmarkBox.width, y: source.Float64() * benchmarkBox.height}
+				got, want := diagram.Locate(p), nearestSite(metric, vertex{}, diagram.sites, nil, p)
+				gotDistance := metric.distance(p, vertex(diagram.sites[got]))
+				wantDistance := metric.distance(p, vertex(diagram.sites[want]))
+				if got != want && math.Abs(gotDistance-wantDistance) > 1e-9*benchmarkBox.width {
+					t.Fatalf("%v %s: (%v, %v) located in cell %d, %v away, nearest is %d, %v away", metric,
+						distribution.name, p.x, p.y, got, gotDistance, want, wantDistance)
+				}
+			}
+		}
+	}
+
+	if _, err := Compute(uniformSites(10, rand.New(rand.NewSource(1))), benchmarkBox,
+		Options{Metric: Metric(7)}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("computing with an unknown metric returned %v, want ErrInvalidInput", err)
+	}
+}
+
+func BenchmarkMetricDiagram(b *testing.B) {
+	siteList := uniformSites(10000, rand.New(rand.NewSource(1)))
+	for _, metric := range []Metric{Euclidean, Manhattan, Chebyshev} {
+		b.Run(metric.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Compute(siteList, benchmarkBox, Options{Metric: metric}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWeightedDiagram(b *testing.B) {
+	source := rand.New(rand.NewSource(1))
+	siteList := uniformSites(2000, source)
+	additive, multiplicative := make([]float64, len(siteList)), make([]float64, len(siteList))
+	for k := range siteList {
+		additive[k], multiplicative[k] = source.Float64()*10, 1+source.Float64()*2
+	}
+	for _, weighting := range []Weighting{AdditiveWeights, MultiplicativeWeights} {
+		options := Options{Weighting: weighting, Weights: additive}
+		if weighting == MultiplicativeWeights {
+			options.Weights = multiplicative
+		}
+		b.Run(weighting.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Compute(siteList, benchmarkBox, options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/fogleman/gg"
+)
+
+// OrderDiagram - a voronoi diagram of higher order, dividing the bounding box into regions sharing the same
+// k nearest sites, or the farthest-point diagram, whose regions share the same farthest site. Each region is
+// labelled by its set of sites. The regions are the cells of a diagram with a site standing for each region
+// (the centroid of its sites), so they are measured and drawn as the cells of any other diagram.
+type OrderDiagram struct {
+	sites    []site   // After merging duplicates, indexed by SiteID
+	siteIDs  []SiteID // The SiteID of each input site
+	order    int      // How many sites label each region, or zero for the farthest-point diagram
+	labels   [][]SiteID
+	labelled map[string]SiteID // The region labelled by each set of sites, keyed by labelKey
+	regions  *Diagram          // Indexed by region
+}
+
+// ComputeOrder generates the voronoi diagram of the given order within the bounding box, whose regions are the
+// points sharing the same order nearest sites. Order one is the usual diagram. The options apply as for
+// Compute, whose diagram of the sites the regions are found from, except that the metric must be plain
+// euclidean and the box may not wrap around. Returned errors wrap ErrInvalidInput if the order is not between
+// one and the number of sites, and otherwise are those of Compute.
+func ComputeOrder(siteList []site, boundingBox boundingBox, order int, options Options) (*OrderDiagram, error) {
+	if !options.plainEuclidean() {
+		return nil, &InputError{Index: -1, Reason: "needs a plain euclidean diagram in a box which does not wrap",
+			Err: ErrInvalidInput}
+	}
+	diagram, err := Compute(siteList, boundingBox, options)
+	if err != nil {
+		return nil, err
+	}
+	if order < 1 || order > len(diagram.sites) {
+		return nil, &InputError{Index: -1, Reason: fmt.Sprintf("order %d is not between 1 and the %d sites", order,
+			len(diagram.sites)), Err: ErrInvalidInput}
+	}
+	neighbours := diagram.dcelNeighbours()
+	minimumArea := 1e-12 * boundingBox.width * boundingBox.height
+
+	// The k nearest sites of any point are joined in the delaunay triangulation, so the (k+1)th nearest is a
+	// delaunay neighbour of one of them. Each region of order k is split between those neighbours to find
+	// the labels of order k+1, whose regions are then cut out of the box afresh.
+	var labels [][]SiteID
+	for id, polygon := range diagram.cellPolygons() {
+		if len(polygon) >= 3 && polygonArea(polygon) > minimumArea {
+			labels = append(labels, []SiteID{SiteID(id)})
+		}
+	}
+	for k := 1; k < order; k++ {
+		seen := map[string]bool{}
+		var next [][]SiteID
+		for _, label := range labels {
+			region := orderRegion(diagram, neighbours, label, boundingBox, nil)
+			candidates := orderCandidates(neighbours, label)
+			for _, t := range candidates {
+				piece := region
+				for _, u := range candidates {
+					if u != t && len(piece) > 0 {
+						piece = clipToBisector(piece, &diagram.sites[t], &diagram.sites[u], -1)
+					}
+				}
+				grown := append(append([]SiteID(nil), label...), t)
+				sort.Slice(grown, func(i, j int) bool { return grown[i] < grown[j] })
+				if key := labelKey(grown); len(piece) >= 3 && polygonArea(piece) > minimumArea && !seen[key] {
+					seen[key] = true
+					next = append(next, grown)
+				}
+			}
+		}
+		labels = next
+	}
+
+	orderDiagram := &OrderDiagram{sites: diagram.sites, siteIDs: diagram.siteIDs, order: order,
+		labelled: map[string]SiteID{}}
+	var swaps [][2]SiteID
+	var cells [][]cellCorner
+	for _, label := range labels {
+		region := orderRegion(diagram, neighbours, label, boundingBox, &swaps)
+		if len(region) >= 3 && polygonArea(region) > minimumArea {
+			orderDiagram.labelled[labelKey(label)] = SiteID(len(orderDiagram.labels))
+			orderDiagram.labels = append(orderDiagram.labels, label)
+			cells = append(cells, region)
+		}
+	}
+	// Across the bisector between s in the label and t outside it is the region with t in place of s
+	for id, cell := range cells {
+		for k, corner := range cell {
+			if corner.across >= 0 {
+				swap := swaps[corner.across]
+				across := make([]SiteID, 0, order)
+				for _, s := range orderDiagram.labels[id] {
+					if s != swap[0] {
+						across = append(across, s)
+					}
+				}
+				across = append(across, swap[1])
+				sort.Slice(across, func(i, j int) bool { return across[i] < across[j] })
+				if neighbour, ok := orderDiagram.labelled[labelKey(across)]; ok {
+					cell[k].across = neighbour
+				} else {
+					cell[k].across = -1
+				}
+			}
+		}
+	}
+	if err := orderDiagram.finish(cells, boundingBox); err != nil {
+		return nil, err
+	}
+	return orderDiagram, nil
+}
+
+// ComputeFarthest generates the farthest-point voronoi diagram within the bounding box, whose regions are the
+// points sharing the same farthest site, each labelled by that one site. Only the corners of the convex hull
+// of the sites have regions. The options apply as for ComputeOrder.
+func ComputeFarthest(siteList []site, boundingBox boundingBox, options Options) (*OrderDiagram, error) {
+	if !options.plainEuclidean() {
+		return nil, &InputError{Index: -1, Reason: "needs a plain euclidean diagram in a box which does not wrap",
+			Err: ErrInvalidInput}
+	}
+	diagram, err := Compute(siteList, boundingBox, options)
+	if err != nil {
+		return nil, err
+	}
+	orderDiagram := &OrderDiagram{sites: diagram.sites, siteIDs: diagram.siteIDs, labelled: map[string]SiteID{}}
+	minimumArea := 1e-12 * boundingBox.width * boundingBox.height
+
+	// A region is the part of the box nearer every other corner of the hull than its own, the new sides
+	// having the site of the neighbouring region across them until the regions are numbered
+	hull := convexHull(diagram.sites)
+	var cells [][]cellCorner
+	for _, s := range hull {
+		region := []cellCorner{{vertex{0, 0}, -1}, {vertex{boundingBox.width, 0}, -1},
+			{vertex{boundingBox.width, boundingBox.height}, -1}, {vertex{0, boundingBox.height}, -1}}
+		for _, t := range hull {
+			if t != s && len(region) > 0 {
+				region = clipToBisector(region, &diagram.sites[t], &diagram.sites[s], t)
+			}
+		}
+		if len(region) >= 3 && polygonArea(region) > minimumArea {
+			orderDiagram.labelled[labelKey([]SiteID{s})] = SiteID(len(orderDiagram.labels))
+			orderDiagram.labels = append(orderDiagram.labels, []SiteID{s})
+			cells = append(cells, region)
+		}
+	}
+	for _, cell := range cells {
+		for k, corner := range cell {
+			if corner.across >= 0 {
+				if neighbour, ok := orderDiagram.labelled[labelKey([]SiteID{corner.across})]; ok {
+					cell[k].across = neighbour
+				} else {
+					cell[k].across = -1
+				}
+			}
+		}
+	}
+	if err := orderDiagram.finish(cells, boundingBox); err != nil {
+		return nil, err
+	}
+	return orderDiagram, nil
+}
+
+// orderRegion cuts the region labelled by a set of sites out of the box: the part nearer every site of the
+// label than any delaunay neighbour outside it. If swaps is given, each new side has across it the index in
+// swaps of the pair of sites (inside the label and outside it) whose bisector it is.
+func orderRegion(diagram *Diagram, neighbours [][]SiteID, label []SiteID, boundingBox boundingBox,
+	swaps *[][2]SiteID) []cellCorner {
+	region := []cellCorner{{vertex{0, 0}, -1}, {vertex{boundingBox.width, 0}, -1},
+		{vertex{boundingBox.width, boundingBox.height}, -1}, {vertex{0, boundingBox.height}, -1}}
+	candidates := orderCandidates(neighbours, label)
+	for _, s := range label {
+		for _, t := range candidates {
+			if len(region) == 0 {
+				return nil
+			}
+			across := SiteID(-1)
+			if swaps != nil {
+				across = SiteID(len(*swaps))
+				*swaps = append(*swaps, [2]SiteID{s, t})
+			}
+			region = clipToBisector(region, &diagram.sites[s], &diagram.sites[t], across)
+		}
+	}
+	return region
+}
+
+// orderCandidates returns the delaunay neighbours of the sites of a label which are not in it, in order
+func orderCandidates(neighbours [][]SiteID, label []SiteID) []SiteID {
+	inside := map[SiteID]bool{}
+	for _, s := range label {
+		inside[s] = true
+	}
+	var candidates []SiteID
+	for _, s := range label {
+		for _, t := range neighbours[s] {
+			if !inside[t] {
+				inside[t] = true
+				candidates = append(candidates, t)
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i] < candidates[j] })
+	return candidates
+}
+
+// labelKey - a set of sites in order as a map key
+func labelKey(label []SiteID) string {
+	return fmt.Sprint(label)
+}
+
+// convexHull returns the corners of the convex hull of the sites anticlockwise, leaving out sites part way
+// along its sides (Andrew's monotone chain)
+func convexHull(siteList []site) []SiteID {
+	ids := make([]SiteID, len(siteList))
+	for i := range ids {
+		ids[i] = SiteID(i)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return pointLeftOf(vertex(siteList[ids[i]]), vertex(siteList[ids[j]]))
+	})
+	if len(ids) < 3 {
+		return ids
+	}
+	var hull []SiteID
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, id := range ids {
+			for len(hull) >= start+2 && cross(vertex(siteList[hull[len(hull)-2]]),
+				vertex(siteList[hull[len(hull)-1]]), vertex(siteList[id])) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, id)
+		}
+		// Each chain ends where the other starts
+		hull = hull[:len(hull)-1]
+		for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+	}
+	return hull
+}
+
+// finish joins the regions into the dcel of a diagram with a site at the centroid of each region's sites
+func (diagram *OrderDiagram) finish(cells [][]cellCorner, boundingBox boundingBox) error {
+	siteList := make([]site, len(diagram.labels))
+	siteIDs := make([]SiteID, len(diagram.labels))
+	for id, label := range diagram.labels {
+		for _, s := range label {
+			siteList[id].x += diagram.sites[s].x / float64(len(label))
+			siteList[id].y += diagram.sites[s].y / float64(len(label))
+		}
+		siteIDs[id] = SiteID(id)
+	}
+	dcel, err := dcelFromCells(siteList, cells, boundingBox)
+	if err != nil {
+		return err
+	}
+	diagram.regions = &Diagram{sites: siteList, siteIDs: siteIDs, boundingBox: boundingBox, dcel: dcel,
+		fixedCells: cells}
+	return nil
+}
+
+// Sites returns the sites of the diagram after duplicates were merged, indexed by SiteID
+func (diagram *OrderDiagram) Sites() []site {
+	return append([]site(nil), diagram.sites...)
+}
+
+// SiteIDs returns the id of the merged site that each input site belongs to
+func (diagram *OrderDiagram) SiteIDs() []SiteID {
+	return append([]SiteID(nil), diagram.siteIDs...)
+}
+
+// Labels returns the set of sites labelling each region in order of id, indexed by region: its nearest
+// sites, or its farthest site in the farthest-point diagram
+func (diagram *OrderDiagram) Labels() [][]SiteID {
+	labels := make([][]SiteID, len(diagram.labels))
+	for id, label := range diagram.labels {
+		labels[id] = append([]SiteID(nil), label...)
+	}
+	return labels
+}
+
+// CellStats returns the measurements of every region, indexed by region, with the neighbours of each being
+// regions too
+func (diagram *OrderDiagram) CellStats() []CellStats {
+	return diagram.regions.CellStats()
+}
+
+// Locate returns the region containing p, or -1 if p lies outside the bounding box. A point on the border
+// of two regions may be given either.
+func (diagram *OrderDiagram) Locate(p vertex) SiteID {
+	box := diagram.regions.boundingBox
+	if p.x < 0 || p.y < 0 || p.x > box.width || p.y > box.height || len(diagram.labels) == 0 {
+		return -1
+	}
+	ids := make([]SiteID, len(diagram.sites))
+	distances := make([]float64, len(diagram.sites))
+	for i, s := range diagram.sites {
+		ids[i], distances[i] = SiteID(i), math.Hypot(s.x-p.x, s.y-p.y)
+	}
+	sort.Slice(ids, func(i, j int) bool { return distances[ids[i]] < distances[ids[j]] })
+	label := ids[:diagram.order]
+	if diagram.order == 0 {
+		label = ids[len(ids)-1:]
+	}
+	label = append([]SiteID(nil), label...)
+	sort.Slice(label, func(i, j int) bool { return label[i] < label[j] })
+	if id, ok := diagram.labelled[labelKey(label)]; ok {
+		return id
+	}
+
+	// Where distances tie the sites sorted first may not label a region, so the nearest region is found:
+	// the k nearest sites have the least sum of squared distances, and the farthest the greatest distance
+	best, bestScore := SiteID(-1), math.Inf(1)
+	for id, label := range diagram.labels {
+		score := 0.0
+		for _, s := range label {
+			if diagram.order == 0 {
+				score -= distances[s]
+			} else {
+				score += distances[s] * distances[s]
+			}
+		}
+		if score < bestScore {
+			best, bestScore = SiteID(id), score
+		}
+	}
+	return best
+}
+
+// renderOrderVoronoi draws the borders of the regions and the sites into an image the size of the bounding
+// box
+func renderOrderVoronoi(diagram *OrderDiagram) *gg.Context {
+	return renderVoronoi(diagram.regions.boundingBox, diagram.regions.dcel, diagram.sites)
+}
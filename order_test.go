@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestOrderDiagram(t *testing.T) {
+	siteList := uniformSites(60, rand.New(rand.NewSource(14)))
+	plain, err := Compute(siteList, benchmarkBox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for order := 1; order <= 4; order++ {
+		diagram, err := ComputeOrder(siteList, benchmarkBox, order, Options{})
+		if err != nil {
+			t.Fatalf("order %d: %v", order, err)
+		}
+		if err := diagram.regions.dcel.validate(); err != nil {
+			t.Fatalf("order %d: %v", order, err)
+		}
+		labels := diagram.Labels()
+		stats := diagram.CellStats()
+		totalArea := 0.0
+		for id, cell := range stats {
+			if len(labels[id]) != order {
+				t.Fatalf("order %d: region %d is labelled %v", order, id, labels[id])
+			}
+			totalArea += cell.Area
+			// Neighbouring regions differ by one site
+			for _, neighbour := range cell.Neighbours {
+				if shared := sharedSites(labels[id], labels[neighbour.ID]); shared != order-1 {
+					t.Fatalf("order %d: regions %v and %v are neighbours", order, labels[id], labels[neighbour.ID])
+				}
+			}
+		}
+		if math.Abs(totalArea-benchmarkBox.width*benchmarkBox.height) > 1e-6 {
+			t.Errorf("order %d: regions cover %v, want %v", order, totalArea, benchmarkBox.width*benchmarkBox.height)
+		}
+		if order == 1 {
+			for id, cell := range plain.CellStats() {
+				if labels[id][0] != SiteID(id) || math.Abs(stats[id].Area-cell.Area) > 1e-6 {
+					t.Fatalf("order 1: region %d labelled %v has area %v, want the cell of site %d with %v", id,
+						labels[id], stats[id].Area, id, cell.Area)
+				}
+			}
+		}
+
+		// Every point is in the region of its nearest sites
+		source := rand.New(rand.NewSource(15))
+		for k := 0; k < 500; k++ {
+			p := vertex{source.Float64() * benchmarkBox.width, source.Float64() * benchmarkBox.height}
+			got := diagram.Locate(p)
+			nearest := sitesByDistance(siteList, p)
+			for _, s := range labels[got] {
+				if d := distanceTo(siteList[s], p); d > distanceTo(siteList[nearest[order-1]], p)+1e-9 {
+					t.Fatalf("order %d: %v is in region %v, but %d is not among its nearest %v", order, p,
+						labels[got], s, nearest[:order])
+				}
+			}
+		}
+	}
+}
+
+func TestFarthestDiagram(t *testing.T) {
+	// The farthest-point diagram of a square's corners is its quarters, and the middle site has no region
+	box := boundingBox{width: 100, height: 100}
+	diagram, err := ComputeFarthest([]site{{20, 20}, {80, 20}, {80, 80}, {20, 80}, {50, 50}}, box, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := diagram.Labels()
+	if len(labels) != 4 {
+		t.Fatalf("got regions %v, want one for each corner", labels)
+	}
+	for id, cell := range diagram.CellStats() {
+		if !nearlyEqual(cell.Area, 2500) || len(cell.Neighbours) != 2 {
+			t.Errorf("region %v has area %v and %d neighbours, want a quarter of the box", labels[id], cell.Area,
+				len(cell.Neighbours))
+		}
+	}
+	if got := diagram.Locate(vertex{90, 90}); labels[got][0] != 0 {
+		t.Errorf("(90, 90) is in the region of %v, want the farthest corner (20, 20)", labels[got])
+	}
+
+	siteList := uniformSites(200, rand.New(rand.NewSource(16)))
+	diagram, err = ComputeFarthest(siteList, benchmarkBox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	totalArea := 0.0
+	for _, cell := range diagram.CellStats() {
+		totalArea += cell.Area
+	}
+	if math.Abs(totalArea-benchmarkBox.width*benchmarkBox.height) > 1e-6 {
+		t.Errorf("regions cover %v, want %v", totalArea, benchmarkBox.width*benchmarkBox.height)
+	}
+	labels = diagram.Labels()
+	source := rand.New(rand.NewSource(17))
+	for k := 0; k < 500; k++ {
+		p := vertex{source.Float64() * benchmarkBox.width, source.Float64() * benchmarkBox.height}
+		farthest := sitesByDistance(siteList, p)[len(siteList)-1]
+		if got := labels[diagram.Locate(p)][0]; distanceTo(siteList[farthest], p)-distanceTo(siteList[got], p) > 1e-9 {
+			t.Fatalf("%v is in the region of %d, farthest is %d", p, got, farthest)
+		}
+	}
+
+	voronoi := renderOrderVoronoi(diagram)
+	if bounds := voronoi.Image().Bounds(); bounds.Dx() != 1000 || bounds.Dy() != 1000 {
+		t.Errorf("got an image %v, want the size of the box", bounds)
+	}
+}
+
+func TestOrderInput(t *testing.T) {
+	siteList := []site{{10, 10}, {20, 20}, {30, 10}}
+	box := boundingBox{width: 100, height: 100}
+	for _, order := range []int{0, 4} {
+		if _, err := ComputeOrder(siteList, box, order, Options{}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("order %d: got %v, want ErrInvalidInput", order, err)
+		}
+	}
+	if _, err := ComputeOrder(siteList, box, 2, Options{Metric: Manhattan}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("manhattan metric: got %v, want ErrInvalidInput", err)
+	}
+	if _, err := ComputeFarthest(siteList, box, Options{Periodic: true}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("periodic box: got %v, want ErrInvalidInput", err)
+	}
+}
+
+// sitesByDistance returns the ids of the sites from nearest p to farthest
+func sitesByDistance(siteList []site, p vertex) []SiteID {
+	ids := make([]SiteID, len(siteList))
+	for i := range ids {
+		ids[i] = SiteID(i)
+	}
+	sort.Slice(ids, func(i, j int) bool { return distanceTo(siteList[ids[i]], p) < distanceTo(siteList[ids[j]], p) })
+	return ids
+}
+
+// sharedSites - how many sites two labels have in common
+func sharedSites(a, b []SiteID) int {
+	shared := 0
+	for _, s := range a {
+		for _, t := range b {
+			if s == t {
+				shared++
+			}
+		}
+	}
+	return shared
+}
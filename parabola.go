@@ -6,19 +6,38 @@ import "math"
 // Since the beachline is x-monotone the breakpoints can be differentiated by which parabola is left
 // of the breakpoint and which is right (i.e. breakpoint (a,b) is not the same as (b,a) breakpoint).
 func getBreakpointXCoordinate(focusPair *breakpoint, directrix float64) float64 {
-	// Get the coefficients for each parabola
-	a1, b1, c1 := getCoefficients(focusPair.leftSite, directrix)
-	a2, b2, c2 := getCoefficients(focusPair.rightSite, directrix)
+	// A site lying on the directrix has a degenerate parabola (a vertical ray up from the site) so the
+	// breakpoint is directly above it. Two foci at the same height meet halfway between them.
+	if focusPair.leftSite.y == directrix {
+		return focusPair.leftSite.x
+	}
+	if focusPair.rightSite.y == directrix {
+		return focusPair.rightSite.x
+	}
+	if focusPair.leftSite.y == focusPair.rightSite.y {
+		return (focusPair.leftSite.x + focusPair.rightSite.x) / 2
+	}
+
+	// Get the coefficients for each parabola. These are relative to the left focus and the directrix so that
+	// large coordinates don't swamp the difference between the parabolas.
+	originX := focusPair.leftSite.x
+	a1, b1, c1 := getCoefficients(focusPair.leftSite, originX, directrix)
+	a2, b2, c2 := getCoefficients(focusPair.rightSite, originX, directrix)
 
 	aDiff := a1 - a2
 	bDiff := b1 - b2
 	cDiff := c1 - c2
 
-	discriminant := (bDiff * bDiff) - (4 * aDiff * cDiff)
+	// The parabolas always intersect, a negative discriminant can only come from rounding
+	discriminant := math.Max((bDiff*bDiff)-(4*aDiff*cDiff), 0)
 
-	// Quadratic formula
-	intersection1 := ((-1 * bDiff) + math.Sqrt(discriminant)) / (2 * aDiff)
-	intersection2 := ((-1 * bDiff) - math.Sqrt(discriminant)) / (2 * aDiff)
+	// Quadratic formula - in the form which avoids cancellation between -b and the square root
+	q := -0.5 * (bDiff + math.Copysign(math.Sqrt(discriminant), bDiff))
+	intersection1 := originX + (q / aDiff)
+	intersection2 := originX + (cDiff / q)
+	if q == 0 {
+		intersection2 = intersection1
+	}
 
 	// Problem: Given a focus pair (a,b), we get two intersections returned (x1 and x2), how do we
 	//          know which one is the intersection for (a,b) (i.e. is x1 (a,b) or (b,a))
@@ -35,13 +54,15 @@ func getBreakpointXCoordinate(focusPair *breakpoint, directrix float64) float64
 
 // Return the coefficients in the parabola standard form equation ax^2 + bx + c = 0
 // Note - I got the equations to determine the coefficients from math stackexchange post
-func getCoefficients(site *site, directrix float64) (float64, float64, float64) {
+// The coefficients are for the parabola shifted so that originX and the directrix lie on the axes.
+func getCoefficients(site *site, originX, directrix float64) (float64, float64, float64) {
 	// Double the distance from the focus to the directrix
 	dp := 2.0 * (site.y - directrix)
+	x := site.x - originX
 
 	a := 1.0 / dp
-	b := (-2.0 * site.x) / dp
-	c := directrix + (dp / 4.0) + ((site.x * site.x) / dp)
+	b := (-2.0 * x) / dp
+	c := (dp / 4.0) + ((x * x) / dp)
 
 	return a, b, c
 }
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// minimumStripSites - strips are never made smaller than this, so small inputs are swept in one pass
+const minimumStripSites = 8
+
+// delaunayGraph - the delaunay triangulation of the sites, which is the dual of the voronoi diagram: two
+// sites are neighbours when their cells share an edge. Sites are sorted left to right (bottom to top when
+// they have the same x) and each neighbour list is kept in anticlockwise order.
+type delaunayGraph struct {
+	sites      []*site
+	neighbours [][]int
+}
+
+// parallelSweep splits the sites into vertical strips which are swept concurrently, then stitches the
+// triangulations of neighbouring strips together along their boundaries (the Guibas-Stolfi merge) and
+// builds the voronoi diagram from the result. The merged triangulation is checked before it is used and if
+// rounding has made it invalid the sites are swept again in one pass, so the diagram is always the one
+// fortunesAlgorithm would produce.
+func (builder *Builder) parallelSweep(limits *sweepLimits, siteList []site, strips int) (*doublyConnectedEdgeList,
+	error) {
+	strips = min(strips, len(siteList)/minimumStripSites)
+	if strips < 2 {
+		return builder.sweep(limits, siteList)
+	}
+	graph, ok, err := triangulateInStrips(limits, siteList, strips)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		limits.restart()
+		return builder.sweep(limits, siteList)
+	}
+	return graph.voronoi(&builder.arena), nil
+}
+
+// triangulateInStrips builds the delaunay triangulation of the sites from the diagrams of the strips. ok
+// is false if the sites are collinear (there are no triangles to stitch together) or the result is invalid.
+func triangulateInStrips(limits *sweepLimits, siteList []site, strips int) (graph *delaunayGraph, ok bool,
+	err error) {
+	graph = &delaunayGraph{sites: make([]*site, len(siteList)), neighbours: make([][]int, len(siteList))}
+	for i := range siteList {
+		graph.sites[i] = &siteList[i]
+	}
+	sort.Slice(graph.sites, func(i, j int) bool {
+		return siteLeftOf(graph.sites[i], graph.sites[j])
+	})
+	if graph.collinear() {
+		return nil, false, nil
+	}
+
+	bounds := make([]int, strips+1)
+	for strip := range bounds {
+		bounds[strip] = strip * len(siteList) / strips
+	}
+	errs := make([]error, strips)
+	var wait sync.WaitGroup
+	for strip := 0; strip < strips; strip++ {
+		wait.Add(1)
+		go func(strip int) {
+			defer wait.Done()
+			errs[strip] = graph.triangulateStrip(limits, bounds[strip], bounds[strip+1])
+		}(strip)
+	}
+	wait.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if err := limits.checkContext(); err != nil {
+		return nil, false, err
+	}
+
+	// Merge neighbouring pairs of strips, then pairs of those and so on. Each merge only touches the sites
+	// of its own strips so the merges at each level can run at the same time.
+	merged := true
+	var mergedLock sync.Mutex
+	for width := 1; width < strips; width *= 2 {
+		for strip := 0; strip+width < strips; strip += 2 * width {
+			wait.Add(1)
+			go func(lo, mid, hi int) {
+				defer wait.Done()
+				if !graph.merge(lo, mid, hi) {
+					mergedLock.Lock()
+					merged = false
+					mergedLock.Unlock()
+				}
+			}(bounds[strip], bounds[strip+width], bounds[min(strip+2*width, strips)])
+		}
+		wait.Wait()
+	}
+	return graph, merged && graph.isDelaunay(bounds), nil
+}
+
+// siteLeftOf - the order of the sites in a delaunayGraph
+func siteLeftOf(a, b *site) bool {
+	return a.x < b.x || (a.x == b.x && a.y < b.y)
+}
+
+// triangulateStrip sweeps the sites lo to hi on their own and records the neighbours in their diagram
+func (graph *delaunayGraph) triangulateStrip(limits *sweepLimits, lo, hi int) error {
+	stripSites := make([]site, hi-lo)
+	for i := range stripSites {
+		stripSites[i] = *graph.sites[lo+i]
+	}
+	var builder Builder
+	dcel, err := builder.sweep(limits, stripSites)
+	if err != nil {
+		return err
+	}
+
+	index := func(s *site) int {
+		return lo + sort.Search(len(stripSites), func(k int) bool { return !siteLeftOf(&stripSites[k], s) })
+	}
+	// Half-edges are added to the dcel in twin pairs. The neighbour lists are cut from one array, leaving
+	// room for the edges the merges add.
+	pairs := make([][2]int, len(dcel.edges)/2)
+	degrees := make([]int, hi-lo)
+	for k := range pairs {
+		pairs[k] = [2]int{index(dcel.edges[2*k].site), index(dcel.edges[2*k+1].site)}
+		degrees[pairs[k][0]-lo]++
+		degrees[pairs[k][1]-lo]++
+	}
+	const spare = 4
+	backing := make([]int, 0, len(dcel.edges)+spare*(hi-lo))
+	for i, degree := range degrees {
+		graph.neighbours[lo+i] = backing[len(backing) : len(backing) : len(backing)+degree+spare]
+		backing = backing[:len(backing)+degree+spare]
+	}
+	for _, pair := range pairs {
+		graph.neighbours[pair[0]] = append(graph.neighbours[pair[0]], pair[1])
+		graph.neighbours[pair[1]] = append(graph.neighbours[pair[1]], pair[0])
+	}
+
+	var angles []float64
+	for i := lo; i < hi; i++ {
+		// Insertion sort by angle (there are only a few neighbours each), dropping any repeated neighbour
+		neighbours := graph.neighbours[i]
+		angles = angles[:0]
+		for k, j := range neighbours {
+			angle := graph.angle(i, j)
+			angles = append(angles, angle)
+			for ; k > 0 && angles[k-1] > angle; k-- {
+				angles[k], neighbours[k] = angles[k-1], neighbours[k-1]
+			}
+			angles[k], neighbours[k] = angle, j
+		}
+		unique := neighbours[:0]
+		for _, j := range neighbours {
+			if len(unique) == 0 || unique[len(unique)-1] != j {
+				unique = append(unique, j)
+			}
+		}
+		graph.neighbours[i] = unique
+	}
+	return nil
+}
+
+// merge stitches together the triangulations of the sites lo to mid and mid to hi. Starting with the edge
+// along the bottom of their combined convex hull, it repeatedly adds the edge to whichever candidate site
+// on the left or right forms an empty circle with the current base edge, deleting the edges that circle
+// shows are no longer delaunay, until it reaches the top of the hull. It returns false if rounding stopped
+// it from finishing.
+func (graph *delaunayGraph) merge(lo, mid, hi int) bool {
+	left, rightMost := graph.hull(lo, mid)
+	right, _ := graph.hull(mid, hi)
+
+	// Find the lower common tangent, starting from the closest sites of the two halves
+	l, r := rightMost, 0
+	for {
+		x, y := graph.sites[left[l]], graph.sites[right[r]]
+		if previous := (l + len(left) - 1) % len(left); orientation(x, y, graph.sites[left[previous]]) < 0 {
+			l = previous
+		} else if next := (r + 1) % len(right); orientation(x, y, graph.sites[right[next]]) < 0 {
+			r = next
+		} else {
+			break
+		}
+	}
+
+	x, y := left[l], right[r]
+	graph.insertEdge(x, y)
+	for steps := 0; ; steps++ {
+		if steps > 3*(hi-lo) {
+			return false
+		}
+		// Candidates above the base edge x-y: the next neighbour anticlockwise around x from y, and the next
+		// clockwise around y from x
+		leftCandidate := graph.succ(x, y)
+		if graph.above(x, y, leftCandidate) {
+			for {
+				next := graph.succ(x, leftCandidate)
+				if inCircle(graph.sites[x], graph.sites[y], graph.sites[leftCandidate], graph.sites[next]) <= 0 {
+					break
+				}
+				graph.deleteEdge(x, leftCandidate)
+				leftCandidate = next
+			}
+		}
+		rightCandidate := graph.pred(y, x)
+		if graph.above(x, y, rightCandidate) {
+			for {
+				next := graph.pred(y, rightCandidate)
+				if inCircle(graph.sites[x], graph.sites[y], graph.sites[rightCandidate], graph.sites[next]) <= 0 {
+					break
+				}
+				graph.deleteEdge(y, rightCandidate)
+				rightCandidate = next
+			}
+		}
+
+		leftValid, rightValid := graph.above(x, y, leftCandidate), graph.above(x, y, rightCandidate)
+		switch {
+		case !leftValid && !rightValid:
+			// The base edge is the upper common tangent
+			return true
+		case !leftValid || (rightValid && inCircle(graph.sites[x], graph.sites[y], graph.sites[leftCandidate],
+			graph.sites[rightCandidate]) > 0):
+			graph.insertEdge(x, rightCandidate)
+			y = rightCandidate
+		default:
+			graph.insertEdge(leftCandidate, y)
+			x = leftCandidate
+		}
+	}
+}
+
+// hull returns the convex hull of the sites lo to hi in anticlockwise order, including sites part way along
+// its sides, and the position in it of the rightmost site (Andrew's monotone chain). When the sites are
+// collinear the hull goes along them and back again.
+func (graph *delaunayGraph) hull(lo, hi int) (hull []int, rightMost int) {
+	if hi-lo == 1 {
+		return []int{lo}, 0
+	}
+	chain := func(start, end, step int) []int {
+		var chain []int
+		for i := start; i != end; i += step {
+			for len(chain) >= 2 && orientation(graph.sites[chain[len(chain)-2]], graph.sites[chain[len(chain)-1]],
+				graph.sites[i]) < 0 {
+				chain = chain[:len(chain)-1]
+			}
+			chain = append(chain, i)
+		}
+		return chain
+	}
+	lower, upper := chain(lo, hi, 1), chain(hi-1, lo-1, -1)
+	return append(lower[:len(lower)-1], upper[:len(upper)-1]...), len(lower) - 1
+}
+
+// collinear reports whether every site lies on one line
+func (graph *delaunayGraph) collinear() bool {
+	first, last := graph.sites[0], graph.sites[len(graph.sites)-1]
+	for _, site := range graph.sites {
+		if orientation(first, last, site) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isDelaunay checks the merged graph is a triangulation of the convex hull in which no site lies inside
+// the circle through any triangle. Turning to the clockwise neighbour at the end of a directed edge traces
+// the face to its left, which must either be an anticlockwise triangle or the outside of the hull (traced
+// clockwise). Euler's formula V - E + F = 2 then only holds if the graph is planar with one outside face.
+// The sites of each strip are checked concurrently.
+func (graph *delaunayGraph) isDelaunay(bounds []int) bool {
+	type faceCounts struct {
+		edges, triangles, hullEdges int
+		ok                          bool
+	}
+	counts := make([]faceCounts, len(bounds)-1)
+	var wait sync.WaitGroup
+	for strip := range counts {
+		wait.Add(1)
+		go func(strip int) {
+			defer wait.Done()
+			count := &counts[strip]
+			count.ok = true
+			for i := bounds[strip]; i < bounds[strip+1] && count.ok; i++ {
+				for _, j := range graph.neighbours[i] {
+					count.edges++
+					m := graph.pred(j, i)
+					turn := orientation(graph.sites[i], graph.sites[j], graph.sites[m])
+					if turn <= 0 || graph.pred(m, j) != i {
+						count.hullEdges++
+						count.ok = count.ok && turn <= 0
+						continue
+					}
+					if j > i && m > i {
+						count.triangles++
+					}
+					// The site across the edge from the triangle must lie outside its circle
+					if opposite := graph.pred(i, j); opposite != m && inCircle(graph.sites[i], graph.sites[j],
+						graph.sites[m], graph.sites[opposite]) > 0 {
+						count.ok = false
+					}
+				}
+			}
+		}(strip)
+	}
+	wait.Wait()
+
+	hull, _ := graph.hull(0, len(graph.sites))
+	var total faceCounts
+	for _, count := range counts {
+		if !count.ok {
+			return false
+		}
+		total.edges += count.edges
+		total.triangles += count.triangles
+		total.hullEdges += count.hullEdges
+	}
+	return total.hullEdges == len(hull) && len(graph.sites)-total.edges/2+total.triangles+1 == 2
+}
+
+// voronoi builds the dcel of the voronoi diagram with the same structure fortunesAlgorithm leaves: a
+// half-edge pair for each pair of neighbours and a vertex for each triangle, with the edges that meet
+// there linked together. Hull edges have no triangle outside so one of their half-edges has no origin,
+// ready for connectEdgesToBoundary.
+func (graph *delaunayGraph) voronoi(arena *arena) *doublyConnectedEdgeList {
+	dcel := &doublyConnectedEdgeList{
+		vertices: make([]*vertex, 0, 3*len(graph.sites)),
+		edges:    make([]*halfEdge, 0, 6*len(graph.sites)),
+		arena:    arena,
+	}
+
+	// The half-edge with site i on its right for the edge between i and each of its neighbours
+	halfEdges := make([][]*halfEdge, len(graph.sites))
+	for i, neighbours := range graph.neighbours {
+		halfEdges[i] = make([]*halfEdge, len(neighbours))
+	}
+	for i, neighbours := range graph.neighbours {
+		for k, j := range neighbours {
+			if i < j {
+				halfEdges[i][k] = dcel.addIsolatedEdge(graph.sites[i], graph.sites[j])
+				halfEdges[j][graph.position(j, i)] = halfEdges[i][k].twinEdge
+			}
+		}
+	}
+
+	// Visit each anticlockwise triangle (i, j, m) once, starting from its lowest site. The half-edges with
+	// i, j and m on their right leave its vertex travelling clockwise around their cells.
+	for i, neighbours := range graph.neighbours {
+		for k, j := range neighbours {
+			m := graph.pred(j, i)
+			if j < i || m < i || orientation(graph.sites[i], graph.sites[j], graph.sites[m]) <= 0 {
+				continue
+			}
+			x, y, _ := circumcenter(graph.sites[j], graph.sites[i], graph.sites[m])
+			voronoiVertex := dcel.addIsolatedVertex(x, y)
+			fromI, fromJ, fromM := halfEdges[i][k], halfEdges[j][graph.position(j, m)],
+				halfEdges[m][graph.position(m, i)]
+			fromI.originVertex = voronoiVertex
+			fromJ.originVertex = voronoiVertex
+			fromM.originVertex = voronoiVertex
+
+			// Each half-edge arriving at the vertex continues around its cell with the one leaving it
+			fromI.twinEdge.nextEdge = fromJ
+			fromJ.twinEdge.nextEdge = fromM
+			fromM.twinEdge.nextEdge = fromI
+		}
+	}
+	return dcel
+}
+
+// angle of the direction from site i to site j
+func (graph *delaunayGraph) angle(i, j int) float64 {
+	return math.Atan2(graph.sites[j].y-graph.sites[i].y, graph.sites[j].x-graph.sites[i].x)
+}
+
+// position of j in the neighbours of i, or -1
+func (graph *delaunayGraph) position(i, j int) int {
+	for k, neighbour := range graph.neighbours[i] {
+		if neighbour == j {
+			return k
+		}
+	}
+	return -1
+}
+
+// succ - the neighbour of i after j going anticlockwise
+func (graph *delaunayGraph) succ(i, j int) int {
+	neighbours := graph.neighbours[i]
+	return neighbours[(graph.position(i, j)+1)%len(neighbours)]
+}
+
+// pred - the neighbour of i after j going clockwise
+func (graph *delaunayGraph) pred(i, j int) int {
+	neighbours := graph.neighbours[i]
+	return neighbours[(graph.position(i, j)+len(neighbours)-1)%len(neighbours)]
+}
+
+// face returns the third site of the triangle to the left of the edge from i to j, or false if the left of
+// the edge is outside the hull
+func (graph *delaunayGraph) face(i, j int) (int, bool) {
+	if graph.position(j, i) < 0 {
+		return -1, false
+	}
+	m := graph.pred(j, i)
+	return m, orientation(graph.sites[i], graph.sites[j], graph.sites[m]) > 0 && graph.pred(m, j) == i
+}
+
+// above reports whether site c is to the left of the directed edge a-b
+func (graph *delaunayGraph) above(a, b, c int) bool {
+	return orientation(graph.sites[a], graph.sites[b], graph.sites[c]) > 0
+}
+
+func (graph *delaunayGraph) insertEdge(i, j int) {
+	insert := func(i, j int) {
+		neighbours := graph.neighbours[i]
+		angle := graph.angle(i, j)
+		k := sort.Search(len(neighbours), func(k int) bool { return graph.angle(i, neighbours[k]) > angle })
+		neighbours = append(neighbours, 0)
+		copy(neighbours[k+1:], neighbours[k:])
+		neighbours[k] = j
+		graph.neighbours[i] = neighbours
+	}
+	insert(i, j)
+	insert(j, i)
+}
+
+func (graph *delaunayGraph) deleteEdge(i, j int) {
+	remove := func(i, j int) {
+		k := graph.position(i, j)
+		graph.neighbours[i] = append(graph.neighbours[i][:k], graph.neighbours[i][k+1:]...)
+	}
+	remove(i, j)
+	remove(j, i)
+}
+
+// orientation - positive when a, b and c turn anticlockwise, negative when they turn clockwise and zero
+// when they are collinear
+func orientation(a, b, c *site) float64 {
+	return (b.x-a.x)*(c.y-a.y) - (b.y-a.y)*(c.x-a.x)
+}
+
+// inCircle - positive when d lies inside the circle through the anticlockwise triangle a, b, c. Results
+// too small to be told apart from rounding error (such as for cocircular sites) are returned as zero.
+func inCircle(a, b, c, d *site) float64 {
+	adx, ady := a.x-d.x, a.y-d.y
+	bdx, bdy := b.x-d.x, b.y-d.y
+	cdx, cdy := c.x-d.x, c.y-d.y
+	aLift, bLift, cLift := adx*adx+ady*ady, bdx*bdx+bdy*bdy, cdx*cdx+cdy*cdy
+	determinant := aLift*(bdx*cdy-cdx*bdy) + bLift*(cdx*ady-adx*cdy) + cLift*(adx*bdy-bdx*ady)
+	permanent := aLift*(math.Abs(bdx*cdy)+math.Abs(cdx*bdy)) + bLift*(math.Abs(cdx*ady)+math.Abs(adx*cdy)) +
+		cLift*(math.Abs(adx*bdy)+math.Abs(bdx*ady))
+	if math.Abs(determinant) <= 1e-12*permanent {
+		return 0
+	}
+	return determinant
+}
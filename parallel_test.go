@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// diagramEdges returns the edges of a diagram which have a length, keyed by the ids of the sites either side
+// and running from the origin of the half-edge of the lower id
+func diagramEdges(t *testing.T, diagram *Diagram) map[[2]SiteID][2]vertex {
+	ids := map[*site]SiteID{}
+	for i := range diagram.sites {
+		ids[&diagram.sites[i]] = SiteID(i)
+	}
+	minimumLength := 1e-9 * math.Max(diagram.boundingBox.width, diagram.boundingBox.height)
+	edges := map[[2]SiteID][2]vertex{}
+	for _, halfEdge := range diagram.dcel.edges {
+		a, b := ids[halfEdge.site], ids[halfEdge.twinEdge.site]
+		start, end := *halfEdge.originVertex, *halfEdge.twinEdge.originVertex
+		if a > b || math.Hypot(end.x-start.x, end.y-start.y) < minimumLength {
+			continue
+		}
+		if _, ok := edges[[2]SiteID{a, b}]; ok {
+			t.Fatalf("sites %d and %d share more than one edge", a, b)
+		}
+		edges[[2]SiteID{a, b}] = [2]vertex{start, end}
+	}
+	return edges
+}
+
+func TestParallelMatchesSequential(t *testing.T) {
+	for _, distribution := range siteDistributions {
+		for _, size := range []int{20, 300, 3000} {
+			siteList := distribution.generate(size, rand.New(rand.NewSource(int64(size))))
+			want, err := Compute(siteList, benchmarkBox, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantEdges := diagramEdges(t, want)
+
+			for _, parallelism := range []int{2, 3, 4, 8} {
+				if _, ok, err := triangulateInStrips(nil, want.sites, parallelism); !ok || err != nil {
+					t.Errorf("%s %d sites in %d strips: triangulation failed (%v)", distribution.name, size,
+						parallelism, err)
+				}
+				diagram, err := Compute(siteList, benchmarkBox, Options{Parallelism: parallelism})
+				if err != nil {
+					t.Fatal(err)
+				}
+				edges := diagramEdges(t, diagram)
+				if len(edges) != len(wantEdges) {
+					t.Errorf("%s %d sites in %d strips: %d edges, want %d", distribution.name, size, parallelism,
+						len(edges), len(wantEdges))
+				}
+				for sites, wantEdge := range wantEdges {
+					edge, ok := edges[sites]
+					if !ok {
+						t.Errorf("%s %d sites in %d strips: no edge between sites %v", distribution.name, size,
+							parallelism, sites)
+						continue
+					}
+					for end := range edge {
+						// Vertices far outside the box (from nearly collinear sites) are only equal relative to their size
+						scale := math.Max(1, math.Hypot(wantEdge[end].x, wantEdge[end].y))
+						if math.Hypot(edge[end].x-wantEdge[end].x, edge[end].y-wantEdge[end].y) > 1e-9*scale {
+							t.Errorf("%s %d sites in %d strips: edge between sites %v is %v, want %v",
+								distribution.name, size, parallelism, sites, edge, wantEdge)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestParallelCollinear(t *testing.T) {
+	siteList := make([]site, 50)
+	for i := range siteList {
+		siteList[i] = site{x: float64(i) * 10, y: float64(i) * 5}
+	}
+	want, err := Compute(siteList, benchmarkBox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagram, err := Compute(siteList, benchmarkBox, Options{Parallelism: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagramEdges(t, diagram)) != len(diagramEdges(t, want)) {
+		t.Errorf("got %d edges, want %d", len(diagramEdges(t, diagram)), len(diagramEdges(t, want)))
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+)
+
+// computePeriodic finds the cells of a diagram whose bounding box wraps around. Its sites are copied into
+// the eight boxes around it, which is enough as every point of a cell is within half the box of its site, and
+// the diagram of all nine copies is computed over the three by three boxes. The whole cells of the copies
+// in the middle are the cells of the sites, and the pieces of all the copies' cells inside the middle box
+// make up the dcel.
+func (builder *Builder) computePeriodic(limits *sweepLimits, diagram *Diagram, options Options) error {
+	box, n := diagram.boundingBox, len(diagram.sites)
+	images := &Diagram{sites: make([]site, 0, 9*n), boundingBox: boundingBox{width: 3 * box.width,
+		height: 3 * box.height}, metric: diagram.metric}
+	for image := 0; image < 9; image++ {
+		for _, s := range diagram.sites {
+			images.sites = append(images.sites, site{x: s.x + float64(image%3)*box.width,
+				y: s.y + float64(image/3)*box.height})
+		}
+	}
+	if err := builder.computeDCEL(limits, images, options); err != nil {
+		return err
+	}
+
+	imageSites := make([]site, len(images.sites))
+	pieces := make([][]cellCorner, len(images.sites))
+	diagram.fixedCells = make([][]cellCorner, n)
+	for image, cell := range images.cellPolygons() {
+		imageSites[image] = site{x: images.sites[image].x - box.width, y: images.sites[image].y - box.height}
+		shifted := make([]cellCorner, len(cell))
+		for k, corner := range cell {
+			shifted[k] = cellCorner{vertex{x: corner.x - box.width, y: corner.y - box.height}, corner.across}
+		}
+		if image/n == 4 {
+			whole := make([]cellCorner, len(shifted))
+			for k, corner := range shifted {
+				whole[k] = cellCorner{corner.vertex, corner.across % SiteID(n)}
+			}
+			diagram.fixedCells[image%n] = whole
+		}
+		// Where copies of the same site are next to each other the cell borders itself, which the dcel
+		// leaves out as a side of the box would be
+		for k, corner := range shifted {
+			if corner.across >= 0 && corner.across%SiteID(n) == SiteID(image%n) {
+				shifted[k].across = -1
+			}
+		}
+		pieces[image] = clipToBox(shifted, box)
+	}
+
+	dcel, err := dcelFromCells(imageSites, pieces, box)
+	if err != nil {
+		return err
+	}
+	ids := make(map[*site]SiteID, len(imageSites))
+	for image := range imageSites {
+		ids[&imageSites[image]] = SiteID(image)
+	}
+	for _, halfEdge := range dcel.edges {
+		halfEdge.site = &diagram.sites[ids[halfEdge.site]%SiteID(n)]
+	}
+	diagram.dcel = dcel
+	diagram.period = vertex{x: box.width, y: box.height}
+	return nil
+}
+
+// wrapSites returns the sites moved by whole widths and heights of the bounding box into it
+func wrapSites(siteList []site, boundingBox boundingBox) []site {
+	wrapped := make([]site, len(siteList))
+	for i, s := range siteList {
+		wrapped[i] = site{x: s.x - boundingBox.width*math.Floor(s.x/boundingBox.width),
+			y: s.y - boundingBox.height*math.Floor(s.y/boundingBox.height)}
+		// Rounding can leave a site just below zero on the far side
+		if wrapped[i].x >= boundingBox.width {
+			wrapped[i].x = 0
+		}
+		if wrapped[i].y >= boundingBox.height {
+			wrapped[i].y = 0
+		}
+	}
+	return wrapped
+}
+
+// nearestImage returns the copy of q shifted by whole periods nearest p. A zero period is not shifted along.
+func nearestImage(p, q, period vertex) vertex {
+	if period.x > 0 {
+		q.x -= period.x * math.Round((q.x-p.x)/period.x)
+	}
+	if period.y > 0 {
+		q.y -= period.y * math.Round((q.y-p.y)/period.y)
+	}
+	return q
+}
+
+// siteImage returns the copy of a site nearest p, which is the site itself unless the bounding box wraps
+// around
+func (diagram *Diagram) siteImage(id SiteID, p vertex) vertex {
+	return nearestImage(p, vertex(diagram.sites[id]), diagram.period)
+}
+
+// siteDistance returns how far p is from a site under the metric or anisotropy of the diagram, going around
+// the bounding box if it wraps
+func (diagram *Diagram) siteDistance(id SiteID, p vertex) float64 {
+	if diagram.anisotropy != nil {
+		return diagram.anisotropy.distance(vertex(diagram.sites[id]), p)
+	}
+	return diagram.metric.distance(diagram.siteImage(id, p), p)
+}
+
+// siteDistanceToSegment returns how near the segment from a to b comes to a site under the metric or
+// anisotropy of the diagram, going around the bounding box if it wraps
+func (diagram *Diagram) siteDistanceToSegment(id SiteID, a, b vertex) float64 {
+	if diagram.anisotropy != nil {
+		return diagram.anisotropy.distanceToSegment(vertex(diagram.sites[id]), a, b)
+	}
+	return diagram.metric.distanceToSegment(diagram.siteImage(id, a), a, b)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestPeriodicStrips(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	// The second site is given a box to the left, and wraps around into it
+	diagram, err := Compute([]site{{x: 10, y: 50}, {x: -40, y: 50}}, box, Options{Periodic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sites := diagram.Sites(); sites[1] != (site{x: 60, y: 50}) {
+		t.Fatalf("got sites %v, want the second wrapped to (60, 50)", sites)
+	}
+	// Borders at x = 35 and x = 85, with the first cell running over the left side of the box
+	want := map[[2]vertex]bool{{{35, 0}, {35, 100}}: true, {{85, 0}, {85, 100}}: true}
+	if len(diagram.dcel.edges) != 2*len(want) {
+		t.Fatalf("got %d half-edges, want %d", len(diagram.dcel.edges), 2*len(want))
+	}
+	for _, halfEdge := range diagram.dcel.edges {
+		start, end := *halfEdge.originVertex, *halfEdge.twinEdge.originVertex
+		if !want[[2]vertex{start, end}] && !want[[2]vertex{end, start}] {
+			t.Errorf("unexpected edge %v - %v", start, end)
+		}
+	}
+	stats := diagram.CellStats()
+	if !nearlyEqual(stats[0].Area, 5000) || stats[0].Centroid != (vertex{x: 10, y: 50}) ||
+		stats[0].Min != (vertex{x: -15, y: 0}) || stats[0].TouchesBoundary {
+		t.Errorf("got first cell %+v", stats[0])
+	}
+	if got := diagram.Locate(vertex{x: 95, y: 10}); got != 0 {
+		t.Errorf("(95, 10) is in cell %d, want 0 across the side of the box", got)
+	}
+	if _, err := diagram.NaturalNeighbours(vertex{x: 50, y: 50}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("natural neighbours in a periodic diagram returned %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestPeriodicDiagram(t *testing.T) {
+	period := vertex{x: benchmarkBox.width, y: benchmarkBox.height}
+	for _, metric := range []Metric{Euclidean, Manhattan} {
+		for _, distribution := range siteDistributions {
+			siteList := distribution.generate(200, rand.New(rand.NewSource(8)))
+			diagram, err := Compute(siteList, benchmarkBox, Options{DuplicatePolicy: KeepFirstDuplicate,
+				Metric: metric, Periodic: true})
+			if err != nil {
+				t.Fatalf("%v %s: %v", metric, distribution.name, err)
+			}
+
+			// The whole cells cover the box once between them
+			totalArea := 0.0
+			for _, cell := range diagram.CellStats() {
+				totalArea += cell.Area
+			}
+			if !nearlyEqual(totalArea, benchmarkBox.width*benchmarkBox.height) {
+				t.Errorf("%v %s: cells cover %v, want %v", metric, distribution.name, totalArea,
+					benchmarkBox.width*benchmarkBox.height)
+			}
+
+			// Every edge meeting a side of the box is met by one at the same place on the opposite side
+			var left, right, bottom, top []float64
+			for _, halfEdge := range diagram.dcel.edges {
+				switch p := *halfEdge.originVertex; {
+				case p.x == 0:
+					left = append(left, p.y)
+				case p.x == benchmarkBox.width:
+					right = append(right, p.y)
+				case p.y == 0:
+					bottom = append(bottom, p.x)
+				case p.y == benchmarkBox.height:
+					top = append(top, p.x)
+				}
+			}
+			for _, sides := range [][2][]float64{{left, right}, {bottom, top}} {
+				sort.Float64s(sides[0])
+				sort.Float64s(sides[1])
+				if len(sides[0]) != len(sides[1]) {
+					t.Fatalf("%v %s: %d edges meet one side of the box and %d the other", metric,
+						distribution.name, len(sides[0]), len(sides[1]))
+				}
+				for k := range sides[0] {
+					if math.Abs(sides[0][k]-sides[1][k]) > 1e-6 {
+						t.Fatalf("%v %s: edges meet opposite sides at %v and %v", metric, distribution.name,
+							sides[0][k], sides[1][k])
+					}
+				}
+			}
+
+			// Every point is in the cell of its nearest site, going around the box
+			source := rand.New(rand.NewSource(9))
+			for k := 0; k < 1000; k++ {
+				p := vertex{x: source.Float64() * benchmarkBox.width, y: source.Float64() * benchmarkBox.height}
+				got, want := diagram.Locate(p), nearestSite(metric, period, diagram.sites, nil, p)
+				if got != want && math.Abs(diagram.siteDistance(got, p)-diagram.siteDistance(want, p)) > 1e-9 {
+					t.Fatalf("%v %s: (%v, %v) located in cell %d, nearest is %d", metric, distribution.name, p.x,
+						p.y, got, want)
+				}
+			}
+		}
+	}
+}
@@ -1,55 +1,79 @@
-// This example demonstrates a priority queue built using the heap interface.
 package main
 
-import (
-	"container/heap"
-)
+// A priorityQueue is a binary max heap ordered by the less function (so pop returns the item which is
+// greatest). Items are stored by value and sifted directly, avoiding the interface conversions of
+// container/heap.
+type priorityQueue[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
 
-// An Item is something we manage in a priority queue.
-type Item struct {
-	value    Event   // The value of the item; arbitrary.
-	priority float64 // The priority of the item in the queue.
-	// The index is needed by update and is maintained by the heap.Interface methods.
-	index int // The index of the item in the heap.
+func newPriorityQueue[T any](less func(a, b T) bool) priorityQueue[T] {
+	return priorityQueue[T]{less: less}
 }
 
-// A PriorityQueue implements heap.Interface and holds Items.
-type PriorityQueue []*Item
+func (pq *priorityQueue[T]) len() int { return len(pq.items) }
 
-func (pq PriorityQueue) Len() int { return len(pq) }
+// clear - remove every item, keeping the memory for reuse
+func (pq *priorityQueue[T]) clear() {
+	clear(pq.items)
+	pq.items = pq.items[:0]
+}
 
-func (pq PriorityQueue) Less(i, j int) bool {
-	// We want Pop to give us the highest, not lowest, priority so we use greater than here.
-	return pq[i].priority > pq[j].priority
+// peek - return the max value without removing it
+func (pq *priorityQueue[T]) peek() T {
+	return pq.items[0]
 }
 
-func (pq PriorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
-	pq[i].index = i
-	pq[j].index = j
+// push - add item to priority queue
+func (pq *priorityQueue[T]) push(item T) {
+	pq.items = append(pq.items, item)
+	pq.up(len(pq.items) - 1)
 }
 
-// Push - Add item to priority queue
-func (pq *PriorityQueue) Push(x interface{}) {
-	n := len(*pq)
-	item := x.(*Item)
-	item.index = n
-	*pq = append(*pq, item)
+// pop - remove max value from priority queue
+func (pq *priorityQueue[T]) pop() T {
+	top := pq.items[0]
+	last := len(pq.items) - 1
+	pq.items[0] = pq.items[last]
+	var zero T
+	pq.items[last] = zero // for safety - don't keep a reference to the item alive
+	pq.items = pq.items[:last]
+	if last > 0 {
+		pq.down(0)
+	}
+	return top
 }
 
-// Pop - Remove max value from priority queue
-func (pq *PriorityQueue) Pop() interface{} {
-	old := *pq
-	n := len(old)
-	item := old[n-1]
-	item.index = -1 // for safety
-	*pq = old[0 : n-1]
-	return item
+func (pq *priorityQueue[T]) up(i int) {
+	item := pq.items[i]
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !pq.less(pq.items[parent], item) {
+			break
+		}
+		pq.items[i] = pq.items[parent]
+		i = parent
+	}
+	pq.items[i] = item
 }
 
-// update modifies the priority and value of an Item in the queue.
-func (pq *PriorityQueue) update(item *Item, value Event, priority float64) {
-	item.value = value
-	item.priority = priority
-	heap.Fix(pq, item.index)
+func (pq *priorityQueue[T]) down(i int) {
+	n := len(pq.items)
+	item := pq.items[i]
+	for {
+		child := (2 * i) + 1
+		if child >= n {
+			break
+		}
+		if right := child + 1; right < n && pq.less(pq.items[child], pq.items[right]) {
+			child = right
+		}
+		if !pq.less(item, pq.items[child]) {
+			break
+		}
+		pq.items[i] = pq.items[child]
+		i = child
+	}
+	pq.items[i] = item
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"container/heap"
+	"math/rand"
+	"testing"
+)
+
+func TestPriorityQueueOrder(t *testing.T) {
+	pq := newPriorityQueue(func(a, b float64) bool { return a < b })
+	values := rand.New(rand.NewSource(1)).Perm(100)
+	for _, value := range values {
+		pq.push(float64(value))
+	}
+	for want := 99; want >= 0; want-- {
+		if got := pq.pop(); got != float64(want) {
+			t.Fatalf("popped %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEventQueueOrder(t *testing.T) {
+	siteList := []site{{x: 3, y: 1}, {x: 1, y: 5}, {x: 2, y: 1}, {x: 1, y: 5}, {x: 0, y: 3}}
+	queue := newEventQueue(siteList)
+	queue.pushCircle(&circleEvent{center: vertex{x: 9, y: 3}, y: 2})
+	queue.pushCircle(&circleEvent{center: vertex{x: 9, y: 9}, y: 4, falseAlarm: true})
+	queue.pushCircle(&circleEvent{center: vertex{x: 2, y: 3}, y: 1})
+
+	// The duplicate site and the false alarm are dropped, and the circle event at the same point as a site
+	// goes first
+	want := []vertex{{x: 1, y: 5}, {x: 0, y: 3}, {x: 9, y: 3}, {x: 2, y: 3}, {x: 2, y: 1}, {x: 3, y: 1}}
+	for i, location := range want {
+		next, ok := queue.pop()
+		if !ok {
+			t.Fatalf("queue empty after %d events", i)
+		}
+		var got vertex
+		if next.eventType == SiteEvent {
+			got = vertex{x: next.site.x, y: next.site.y}
+		} else {
+			got = next.circle.center
+		}
+		if got != location {
+			t.Errorf("event %d is %s event at %v, want %v", i, next.eventType, got, location)
+		}
+	}
+	if _, ok := queue.pop(); ok {
+		t.Error("queue not empty")
+	}
+}
+
+// The event queue before site and circle events were split, kept to benchmark against
+type legacyEvent struct {
+	eventType string
+	location  site
+	leafNode  *node
+}
+
+type legacyItem struct {
+	value    legacyEvent
+	priority float64
+	index    int
+}
+
+type legacyPriorityQueue []*legacyItem
+
+func (pq legacyPriorityQueue) Len() int           { return len(pq) }
+func (pq legacyPriorityQueue) Less(i, j int) bool { return pq[i].priority > pq[j].priority }
+func (pq legacyPriorityQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index = i
+	pq[j].index = j
+}
+
+func (pq *legacyPriorityQueue) Push(x interface{}) {
+	item := x.(*legacyItem)
+	item.index = len(*pq)
+	*pq = append(*pq, item)
+}
+
+func (pq *legacyPriorityQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	item.index = -1
+	*pq = old[0 : n-1]
+	return item
+}
+
+// The benchmark replays the queue traffic of a sweep: a site event pushes up to two circle events below
+// the sweepline and a circle event up to one, and every other event cancels a circle event still waiting.
+func BenchmarkEventQueue(b *testing.B) {
+	siteList := uniformSites(10000, rand.New(rand.NewSource(1)))
+
+	b.Run("container-heap", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			source := rand.New(rand.NewSource(2))
+			pq := make(legacyPriorityQueue, len(siteList))
+			for j, coordinates := range siteList {
+				pq[j] = &legacyItem{value: legacyEvent{eventType: "site", location: coordinates},
+					priority: coordinates.y, index: j}
+			}
+			heap.Init(&pq)
+			var pending []*legacyItem
+			for pq.Len() > 0 {
+				item := heap.Pop(&pq).(*legacyItem)
+				newCircles := source.Intn(2)
+				if item.value.eventType == "site" {
+					newCircles = source.Intn(3)
+				}
+				for k := newCircles; k > 0; k-- {
+					circle := &legacyItem{value: legacyEvent{eventType: "circle"},
+						priority: item.priority - source.Float64()*10}
+					heap.Push(&pq, circle)
+					pending = append(pending, circle)
+				}
+				if len(pending) > 0 && source.Intn(2) == 0 {
+					k := source.Intn(len(pending))
+					if pending[k].index >= 0 {
+						heap.Remove(&pq, pending[k].index)
+					}
+					pending[k] = pending[len(pending)-1]
+					pending = pending[:len(pending)-1]
+				}
+			}
+		}
+	})
+
+	b.Run("event-queue", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			source := rand.New(rand.NewSource(2))
+			queue := newEventQueue(siteList)
+			var pending []*circleEvent
+			for {
+				next, ok := queue.pop()
+				if !ok {
+					break
+				}
+				y, newCircles := 0.0, source.Intn(2)
+				if next.eventType == SiteEvent {
+					y, newCircles = next.site.y, source.Intn(3)
+				} else {
+					y = next.circle.y
+				}
+				for k := newCircles; k > 0; k-- {
+					circle := &circleEvent{y: y - source.Float64()*10}
+					queue.pushCircle(circle)
+					pending = append(pending, circle)
+				}
+				if len(pending) > 0 && source.Intn(2) == 0 {
+					k := source.Intn(len(pending))
+					pending[k].falseAlarm = true
+					pending[k] = pending[len(pending)-1]
+					pending = pending[:len(pending)-1]
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/fogleman/gg"
+)
+
+// SiteEdge - an edge of a graph on the sites, lowest id first, with the distance between its sites under the
+// metric of the diagram
+type SiteEdge struct {
+	A, B   SiteID
+	Length float64
+}
+
+// DelaunayEdges returns the edges of the delaunay triangulation of the sites - the pairs of sites whose
+// cells share an edge of the dcel - in order of id. Where four or more sites lie on a circle the
+// triangulation is not unique, and every diagonal the sweep produced is included. A weighted diagram's
+// edges are those of the unweighted diagram of its sites.
+func (diagram *Diagram) DelaunayEdges() []SiteEdge {
+	if diagram.unweighted != nil {
+		return diagram.unweighted.DelaunayEdges()
+	}
+	var edges []SiteEdge
+	seen := map[[2]SiteID]bool{}
+	for a, neighbours := range diagram.dcelNeighbours() {
+		for _, b := range neighbours {
+			if SiteID(a) < b && !seen[[2]SiteID{SiteID(a), b}] {
+				seen[[2]SiteID{SiteID(a), b}] = true
+				edges = append(edges, diagram.siteEdge(SiteID(a), b))
+			}
+		}
+	}
+	sortSiteEdges(edges)
+	return edges
+}
+
+// MinimumSpanningTree returns the euclidean minimum spanning tree of the sites in order of id. It is found
+// among the delaunay edges, which always contain it (Kruskal), of the unweighted diagram if it is weighted.
+func (diagram *Diagram) MinimumSpanningTree() []SiteEdge {
+	if diagram.unweighted != nil {
+		return diagram.unweighted.MinimumSpanningTree()
+	}
+	edges := diagram.DelaunayEdges()
+	sort.SliceStable(edges, func(i, j int) bool { return edges[i].Length < edges[j].Length })
+	parents := make([]SiteID, len(diagram.sites))
+	for id := range parents {
+		parents[id] = SiteID(id)
+	}
+	root := func(id SiteID) SiteID {
+		for parents[id] != id {
+			parents[id] = parents[parents[id]]
+			id = parents[id]
+		}
+		return id
+	}
+	var tree []SiteEdge
+	for _, edge := range edges {
+		if a, b := root(edge.A), root(edge.B); a != b {
+			parents[a] = b
+			tree = append(tree, edge)
+		}
+	}
+	sortSiteEdges(tree)
+	return tree
+}
+
+// GabrielGraph returns the gabriel graph of the sites in order of id - the pairs of sites with no other site
+// inside or on the circle they are on opposite sides of. Only the sites next to both in the delaunay
+// triangulation can be. The circles are euclidean, so a weighted diagram's graph is that of the unweighted
+// diagram of its sites, and the returned error wraps ErrInvalidInput if the metric is not euclidean or
+// there is an anisotropy.
+func (diagram *Diagram) GabrielGraph() ([]SiteEdge, error) {
+	if diagram.unweighted != nil {
+		return diagram.unweighted.GabrielGraph()
+	}
+	if diagram.metric != Euclidean {
+		return nil, &InputError{Index: -1, Reason: fmt.Sprintf("the gabriel graph needs a euclidean diagram, not %v",
+			diagram.metric), Err: ErrInvalidInput}
+	}
+	if diagram.anisotropy != nil {
+		return nil, &InputError{Index: -1, Reason: "the gabriel graph needs an isotropic diagram",
+			Err: ErrInvalidInput}
+	}
+	return diagram.filterDelaunayEdges(func(edge SiteEdge, c *site) bool {
+		// The copies of the sites nearest each other, when the bounding box wraps around
+		a := vertex(diagram.sites[edge.A])
+		b := diagram.siteImage(edge.B, a)
+		other := nearestImage(vertex{x: (a.x + b.x) / 2, y: (a.y + b.y) / 2}, vertex(*c), diagram.period)
+		return (a.x-other.x)*(b.x-other.x)+(a.y-other.y)*(b.y-other.y) <= 0
+	}, true), nil
+}
+
+// RelativeNeighbourhoodGraph returns the relative neighbourhood graph of the sites in order of id - the
+// pairs of sites with no other site closer to both of them than they are to each other. Only the sites
+// next to either in the delaunay triangulation can be, so a weighted diagram's graph is that of the
+// unweighted diagram of its sites.
+func (diagram *Diagram) RelativeNeighbourhoodGraph() []SiteEdge {
+	if diagram.unweighted != nil {
+		return diagram.unweighted.RelativeNeighbourhoodGraph()
+	}
+	return diagram.filterDelaunayEdges(func(edge SiteEdge, c *site) bool {
+		return math.Max(diagram.siteDistance(edge.A, vertex(*c)), diagram.siteDistance(edge.B, vertex(*c))) <
+			edge.Length
+	}, false)
+}
+
+// filterDelaunayEdges keeps the delaunay edges for which blocks is false for every delaunay neighbour of
+// their sites - of both sites if common is set, or of either if not
+func (diagram *Diagram) filterDelaunayEdges(blocks func(edge SiteEdge, c *site) bool, common bool) []SiteEdge {
+	neighbours := diagram.dcelNeighbours()
+	isNeighbour := map[[2]SiteID]bool{}
+	for a := range neighbours {
+		for _, b := range neighbours[a] {
+			isNeighbour[[2]SiteID{SiteID(a), b}] = true
+		}
+	}
+	var edges []SiteEdge
+	for _, edge := range diagram.DelaunayEdges() {
+		blocked := false
+		for _, end := range []SiteID{edge.A, edge.B} {
+			for _, c := range neighbours[end] {
+				other := edge.A + edge.B - end
+				if c == other || (common && !isNeighbour[[2]SiteID{other, c}]) {
+					continue
+				}
+				if blocks(edge, &diagram.sites[c]) {
+					blocked = true
+				}
+			}
+		}
+		if !blocked {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+func (diagram *Diagram) siteEdge(a, b SiteID) SiteEdge {
+	return SiteEdge{A: a, B: b, Length: diagram.siteDistance(a, vertex(diagram.sites[b]))}
+}
+
+func sortSiteEdges(edges []SiteEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].A < edges[j].A || (edges[i].A == edges[j].A && edges[i].B < edges[j].B)
+	})
+}
+
+// renderSiteEdges draws a graph on the sites over a rendered diagram, flipping the y axis to match it
+func renderSiteEdges(voronoi *gg.Context, boundingBox boundingBox, siteList []site, edges []SiteEdge) {
+	voronoi.SetRGB(0.2, 0.3, 0.6)
+	voronoi.SetLineWidth(2)
+	for _, edge := range edges {
+		a, b := siteList[edge.A], siteList[edge.B]
+		voronoi.DrawLine(a.x, boundingBox.height-a.y, b.x, boundingBox.height-b.y)
+		voronoi.Stroke()
+	}
+}
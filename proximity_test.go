@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestProximityGraphs(t *testing.T) {
+	for _, distribution := range siteDistributions {
+		siteList := distribution.generate(200, rand.New(rand.NewSource(5)))
+		diagram, err := Compute(siteList, benchmarkBox, Options{DuplicatePolicy: KeepFirstDuplicate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sites := diagram.sites
+		distance := func(a, b int) float64 { return math.Hypot(sites[a].x-sites[b].x, sites[a].y-sites[b].y) }
+
+		// Every pair of sites checked against every other site
+		var gabriel, relative []SiteEdge
+		for a := range sites {
+			for b := a + 1; b < len(sites); b++ {
+				isGabriel, isRelative := true, true
+				for c := range sites {
+					if c == a || c == b {
+						continue
+					}
+					ca, cb := vertex{sites[a].x - sites[c].x, sites[a].y - sites[c].y},
+						vertex{sites[b].x - sites[c].x, sites[b].y - sites[c].y}
+					if ca.x*cb.x+ca.y*cb.y <= 0 {
+						isGabriel = false
+					}
+					if math.Max(distance(a, c), distance(b, c)) < distance(a, b) {
+						isRelative = false
+					}
+				}
+				if isGabriel {
+					gabriel = append(gabriel, diagram.siteEdge(SiteID(a), SiteID(b)))
+				}
+				if isRelative {
+					relative = append(relative, diagram.siteEdge(SiteID(a), SiteID(b)))
+				}
+			}
+		}
+		graph, err := diagram.GabrielGraph()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSiteEdges(t, distribution.name+" gabriel graph", graph, gabriel)
+		checkSiteEdges(t, distribution.name+" relative neighbourhood graph", diagram.RelativeNeighbourhoodGraph(),
+			relative)
+
+		// Prim's algorithm over every pair of sites gives the same total length
+		inTree := make([]bool, len(sites))
+		nearest := make([]float64, len(sites))
+		for k := range nearest {
+			nearest[k] = math.Inf(1)
+		}
+		nearest[0] = 0
+		want := 0.0
+		for range sites {
+			next := -1
+			for k := range sites {
+				if !inTree[k] && (next < 0 || nearest[k] < nearest[next]) {
+					next = k
+				}
+			}
+			inTree[next] = true
+			want += nearest[next]
+			for k := range sites {
+				nearest[k] = math.Min(nearest[k], distance(next, k))
+			}
+		}
+		tree := diagram.MinimumSpanningTree()
+		got := 0.0
+		for _, edge := range tree {
+			got += edge.Length
+		}
+		if len(tree) != len(sites)-1 || !nearlyEqual(got, want) {
+			t.Errorf("%s: spanning tree has %d edges %v long, want %d edges %v long", distribution.name, len(tree),
+				got, len(sites)-1, want)
+		}
+	}
+}
+
+func TestGabrielGraphMetric(t *testing.T) {
+	siteList := uniformSites(100, rand.New(rand.NewSource(6)))
+	for _, options := range []Options{{Metric: Manhattan}, {Metric: Chebyshev},
+		{Anisotropy: [2][2]float64{{2, 0}, {0, 1}}}} {
+		diagram, err := Compute(siteList, benchmarkBox, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := diagram.GabrielGraph(); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%+v: got %v, want ErrInvalidInput", options, err)
+		}
+	}
+
+	// The graph of a weighted diagram is that of its sites
+	plain, err := Compute(siteList, benchmarkBox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := plain.GabrielGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	weights := make([]float64, len(siteList))
+	for k := range weights {
+		weights[k] = float64(1 + k%3)
+	}
+	diagram, err := Compute(siteList, benchmarkBox, Options{Weighting: MultiplicativeWeights, Weights: weights})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := diagram.GabrielGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSiteEdges(t, "weighted gabriel graph", got, want)
+}
+
+func TestProximityGraphsWeighted(t *testing.T) {
+	// The first site's cell swallows the second's, so the weighted diagram's dcel has no edge between them
+	siteList := []site{{10, 10}, {12, 11}, {80, 80}, {50, 20}, {20, 70}}
+	box := boundingBox{width: 100, height: 100}
+	diagram, err := Compute(siteList, box, Options{Weighting: AdditiveWeights, Weights: []float64{10, 0, 0, 0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree := diagram.MinimumSpanningTree(); len(tree) != len(siteList)-1 {
+		t.Fatalf("got %d edges in the spanning tree, want %d", len(tree), len(siteList)-1)
+	}
+	plain, err := Compute(siteList, box, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSiteEdges(t, "weighted delaunay edges", diagram.DelaunayEdges(), plain.DelaunayEdges())
+	checkSiteEdges(t, "weighted spanning tree", diagram.MinimumSpanningTree(), plain.MinimumSpanningTree())
+	checkSiteEdges(t, "weighted relative neighbourhood graph", diagram.RelativeNeighbourhoodGraph(),
+		plain.RelativeNeighbourhoodGraph())
+}
+
+func TestRenderSiteEdges(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	diagram, err := Compute([]site{{x: 20, y: 40}, {x: 80, y: 40}}, box, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	voronoi := renderVoronoi(box, diagram.dcel, diagram.sites)
+	renderSiteEdges(voronoi, box, diagram.sites, diagram.MinimumSpanningTree())
+	// The y axis is flipped, so the edge is drawn 60 pixels down
+	if r, g, b, _ := voronoi.Image().At(35, 60).RGBA(); r == 0xffff && g == 0xffff && b == 0xffff {
+		t.Error("the spanning tree edge was not drawn")
+	}
+	if r, g, b, _ := voronoi.Image().At(35, 30).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+		t.Error("drawing the spanning tree coloured the background")
+	}
+}
+
+func checkSiteEdges(t *testing.T, name string, got, want []SiteEdge) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d edges, want %d", name, len(got), len(want))
+	}
+	for k := range want {
+		if got[k] != want[k] {
+			t.Fatalf("%s: got edge %d %+v, want %+v", name, k, got[k], want[k])
+		}
+	}
+}
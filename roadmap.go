@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Roadmap - the edges of a diagram as a graph for planning paths which keep as far from the sites as
+// possible, treating the sites as obstacles. Nodes are the ends of the edges clipped to the bounding box.
+type Roadmap struct {
+	diagram   *Diagram
+	nodes     []vertex
+	edges     []RoadmapEdge
+	incident  [][]int // The edges at each node
+	cellEdges [][]int // The edges around each cell, indexed by SiteID
+}
+
+// RoadmapEdge - an edge of a roadmap between two of its nodes, with the sites of the cells either side
+type RoadmapEdge struct {
+	From, To  int
+	Sites     [2]SiteID
+	Length    float64
+	Clearance float64 // The closest any point on the edge comes to a site, under the metric of the diagram
+}
+
+// Path - a path between two points, running along the edges of a roadmap between the points where it
+// joins and leaves it
+type Path struct {
+	Points    []vertex
+	Length    float64
+	Clearance float64 // The closest the path comes to a site along the roadmap, not counting its two ends
+}
+
+// roadmapLink - a step from one node of the search to another
+type roadmapLink struct {
+	to                int
+	length, clearance float64
+}
+
+// roadmapEntry - where a point joins the roadmap, at the nearest point on an edge around its cell
+type roadmapEntry struct {
+	point vertex
+	edge  int
+}
+
+// Roadmap returns the edges of the diagram as a roadmap, leaving out any which come closer than
+// minimumClearance to a site
+func (diagram *Diagram) Roadmap(minimumClearance float64) *Roadmap {
+	roadmap := &Roadmap{diagram: diagram, cellEdges: make([][]int, len(diagram.sites))}
+	nodeAt := map[vertex]int{}
+	node := func(p vertex) int {
+		if index, ok := nodeAt[p]; ok {
+			return index
+		}
+		nodeAt[p] = len(roadmap.nodes)
+		roadmap.nodes = append(roadmap.nodes, p)
+		roadmap.incident = append(roadmap.incident, nil)
+		return len(roadmap.nodes) - 1
+	}
+	for _, segment := range clippedSegments(diagram) {
+		// Every point of an edge is as far from either of its sites as from any other site
+		clearance := diagram.siteDistanceToSegment(segment.sites[0], segment.p, segment.q)
+		if clearance < minimumClearance {
+			continue
+		}
+		edge := RoadmapEdge{From: node(segment.p), To: node(segment.q), Sites: segment.sites,
+			Length: math.Hypot(segment.q.x-segment.p.x, segment.q.y-segment.p.y), Clearance: clearance}
+		index := len(roadmap.edges)
+		roadmap.edges = append(roadmap.edges, edge)
+		roadmap.incident[edge.From] = append(roadmap.incident[edge.From], index)
+		roadmap.incident[edge.To] = append(roadmap.incident[edge.To], index)
+		for _, id := range edge.Sites {
+			roadmap.cellEdges[id] = append(roadmap.cellEdges[id], index)
+		}
+	}
+	return roadmap
+}
+
+// Nodes returns the nodes of the roadmap, indexed as in the From and To of its edges
+func (roadmap *Roadmap) Nodes() []vertex {
+	return roadmap.nodes
+}
+
+// Edges returns the edges of the roadmap
+func (roadmap *Roadmap) Edges() []RoadmapEdge {
+	return roadmap.edges
+}
+
+// ShortestPath returns the shortest path from start to goal along the roadmap (A*). Each end joins the
+// roadmap at the nearest point on an edge around its own cell, so the path never passes closer to another
+// site than to the site it starts or finishes beside. Returned errors wrap ErrInvalidInput if start or goal
+// is outside the bounding box, or ErrNoPath if the roadmap does not join them.
+func (roadmap *Roadmap) ShortestPath(start, goal vertex) (Path, error) {
+	return roadmap.search(start, goal, true)
+}
+
+// search finds the shortest path from start to goal, guided towards the goal by the straight line distance
+// to it if heuristic is set (A*), or spreading out evenly if not (Dijkstra)
+func (roadmap *Roadmap) search(start, goal vertex, heuristic bool) (Path, error) {
+	startEntry, err := roadmap.entry(start, "start")
+	if err != nil {
+		return Path{}, err
+	}
+	goalEntry, err := roadmap.entry(goal, "goal")
+	if err != nil {
+		return Path{}, err
+	}
+
+	// The start and goal join the search as two extra nodes, linked to the ends of the edges they join
+	startNode, goalNode := len(roadmap.nodes), len(roadmap.nodes)+1
+	links := map[int][]roadmapLink{}
+	link := func(from, to int, entry roadmapEntry, p vertex) {
+		edge := roadmap.edges[entry.edge]
+		links[from] = append(links[from], roadmapLink{to: to, length: math.Hypot(p.x-entry.point.x,
+			p.y-entry.point.y), clearance: roadmap.diagram.siteDistanceToSegment(edge.Sites[0], entry.point, p)})
+	}
+	for _, end := range []int{roadmap.edges[startEntry.edge].From, roadmap.edges[startEntry.edge].To} {
+		link(startNode, end, startEntry, roadmap.nodes[end])
+	}
+	for _, end := range []int{roadmap.edges[goalEntry.edge].From, roadmap.edges[goalEntry.edge].To} {
+		link(end, goalNode, goalEntry, roadmap.nodes[end])
+	}
+	if startEntry.edge == goalEntry.edge {
+		link(startNode, goalNode, goalEntry, startEntry.point)
+	}
+
+	type searchState struct {
+		node                 int
+		distance, lowerBound float64
+	}
+	distances := make([]float64, len(roadmap.nodes)+2)
+	for k := range distances {
+		distances[k] = math.Inf(1)
+	}
+	previous := make([]int, len(distances))
+	clearances := make([]float64, len(distances)) // Of the step into each node
+	done := make([]bool, len(distances))
+	estimate := func(node int) float64 {
+		if !heuristic || node == goalNode {
+			return 0
+		}
+		p := startEntry.point
+		if node != startNode {
+			p = roadmap.nodes[node]
+		}
+		return math.Hypot(goalEntry.point.x-p.x, goalEntry.point.y-p.y)
+	}
+	queue := newPriorityQueue(func(a, b searchState) bool {
+		// The queue pops its greatest item, so the state nearest the goal is the greatest
+		return a.lowerBound > b.lowerBound
+	})
+	distances[startNode] = 0
+	queue.push(searchState{node: startNode, lowerBound: estimate(startNode)})
+	for queue.len() > 0 && !done[goalNode] {
+		state := queue.pop()
+		if done[state.node] {
+			continue
+		}
+		done[state.node] = true
+		visit := func(next roadmapLink) {
+			if distance := state.distance + next.length; distance < distances[next.to] {
+				distances[next.to], previous[next.to], clearances[next.to] = distance, state.node, next.clearance
+				queue.push(searchState{node: next.to, distance: distance, lowerBound: distance + estimate(next.to)})
+			}
+		}
+		for _, next := range links[state.node] {
+			visit(next)
+		}
+		if state.node < len(roadmap.nodes) {
+			for _, index := range roadmap.incident[state.node] {
+				edge := roadmap.edges[index]
+				other := edge.From
+				if other == state.node {
+					other = edge.To
+				}
+				visit(roadmapLink{to: other, length: edge.Length, clearance: edge.Clearance})
+			}
+		}
+	}
+	if !done[goalNode] {
+		return Path{}, fmt.Errorf("%w: from (%v, %v) to (%v, %v)", ErrNoPath, start.x, start.y, goal.x, goal.y)
+	}
+
+	// Walk back from the goal, then put the points in order
+	path := Path{Points: []vertex{goal, goalEntry.point}, Clearance: math.Inf(1)}
+	for node := goalNode; node != startNode; node = previous[node] {
+		path.Clearance = math.Min(path.Clearance, clearances[node])
+		if previous[node] != startNode {
+			path.Points = append(path.Points, roadmap.nodes[previous[node]])
+		}
+	}
+	path.Points = append(path.Points, startEntry.point, start)
+	for i, j := 0, len(path.Points)-1; i < j; i, j = i+1, j-1 {
+		path.Points[i], path.Points[j] = path.Points[j], path.Points[i]
+	}
+	// An end may join the roadmap at a node, or be on the roadmap already
+	points := path.Points[:1]
+	for _, p := range path.Points[1:] {
+		if p != points[len(points)-1] {
+			points = append(points, p)
+		}
+	}
+	path.Points = points
+	for k := 1; k < len(path.Points); k++ {
+		path.Length += math.Hypot(path.Points[k].x-path.Points[k-1].x, path.Points[k].y-path.Points[k-1].y)
+	}
+	return path, nil
+}
+
+// entry finds where a point joins the roadmap - the nearest point to it on an edge around its cell, which
+// it can reach in a straight line without leaving the cell
+func (roadmap *Roadmap) entry(p vertex, name string) (roadmapEntry, error) {
+	id := roadmap.diagram.Locate(p)
+	if id < 0 {
+		return roadmapEntry{}, &InputError{Index: -1, Reason: fmt.Sprintf("%s (%v, %v) is outside the bounding box",
+			name, p.x, p.y), Err: ErrInvalidInput}
+	}
+	entry, nearestDistance := roadmapEntry{edge: -1}, math.Inf(1)
+	for _, index := range roadmap.cellEdges[id] {
+		edge := roadmap.edges[index]
+		nearest := nearestOnSegment(p, roadmap.nodes[edge.From], roadmap.nodes[edge.To])
+		if distance := math.Hypot(nearest.x-p.x, nearest.y-p.y); distance < nearestDistance {
+			entry, nearestDistance = roadmapEntry{point: nearest, edge: index}, distance
+		}
+	}
+	if entry.edge < 0 {
+		return roadmapEntry{}, fmt.Errorf("%w: no roadmap edge around the cell of the %s (%v, %v)", ErrNoPath,
+			name, p.x, p.y)
+	}
+	return entry, nil
+}
+
+// nearestOnSegment returns the point of the segment from a to b nearest to p
+func nearestOnSegment(p, a, b vertex) vertex {
+	dx, dy := b.x-a.x, b.y-a.y
+	if dx == 0 && dy == 0 {
+		return a
+	}
+	t := math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/(dx*dx+dy*dy)))
+	return vertex{x: a.x + t*dx, y: a.y + t*dy}
+}
+
+// distanceToSegment - the distance from p to the nearest point of the segment from a to b
+func distanceToSegment(p, a, b vertex) float64 {
+	nearest := nearestOnSegment(p, a, b)
+	return math.Hypot(nearest.x-p.x, nearest.y-p.y)
+}
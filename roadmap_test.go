@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRoadmapGrid(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	diagram, err := Compute([]site{{x: 25, y: 25}, {x: 75, y: 25}, {x: 25, y: 75}, {x: 75, y: 75}}, box, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The edges make a cross through the centre, each 25 from its sites
+	roadmap := diagram.Roadmap(0)
+	if len(roadmap.Edges()) != 4 || len(roadmap.Nodes()) != 5 {
+		t.Fatalf("got %d nodes and %d edges, want 5 and 4", len(roadmap.Nodes()), len(roadmap.Edges()))
+	}
+	for _, edge := range roadmap.Edges() {
+		if !nearlyEqual(edge.Length, 50) || !nearlyEqual(edge.Clearance, 25) {
+			t.Errorf("got edge %+v", edge)
+		}
+	}
+
+	path, err := roadmap.ShortestPath(vertex{x: 10, y: 10}, vertex{x: 90, y: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nearlyEqual(path.Length, 160) || !nearlyEqual(path.Clearance, 25) || len(path.Points) != 5 ||
+		path.Points[2] != (vertex{x: 50, y: 50}) {
+		t.Errorf("got path %+v", path)
+	}
+
+	if _, err := diagram.Roadmap(30).ShortestPath(vertex{x: 10, y: 10}, vertex{x: 90, y: 90}); !errors.Is(err,
+		ErrNoPath) {
+		t.Errorf("planning with too high a clearance returned %v, want ErrNoPath", err)
+	}
+	if _, err := roadmap.ShortestPath(vertex{x: -10, y: 10}, vertex{x: 90, y: 90}); !errors.Is(err,
+		ErrInvalidInput) {
+		t.Errorf("planning from outside the box returned %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestRoadmap(t *testing.T) {
+	siteList := uniformSites(500, rand.New(rand.NewSource(1)))
+	diagram, err := Compute(siteList, benchmarkBox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The clearance of a point is its distance to the nearest site
+	clearance := func(a, b vertex) float64 {
+		nearest := math.Inf(1)
+		for _, s := range diagram.sites {
+			nearest = math.Min(nearest, distanceToSegment(vertex(s), a, b))
+		}
+		return nearest
+	}
+	source := rand.New(rand.NewSource(2))
+	for _, minimumClearance := range []float64{0, 10, 20} {
+		roadmap := diagram.Roadmap(minimumClearance)
+		nodes := roadmap.Nodes()
+		for _, edge := range roadmap.Edges() {
+			if edge.Clearance < minimumClearance || math.Abs(edge.Clearance-clearance(nodes[edge.From],
+				nodes[edge.To])) > 1e-6 {
+				t.Fatalf("edge %+v has clearance %v", edge, clearance(nodes[edge.From], nodes[edge.To]))
+			}
+		}
+
+		for k := 0; k < 50; k++ {
+			start := vertex{x: source.Float64() * 1000, y: source.Float64() * 1000}
+			goal := vertex{x: source.Float64() * 1000, y: source.Float64() * 1000}
+			path, err := roadmap.ShortestPath(start, goal)
+			want, wantErr := roadmap.search(start, goal, false)
+			if (err == nil) != (wantErr == nil) || !nearlyEqual(path.Length, want.Length) {
+				t.Fatalf("A* found %v long (%v), Dijkstra found %v long (%v)", path.Length, err, want.Length,
+					wantErr)
+			}
+			if err != nil {
+				if minimumClearance == 0 || !errors.Is(err, ErrNoPath) {
+					t.Fatal(err)
+				}
+				continue
+			}
+			if path.Points[0] != start || path.Points[len(path.Points)-1] != goal ||
+				path.Clearance < minimumClearance {
+				t.Fatalf("got path %+v", path)
+			}
+			for i := 2; i < len(path.Points)-1; i++ {
+				if got := clearance(path.Points[i-1], path.Points[i]); got < path.Clearance-1e-6 {
+					t.Fatalf("path comes %v from a site, reported clearance %v", got, path.Clearance)
+				}
+			}
+		}
+	}
+}
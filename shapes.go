@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/fogleman/gg"
+)
+
+// Shape - a site which is a point, a line segment or a simple polygon, given by its corners: one for a point,
+// two for a segment, and three or more in order around a polygon, either way round
+type Shape []vertex
+
+// ShapeDiagram - the voronoi diagram of shapes, dividing the bounding box by which shape is nearest. Where a
+// border runs between a point or a corner and a side it is a parabola, so borders are followed as polylines:
+// the diagram is that of points spaced along the outlines of the shapes, close enough that anywhere along the
+// borders the distances to the two shapes differ by no more than the tolerance. The cell of a polygon
+// includes its inside.
+type ShapeDiagram struct {
+	shapes    []Shape
+	tolerance float64
+	samples   *Diagram
+	owners    []SiteID // The shape of each sample, indexed by the samples' SiteIDs
+}
+
+// ShapeBorder - part of the border between the cells of two shapes, with A (the lower id) on the left going
+// along its points
+type ShapeBorder struct {
+	A, B   SiteID
+	Points []vertex
+}
+
+// ComputeShapes generates the voronoi diagram of the shapes within the bounding box, following the borders to
+// within tolerance. Parallelism and Limits in the options apply to the sweep of the points along the shapes,
+// whose number is checked against MaxSites (or 1<<24 if it is not set) before they are placed; the metric
+// must be plain euclidean and the box may not wrap around. Shapes must not touch each other or lie
+// inside a polygon, and polygons must not touch themselves. Returned errors wrap ErrInvalidInput or
+// ErrDegenerateInput if the input is rejected, and otherwise are those of Compute.
+func ComputeShapes(shapes []Shape, boundingBox boundingBox, tolerance float64, options Options) (*ShapeDiagram,
+	error) {
+	if !isFinite(tolerance) || tolerance <= 0 {
+		return nil, &InputError{Index: -1, Reason: "tolerance must be finite and positive", Err: ErrInvalidInput}
+	}
+	if !options.plainEuclidean() {
+		return nil, &InputError{Index: -1, Reason: "shapes need a plain euclidean diagram in a box which does not wrap",
+			Err: ErrInvalidInput}
+	}
+	if err := validateShapes(shapes); err != nil {
+		return nil, err
+	}
+
+	// Each shape's points are spaced so that a point on a border, which is at least half the distance to
+	// the nearest other shape from it, is no more than the tolerance further from the nearest point than
+	// from the outline between the points either side
+	spacings, count := make([]float64, len(shapes)), 0.0
+	for id, shape := range shapes {
+		clearance := math.Inf(1)
+		for other := range shapes {
+			if other != id {
+				clearance = math.Min(clearance, shapeDistance(shape, shapes[other]))
+			}
+		}
+		spacings[id] = math.Max(2*tolerance, 2*math.Sqrt(tolerance*clearance))
+		count += outlineSamples(shape, spacings[id])
+	}
+	if err := checkOutlineSamples(count, options.Limits); err != nil {
+		return nil, err
+	}
+	var samples []site
+	var owners []SiteID
+	for id, shape := range shapes {
+		points, _ := sampleOutline(shape, spacings[id])
+		for _, p := range points {
+			samples = append(samples, site(p))
+			owners = append(owners, SiteID(id))
+		}
+	}
+
+	diagram, err := Compute(samples, boundingBox, Options{DuplicatePolicy: KeepFirstDuplicate,
+		Parallelism: options.Parallelism, Limits: options.Limits})
+	if err != nil {
+		return nil, err
+	}
+	// Shapes do not touch, so only points of the same shape can be merged
+	merged := make([]SiteID, len(diagram.sites))
+	for k, id := range diagram.siteIDs {
+		merged[id] = owners[k]
+	}
+	return &ShapeDiagram{shapes: shapes, tolerance: tolerance, samples: diagram, owners: merged}, nil
+}
+
+// maxOutlineSamples - the most points spaced along outlines when the options do not limit the sites
+const maxOutlineSamples = 1 << 24
+
+// outlineSamples - how many points sampleOutline places along a shape, counted without placing them
+func outlineSamples(shape Shape, spacing float64) float64 {
+	if len(shape) == 1 {
+		return 1
+	}
+	count := 0.0
+	for _, side := range shapeSides(shape) {
+		count += math.Max(1, math.Ceil(math.Hypot(side[1].x-side[0].x, side[1].y-side[0].y)/spacing))
+	}
+	if len(shape) == 2 {
+		count++
+	}
+	return count
+}
+
+// checkOutlineSamples fails if there would be more points along the outlines than MaxSites allows, or than
+// maxOutlineSamples if it is not set, before any of them are placed
+func checkOutlineSamples(count float64, limits Limits) error {
+	limit := maxOutlineSamples
+	if limits.MaxSites > 0 {
+		limit = limits.MaxSites
+	}
+	if count > float64(limit) {
+		return &LimitError{Limit: "sites", Max: limit, Err: ErrLimitExceeded}
+	}
+	return nil
+}
+
+// sampleOutline returns points spaced no further apart than spacing along the outline of a shape, starting at
+// each of its corners, and how far round the outline from the first corner each one is
+func sampleOutline(shape Shape, spacing float64) (points []vertex, along []float64) {
+	if len(shape) == 1 {
+		return []vertex{shape[0]}, []float64{0}
+	}
+	distance := 0.0
+	for _, side := range shapeSides(shape) {
+		a, b := side[0], side[1]
+		length := math.Hypot(b.x-a.x, b.y-a.y)
+		steps := max(1, int(math.Ceil(length/spacing)))
+		for step := 0; step < steps; step++ {
+			t := float64(step) / float64(steps)
+			points = append(points, vertex{x: a.x + t*(b.x-a.x), y: a.y + t*(b.y-a.y)})
+			along = append(along, distance+t*length)
+		}
+		distance += length
+	}
+	// A segment ends at its far corner, where a polygon goes on round
+	if len(shape) == 2 {
+		points = append(points, shape[1])
+		along = append(along, distance)
+	}
+	return points, along
+}
+
+// validateShapes rejects shapes with non-finite or repeated corners, polygons which touch themselves, and
+// shapes which touch or lie inside another
+func validateShapes(shapes []Shape) error {
+	for id, shape := range shapes {
+		reject := func(reason string, err error) error {
+			return &InputError{Index: id, Site: site(shape[0]), Reason: reason, Err: err}
+		}
+		if len(shape) == 0 {
+			return &InputError{Index: id, Reason: "has no corners", Err: ErrInvalidInput}
+		}
+		for _, corner := range shape {
+			if !isFinite(corner.x) || !isFinite(corner.y) {
+				return reject("has a corner with a non-finite coordinate", ErrInvalidInput)
+			}
+		}
+		sides := shapeSides(shape)
+		for k, side := range sides {
+			if len(shape) > 1 && side[0] == side[1] {
+				return reject("has a side of zero length", ErrDegenerateInput)
+			}
+			// Sides next to each other share a corner, so only the others are checked
+			for j := k + 2; j < len(sides); j++ {
+				if (k > 0 || j < len(sides)-1) && segmentDistance(side, sides[j]) == 0 {
+					return reject(fmt.Sprintf("touches itself at sides %d and %d", k, j), ErrDegenerateInput)
+				}
+			}
+		}
+		for other := 0; other < id; other++ {
+			if shapeDistance(shape, shapes[other]) == 0 {
+				return reject(fmt.Sprintf("touches shape %d", other), ErrDegenerateInput)
+			}
+		}
+	}
+	for id, shape := range shapes {
+		for other, polygon := range shapes {
+			if other != id && len(polygon) > 2 && insidePolygon(shape[0], polygon) {
+				return &InputError{Index: id, Site: site(shape[0]), Reason: fmt.Sprintf("is inside shape %d", other),
+					Err: ErrDegenerateInput}
+			}
+		}
+	}
+	return nil
+}
+
+// shapeSides returns the sides of a shape, a point having one of zero length
+func shapeSides(shape Shape) [][2]vertex {
+	switch len(shape) {
+	case 1:
+		return [][2]vertex{{shape[0], shape[0]}}
+	case 2:
+		return [][2]vertex{{shape[0], shape[1]}}
+	}
+	sides := make([][2]vertex, len(shape))
+	for k := range shape {
+		sides[k] = [2]vertex{shape[k], shape[(k+1)%len(shape)]}
+	}
+	return sides
+}
+
+// shapeDistance - the distance between the outlines of two shapes
+func shapeDistance(a, b Shape) float64 {
+	distance := math.Inf(1)
+	for _, sideA := range shapeSides(a) {
+		for _, sideB := range shapeSides(b) {
+			distance = math.Min(distance, segmentDistance(sideA, sideB))
+		}
+	}
+	return distance
+}
+
+// segmentDistance - the distance between two segments, which is zero if they touch or cross
+func segmentDistance(a, b [2]vertex) float64 {
+	// Each segment's ends on opposite sides of (or on) the line through the other
+	ab0, ab1 := cross(a[0], a[1], b[0]), cross(a[0], a[1], b[1])
+	ba0, ba1 := cross(b[0], b[1], a[0]), cross(b[0], b[1], a[1])
+	if ab0*ab1 < 0 && ba0*ba1 < 0 {
+		return 0
+	}
+	return math.Min(math.Min(distanceToSegment(a[0], b[0], b[1]), distanceToSegment(a[1], b[0], b[1])),
+		math.Min(distanceToSegment(b[0], a[0], a[1]), distanceToSegment(b[1], a[0], a[1])))
+}
+
+// insidePolygon - whether p is strictly inside the polygon, by counting the sides a ray to the right crosses
+func insidePolygon(p vertex, polygon Shape) bool {
+	inside := false
+	for k, a := range polygon {
+		b := polygon[(k+1)%len(polygon)]
+		if (a.y > p.y) != (b.y > p.y) && p.x < a.x+(p.y-a.y)*(b.x-a.x)/(b.y-a.y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Shapes returns the shapes of the diagram, indexed by SiteID
+func (diagram *ShapeDiagram) Shapes() []Shape {
+	return append([]Shape(nil), diagram.shapes...)
+}
+
+// Locate returns the id of the shape whose cell contains p, or -1 if p lies outside the bounding box
+func (diagram *ShapeDiagram) Locate(p vertex) SiteID {
+	if id := diagram.samples.Locate(p); id >= 0 {
+		return diagram.owners[id]
+	}
+	return -1
+}
+
+// Borders returns the borders between the cells of the shapes inside the bounding box, in order of the ids
+// of their shapes. Two shapes may share several borders.
+func (diagram *ShapeDiagram) Borders() []ShapeBorder {
+	pieces := map[[2]SiteID][][2]vertex{}
+	for _, segment := range clippedSegments(diagram.samples) {
+		a, b := diagram.owners[segment.sites[0]], diagram.owners[segment.sites[1]]
+		if a == b {
+			continue
+		}
+		sampleA := segment.sites[0]
+		if a > b {
+			a, b, sampleA = b, a, segment.sites[1]
+		}
+		p, q := segment.p, segment.q
+		if cross(p, q, vertex(diagram.samples.sites[sampleA])) < 0 {
+			p, q = q, p
+		}
+		pieces[[2]SiteID{a, b}] = append(pieces[[2]SiteID{a, b}], [2]vertex{p, q})
+	}
+
+	var borders []ShapeBorder
+	for pair, segments := range pieces {
+		for _, line := range chainSegments(segments) {
+			borders = append(borders, ShapeBorder{A: pair[0], B: pair[1], Points: line})
+		}
+	}
+	sort.Slice(borders, func(i, j int) bool {
+		if borders[i].A != borders[j].A || borders[i].B != borders[j].B {
+			return borders[i].A < borders[j].A || (borders[i].A == borders[j].A && borders[i].B < borders[j].B)
+		}
+		return pointLeftOf(borders[i].Points[0], borders[j].Points[0])
+	})
+	return borders
+}
+
+// chainSegments joins segments end to start into polylines, those with a free start first and then closed
+// loops, which end where they start
+func chainSegments(segments [][2]vertex) [][]vertex {
+	starting := map[vertex][]int{}
+	ending := map[vertex]bool{}
+	for k, segment := range segments {
+		starting[segment[0]] = append(starting[segment[0]], k)
+		ending[segment[1]] = true
+	}
+	used := make([]bool, len(segments))
+	follow := func(k int) []vertex {
+		line := []vertex{segments[k][0]}
+		for k >= 0 {
+			used[k] = true
+			line = append(line, segments[k][1])
+			next := -1
+			for _, j := range starting[segments[k][1]] {
+				if !used[j] {
+					next = j
+					break
+				}
+			}
+			k = next
+		}
+		return line
+	}
+	var lines [][]vertex
+	for k, segment := range segments {
+		if !used[k] && !ending[segment[0]] {
+			lines = append(lines, follow(k))
+		}
+	}
+	for k := range segments {
+		if !used[k] {
+			lines = append(lines, follow(k))
+		}
+	}
+	return lines
+}
+
+// Cells returns the outline of the cell of every shape inside the bounding box, indexed by SiteID, as closed
+// rings ending where they start: anticlockwise around the cell, and clockwise around any holes in it where
+// the cell of another shape is surrounded
+func (diagram *ShapeDiagram) Cells() [][][]vertex {
+	box := diagram.samples.boundingBox
+	// The cells of neighbouring points are clipped separately, so their shared corners are a rounding error
+	// apart
+	snap := newPointSnapper(1e-9 * math.Max(box.width, box.height))
+	sides := make([][][2]vertex, len(diagram.shapes))
+	for sample, polygon := range diagram.samples.cellPolygons() {
+		owner := diagram.owners[sample]
+		for k, corner := range polygon {
+			if corner.across >= 0 && diagram.owners[corner.across] == owner {
+				continue
+			}
+			p, q := snap.point(corner.vertex), snap.point(polygon[(k+1)%len(polygon)].vertex)
+			if p != q {
+				sides[owner] = append(sides[owner], [2]vertex{p, q})
+			}
+		}
+	}
+	cells := make([][][]vertex, len(diagram.shapes))
+	for id := range cells {
+		cells[id] = chainSegments(sides[id])
+	}
+	return cells
+}
+
+// renderShapeVoronoi draws the borders and shapes into an image the size of the bounding box, flipping the y
+// axis as renderVoronoi does
+func renderShapeVoronoi(diagram *ShapeDiagram) *gg.Context {
+	box := diagram.samples.boundingBox
+	voronoi := gg.NewContext(int(box.width), int(box.height))
+	voronoi.SetRGB(1, 1, 1)
+	voronoi.Clear()
+	voronoi.SetLineWidth(3)
+
+	voronoi.SetRGB(0.3, 0.7, 0.8)
+	for _, border := range diagram.Borders() {
+		for _, p := range border.Points {
+			voronoi.LineTo(p.x, box.height-p.y)
+		}
+		voronoi.Stroke()
+	}
+
+	voronoi.SetRGB(0.9, 0.5, 0.6)
+	for _, shape := range diagram.shapes {
+		if len(shape) == 1 {
+			voronoi.DrawPoint(shape[0].x, box.height-shape[0].y, 2.0)
+			voronoi.Stroke()
+			continue
+		}
+		for _, side := range shapeSides(shape) {
+			voronoi.DrawLine(side[0].x, box.height-side[0].y, side[1].x, box.height-side[1].y)
+			voronoi.Stroke()
+		}
+	}
+	return voronoi
+}
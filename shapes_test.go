@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestShapeParabola(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	shapes := []Shape{{{50, 80}}, {{20, 20}, {80, 20}}}
+	diagram, err := ComputeShapes(shapes, box, 0.01, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	borders := diagram.Borders()
+	if len(borders) != 1 || borders[0].A != 0 || borders[0].B != 1 {
+		t.Fatalf("got borders %+v, want one between the point and the segment", borders)
+	}
+	// Above the segment the border is the parabola with the point as focus and the segment's line as
+	// directrix, whose vertex is half way between them
+	nearVertex := math.Inf(1)
+	for k, p := range borders[0].Points {
+		if math.Abs(shapeDistanceTo(shapes[0], p)-shapeDistanceTo(shapes[1], p)) > 0.01+1e-9 {
+			t.Fatalf("border point %v is %v from the point and %v from the segment", p,
+				shapeDistanceTo(shapes[0], p), shapeDistanceTo(shapes[1], p))
+		}
+		if k > 0 && cross(borders[0].Points[k-1], p, vertex{50, 80}) < 0 {
+			t.Fatalf("the point is on the right of the border at %v", p)
+		}
+		nearVertex = math.Min(nearVertex, math.Hypot(p.x-50, p.y-50))
+	}
+	if nearVertex > 0.5 {
+		t.Errorf("the border passes %v from (50, 50), want it through the vertex of the parabola", nearVertex)
+	}
+	if got := diagram.Locate(vertex{50, 40}); got != 1 {
+		t.Errorf("(50, 40) is in cell %d, want the segment's", got)
+	}
+	if got := diagram.Locate(vertex{50, 60}); got != 0 {
+		t.Errorf("(50, 60) is in cell %d, want the point's", got)
+	}
+}
+
+func TestShapeDiagram(t *testing.T) {
+	source := rand.New(rand.NewSource(12))
+	shapes := randomShapes(30, source)
+	const tolerance = 0.05
+	diagram, err := ComputeShapes(shapes, benchmarkBox, tolerance, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	borders := diagram.Borders()
+	if len(borders) < len(shapes)-1 {
+		t.Fatalf("got %d borders between %d shapes", len(borders), len(shapes))
+	}
+	for _, border := range borders {
+		if len(border.Points) < 2 {
+			t.Fatalf("border %+v has fewer than two points", border)
+		}
+		for _, p := range border.Points {
+			a, b := shapeDistanceTo(shapes[border.A], p), shapeDistanceTo(shapes[border.B], p)
+			if math.Abs(a-b) > tolerance+1e-9 {
+				t.Fatalf("border %d - %d: %v is %v from one and %v from the other", border.A, border.B, p, a, b)
+			}
+		}
+	}
+
+	// Every point is in the cell of the nearest shape, unless it is within tolerance as near another
+	for k := 0; k < 2000; k++ {
+		p := vertex{source.Float64() * benchmarkBox.width, source.Float64() * benchmarkBox.height}
+		got, want := diagram.Locate(p), SiteID(-1)
+		for id, shape := range shapes {
+			if want < 0 || shapeDistanceTo(shape, p) < shapeDistanceTo(shapes[want], p) {
+				want = SiteID(id)
+			}
+		}
+		if got != want && shapeDistanceTo(shapes[got], p)-shapeDistanceTo(shapes[want], p) > tolerance {
+			t.Fatalf("%v is in cell %d, nearest is %d", p, got, want)
+		}
+	}
+
+	// The inside of a polygon is in its cell
+	for id, shape := range shapes {
+		if len(shape) > 2 {
+			centre := vertex{}
+			for _, corner := range shape {
+				centre = vertex{centre.x + corner.x/float64(len(shape)), centre.y + corner.y/float64(len(shape))}
+			}
+			if got := diagram.Locate(centre); got != SiteID(id) {
+				t.Errorf("the centre of polygon %d is in cell %d", id, got)
+			}
+		}
+	}
+
+	// The cells, less their holes, cover the box once between them
+	totalArea := 0.0
+	for id, cell := range diagram.Cells() {
+		if len(cell) == 0 {
+			t.Errorf("shape %d has no cell", id)
+		}
+		for _, ring := range cell {
+			if ring[0] != ring[len(ring)-1] {
+				t.Fatalf("shape %d has a ring which is not closed", id)
+			}
+			twiceArea := 0.0
+			for k := 1; k < len(ring); k++ {
+				twiceArea += ring[k-1].x*ring[k].y - ring[k].x*ring[k-1].y
+			}
+			totalArea += twiceArea / 2
+		}
+	}
+	if math.Abs(totalArea-benchmarkBox.width*benchmarkBox.height) > 1e-6 {
+		t.Errorf("cells cover %v, want %v", totalArea, benchmarkBox.width*benchmarkBox.height)
+	}
+
+	voronoi := renderShapeVoronoi(diagram)
+	if bounds := voronoi.Image().Bounds(); bounds.Dx() != 1000 || bounds.Dy() != 1000 {
+		t.Errorf("got an image %v, want the size of the box", bounds)
+	}
+}
+
+func TestShapeInput(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	for _, test := range []struct {
+		name      string
+		shapes    []Shape
+		tolerance float64
+		want      error
+	}{
+		{"no tolerance", []Shape{{{10, 10}}}, 0, ErrInvalidInput},
+		{"no corners", []Shape{{}}, 1, ErrInvalidInput},
+		{"not finite", []Shape{{{math.NaN(), 10}}}, 1, ErrInvalidInput},
+		{"zero length side", []Shape{{{10, 10}, {10, 10}}}, 1, ErrDegenerateInput},
+		{"crossing segments", []Shape{{{10, 10}, {90, 90}}, {{10, 90}, {90, 10}}}, 1, ErrDegenerateInput},
+		{"touching shapes", []Shape{{{10, 10}, {90, 10}}, {{50, 10}}}, 1, ErrDegenerateInput},
+		{"self-intersecting polygon", []Shape{{{10, 10}, {90, 90}, {90, 10}, {10, 90}}}, 1, ErrDegenerateInput},
+		{"point inside polygon", []Shape{{{10, 10}, {90, 10}, {50, 90}}, {{50, 40}}}, 1, ErrDegenerateInput},
+	} {
+		_, err := ComputeShapes(test.shapes, box, test.tolerance, Options{})
+		var inputErr *InputError
+		if !errors.Is(err, test.want) || !errors.As(err, &inputErr) {
+			t.Errorf("%s: got %v, want an InputError wrapping %v", test.name, err, test.want)
+		}
+	}
+	_, err := ComputeShapes([]Shape{{{10, 10}}}, box, 1, Options{Metric: Manhattan})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("manhattan metric: got %v, want ErrInvalidInput", err)
+	}
+
+	// Long segments close together need more points along them than are allowed
+	near := []Shape{{{0, 0}, {1000, 0}}, {{0, 1e-6}, {1000, 1e-6}}}
+	if _, err := ComputeShapes(near, boundingBox{width: 1000, height: 1000}, 1e-6, Options{}); !errors.Is(err,
+		ErrLimitExceeded) {
+		t.Errorf("segments 1e-6 apart: got %v, want ErrLimitExceeded", err)
+	}
+	segments := []Shape{{{10, 10}, {90, 10}}, {{10, 90}, {90, 90}}}
+	if _, err := ComputeShapes(segments, box, 0.001, Options{Limits: Limits{MaxSites: 100}}); !errors.Is(err,
+		ErrLimitExceeded) {
+		t.Errorf("more points than MaxSites: got %v, want ErrLimitExceeded", err)
+	}
+}
+
+// randomShapes returns points, segments and polygons scattered over the benchmark box, apart from each other
+func randomShapes(n int, source *rand.Rand) []Shape {
+	var shapes []Shape
+	for len(shapes) < n {
+		centre := vertex{50 + source.Float64()*900, 50 + source.Float64()*900}
+		var shape Shape
+		switch corners := source.Intn(5) + 1; corners {
+		case 1:
+			shape = Shape{centre}
+		default:
+			radius, turn := 10+source.Float64()*40, source.Float64()*2*math.Pi
+			for k := 0; k < corners; k++ {
+				angle := turn + 2*math.Pi*float64(k)/float64(corners)
+				shape = append(shape, vertex{centre.x + radius*math.Cos(angle), centre.y + radius*math.Sin(angle)})
+			}
+		}
+		if validateShapes(append(shapes, shape)) == nil {
+			shapes = append(shapes, shape)
+		}
+	}
+	return shapes
+}
+
+// shapeDistanceTo - the distance from p to the nearest point of a shape, which is zero inside a polygon
+func shapeDistanceTo(shape Shape, p vertex) float64 {
+	if len(shape) > 2 && insidePolygon(p, shape) {
+		return 0
+	}
+	distance := math.Inf(1)
+	for _, side := range shapeSides(shape) {
+		distance = math.Min(distance, distanceToSegment(p, side[0], side[1]))
+	}
+	return distance
+}
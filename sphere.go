@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// LatLon - a point on the globe in degrees, latitude north and longitude east
+type LatLon struct {
+	Lat, Lon float64
+}
+
+// SphericalDiagram - a voronoi diagram of points on the globe, measuring distance along great circles. Its
+// cells are bounded by great circle arcs.
+type SphericalDiagram struct {
+	sites   []LatLon // After merging duplicates, indexed by SiteID
+	siteIDs []SiteID // The SiteID of each input site
+	points  []vector3
+	cells   [][]sphericalCorner
+}
+
+// sphericalCorner - a corner of a cell on the sphere, and the site of the cell across the side leaving it
+// anticlockwise
+type sphericalCorner struct {
+	point  vector3
+	across SiteID
+}
+
+// vector3 - a point in space, with the centre of the globe at the origin and the north pole at z = 1
+type vector3 struct {
+	x, y, z float64
+}
+
+func (a vector3) add(b vector3) vector3 { return vector3{a.x + b.x, a.y + b.y, a.z + b.z} }
+
+func (a vector3) sub(b vector3) vector3 { return vector3{a.x - b.x, a.y - b.y, a.z - b.z} }
+
+func (a vector3) scale(k float64) vector3 { return vector3{k * a.x, k * a.y, k * a.z} }
+
+func (a vector3) dot(b vector3) float64 { return a.x*b.x + a.y*b.y + a.z*b.z }
+
+func (a vector3) cross(b vector3) vector3 {
+	return vector3{a.y*b.z - a.z*b.y, a.z*b.x - a.x*b.z, a.x*b.y - a.y*b.x}
+}
+
+func (a vector3) length() float64 { return math.Sqrt(a.dot(a)) }
+
+func (a vector3) unit() vector3 { return a.scale(1 / a.length()) }
+
+// angle - the angle between two points as seen from the centre of the globe, in radians
+func (a vector3) angle(b vector3) float64 { return math.Atan2(a.cross(b).length(), a.dot(b)) }
+
+func (p LatLon) vector() vector3 {
+	lat, lon := p.Lat*math.Pi/180, p.Lon*math.Pi/180
+	return vector3{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+}
+
+// latLon - the point on the globe in the direction of a, with longitude between -180 and 180
+func (a vector3) latLon() LatLon {
+	return LatLon{Lat: math.Atan2(a.z, math.Hypot(a.x, a.y)) * 180 / math.Pi, Lon: math.Atan2(a.y, a.x) * 180 / math.Pi}
+}
+
+// ComputeSpherical generates the voronoi diagram of points on the globe. Duplicates are merged as in
+// Compute, with DuplicateTolerance as the great circle distance in degrees, and Limits.MaxSites and the
+// context are checked. The metric must be Euclidean, which on the globe measures along great circles. Returned
+// errors wrap ErrInvalidInput or ErrDegenerateInput if the input is rejected - including when fewer than four
+// distinct sites are given or they all lie on one circle - or ErrNumericalFailure if the hull breaks down.
+func ComputeSpherical(siteList []LatLon, options Options) (*SphericalDiagram, error) {
+	return ComputeSphericalContext(context.Background(), siteList, options)
+}
+
+// ComputeSphericalContext is ComputeSpherical with a context, which is checked periodically
+func ComputeSphericalContext(ctx context.Context, siteList []LatLon, options Options) (*SphericalDiagram,
+	error) {
+	limits := newSweepLimits(ctx, options.Limits)
+	if err := limits.checkContext(); err != nil {
+		return nil, err
+	}
+	if limits.limits.MaxSites > 0 && len(siteList) > limits.limits.MaxSites {
+		return nil, &LimitError{Limit: "sites", Max: limits.limits.MaxSites, Err: ErrLimitExceeded}
+	}
+	if options.Metric != Euclidean {
+		return nil, &InputError{Index: -1, Reason: fmt.Sprintf("the %v metric is not defined on the globe",
+			options.Metric), Err: ErrInvalidInput}
+	}
+	if options.Anisotropy != ([2][2]float64{}) || options.Weighting != Unweighted {
+		return nil, &InputError{Index: -1, Reason: "weights and anisotropy are not defined on the globe",
+			Err: ErrInvalidInput}
+	}
+	for i, p := range siteList {
+		if !isFinite(p.Lat) || !isFinite(p.Lon) || math.Abs(p.Lat) > 90 {
+			return nil, &InputError{Index: i, Site: site{x: p.Lon, y: p.Lat},
+				Reason: "needs a finite longitude and a latitude between -90 and 90", Err: ErrInvalidInput}
+		}
+	}
+
+	diagram := &SphericalDiagram{}
+	var err error
+	if diagram.sites, diagram.points, diagram.siteIDs, err = mergeSphericalDuplicates(siteList,
+		options.DuplicateTolerance, options.DuplicatePolicy); err != nil {
+		return nil, err
+	}
+	faces, err := sphericalHull(limits, diagram.points)
+	if err != nil {
+		return nil, err
+	}
+	diagram.cells = sphericalCells(faces, len(diagram.points))
+	return diagram, nil
+}
+
+// Sites returns the sites of the diagram after duplicates were merged, indexed by SiteID
+func (diagram *SphericalDiagram) Sites() []LatLon {
+	return append([]LatLon(nil), diagram.sites...)
+}
+
+// SiteIDs returns the id of the merged site (and so the cell) that each input site belongs to
+func (diagram *SphericalDiagram) SiteIDs() []SiteID {
+	return append([]SiteID(nil), diagram.siteIDs...)
+}
+
+// Cells returns the corners of every cell, indexed by SiteID, anticlockwise as seen from above the globe.
+// The sides between the corners are great circle arcs.
+func (diagram *SphericalDiagram) Cells() [][]LatLon {
+	cells := make([][]LatLon, len(diagram.cells))
+	for id, cell := range diagram.cells {
+		for _, corner := range cell {
+			cells[id] = append(cells[id], corner.point.latLon())
+		}
+	}
+	return cells
+}
+
+// mergeSphericalDuplicates groups together sites within tolerance degrees of each other, as
+// mergeDuplicateSites does in the plane, returning the merged sites with their points on the unit sphere
+func mergeSphericalDuplicates(siteList []LatLon, tolerance float64, policy DuplicatePolicy) ([]LatLon,
+	[]vector3, []SiteID, error) {
+	if !isFinite(tolerance) || tolerance < 0 {
+		return nil, nil, nil, &InputError{Index: -1, Reason: "duplicate tolerance must be finite and not negative",
+			Err: ErrInvalidInput}
+	}
+	// Buckets the size of the straight line distance between points the tolerance apart, so only the
+	// neighbouring buckets need to be searched
+	chord := 2 * math.Sin(math.Min(tolerance, 180)*math.Pi/360)
+	bucketOf := func(p vector3) [3]float64 {
+		if chord == 0 {
+			return [3]float64{p.x, p.y, p.z}
+		}
+		return [3]float64{math.Floor(p.x / chord), math.Floor(p.y / chord), math.Floor(p.z / chord)}
+	}
+	buckets := map[[3]float64][]SiteID{}
+	var firstIndex []int
+	var firsts, sums []vector3
+	siteIDs := make([]SiteID, len(siteList))
+	for i, p := range siteList {
+		point := p.vector()
+		if math.Abs(p.Lat) == 90 {
+			// Every longitude is the same point
+			point = vector3{z: math.Copysign(1, p.Lat)}
+		}
+		bucket := bucketOf(point)
+		id, found := SiteID(0), false
+		for d := 0; d < 27 && !found; d++ {
+			offset := [3]float64{float64(d%3 - 1), float64(d/3%3 - 1), float64(d/9 - 1)}
+			if chord == 0 && offset != [3]float64{} {
+				continue
+			}
+			for _, group := range buckets[[3]float64{bucket[0] + offset[0], bucket[1] + offset[1],
+				bucket[2] + offset[2]}] {
+				if firsts[group].angle(point) <= tolerance*math.Pi/180 {
+					id, found = group, true
+					break
+				}
+			}
+		}
+		if !found {
+			id = SiteID(len(firsts))
+			firstIndex = append(firstIndex, i)
+			firsts = append(firsts, point)
+			sums = append(sums, point)
+			buckets[bucket] = append(buckets[bucket], id)
+		} else if policy == RejectDuplicates {
+			reason := fmt.Sprintf("duplicates site %d", firstIndex[id])
+			if tolerance > 0 {
+				reason = fmt.Sprintf("is within %v degrees of site %d", tolerance, firstIndex[id])
+			}
+			return nil, nil, nil, &InputError{Index: i, Site: site{x: p.Lon, y: p.Lat}, Reason: reason,
+				Err: ErrDegenerateInput}
+		} else {
+			sums[id] = sums[id].add(point)
+		}
+		siteIDs[i] = id
+	}
+
+	merged := make([]LatLon, len(firsts))
+	for id, index := range firstIndex {
+		merged[id] = siteList[index]
+		if policy == AverageDuplicates && sums[id] != firsts[id] && sums[id].length() > 1e-9 {
+			firsts[id] = sums[id].unit()
+			merged[id] = firsts[id].latLon()
+		}
+	}
+	return merged, firsts, siteIDs, nil
+}
+
+// hullFace - a triangle of the convex hull of the sites
+type hullFace struct {
+	corners    [3]int       // Anticlockwise seen from outside the hull
+	neighbours [3]*hullFace // Across the side from corners[k] to corners[k+1]
+	normal     vector3      // Unit length, pointing out of the hull
+	conflicts  []int        // Points not yet added to the hull which are in front of the face
+	removed    bool
+}
+
+// hullTolerance - how far in front of a face a point must be to see it. Points on the globe closer than about
+// a millionth of a radian to the hull are already on it.
+const hullTolerance = 1e-12
+
+func (face *hullFace) sees(points []vector3, p int) bool {
+	return face.normal.dot(points[p].sub(points[face.corners[0]])) > hullTolerance
+}
+
+func newHullFace(points []vector3, a, b, c int) *hullFace {
+	return &hullFace{corners: [3]int{a, b, c},
+		normal: points[b].sub(points[a]).cross(points[c].sub(points[a])).unit()}
+}
+
+// sphericalHull returns the faces of the convex hull of points on the unit sphere, every one of which is a
+// corner of it. The points are added in a random order, each replacing the faces it can see with a fan of
+// faces to the horizon, and every point not yet added keeps the faces it can see (Clarkson-Shor).
+func sphericalHull(limits *sweepLimits, points []vector3) ([]*hullFace, error) {
+	flat := &InputError{Index: -1, Reason: "the sites need at least four points not on one circle",
+		Err: ErrDegenerateInput}
+	if len(points) < 4 {
+		return nil, flat
+	}
+	// Start from the largest tetrahedron found greedily
+	a, b, c, d := 0, 0, 0, 0
+	for k := range points {
+		if points[k].sub(points[a]).length() > points[b].sub(points[a]).length() {
+			b = k
+		}
+	}
+	for k := range points {
+		if points[k].sub(points[a]).cross(points[b].sub(points[a])).length() >
+			points[c].sub(points[a]).cross(points[b].sub(points[a])).length() {
+			c = k
+		}
+	}
+	normal := points[b].sub(points[a]).cross(points[c].sub(points[a]))
+	for k := range points {
+		if math.Abs(normal.dot(points[k].sub(points[a]))) > math.Abs(normal.dot(points[d].sub(points[a]))) {
+			d = k
+		}
+	}
+	if normal.length() <= hullTolerance || math.Abs(normal.unit().dot(points[d].sub(points[a]))) <= hullTolerance {
+		return nil, flat
+	}
+	if normal.dot(points[d].sub(points[a])) > 0 {
+		b, c = c, b
+	}
+	faces := []*hullFace{newHullFace(points, a, b, c), newHullFace(points, a, d, b), newHullFace(points, b, d, c),
+		newHullFace(points, c, d, a)}
+	sides := map[[2]int]*hullFace{}
+	for _, face := range faces {
+		for k := range face.corners {
+			sides[[2]int{face.corners[k], face.corners[(k+1)%3]}] = face
+		}
+	}
+	for _, face := range faces {
+		for k := range face.corners {
+			face.neighbours[k] = sides[[2]int{face.corners[(k+1)%3], face.corners[k]}]
+		}
+	}
+
+	pointConflicts := make([][]*hullFace, len(points))
+	for p := range points {
+		if p == a || p == b || p == c || p == d {
+			continue
+		}
+		for _, face := range faces {
+			if face.sees(points, p) {
+				face.conflicts = append(face.conflicts, p)
+				pointConflicts[p] = append(pointConflicts[p], face)
+			}
+		}
+	}
+
+	seen := make([]int, len(points)) // The index of the last face each point was tested against
+	for k := range seen {
+		seen[k] = -1
+	}
+	order := rand.New(rand.NewSource(1)).Perm(len(points))
+	for step, p := range order {
+		if step%contextCheckInterval == 0 {
+			if err := limits.checkContext(); err != nil {
+				return nil, err
+			}
+		}
+		if p == a || p == b || p == c || p == d {
+			continue
+		}
+		var visible []*hullFace
+		for _, face := range pointConflicts[p] {
+			if !face.removed {
+				face.removed = true
+				visible = append(visible, face)
+			}
+		}
+		pointConflicts[p] = nil
+		if len(visible) == 0 {
+			return nil, &InputError{Index: -1, Reason: fmt.Sprintf("a site at %v is too close to the others to "+
+				"separate", points[p].latLon()), Err: ErrDegenerateInput}
+		}
+
+		// A fan of faces from the point to each side of the horizon between the faces it sees and the rest
+		fan := map[int]*hullFace{} // By the corner the horizon side starts at
+		for _, face := range visible {
+			for k, behind := range face.neighbours {
+				if behind.removed {
+					continue
+				}
+				start, end := face.corners[k], face.corners[(k+1)%3]
+				if fan[start] != nil {
+					return nil, fmt.Errorf("%w: the horizon of a site at %v is not a loop", ErrNumericalFailure,
+						points[p].latLon())
+				}
+				added := newHullFace(points, start, end, p)
+				added.neighbours[0] = behind
+				for j := range behind.neighbours {
+					if behind.neighbours[j] == face {
+						behind.neighbours[j] = added
+					}
+				}
+				// Only the points which saw one of the faces either side of the horizon can see the new one
+				for _, candidates := range [][]int{face.conflicts, behind.conflicts} {
+					for _, q := range candidates {
+						if q != p && seen[q] != len(faces) && added.sees(points, q) {
+							seen[q] = len(faces)
+							added.conflicts = append(added.conflicts, q)
+							pointConflicts[q] = append(pointConflicts[q], added)
+						}
+					}
+				}
+				fan[start] = added
+				faces = append(faces, added)
+			}
+		}
+		for _, face := range fan {
+			next := fan[face.corners[1]]
+			if next == nil {
+				return nil, fmt.Errorf("%w: the horizon of a site at %v is not a loop", ErrNumericalFailure,
+					points[p].latLon())
+			}
+			face.neighbours[1], next.neighbours[2] = next, face
+		}
+		for _, face := range visible {
+			face.conflicts = nil
+		}
+	}
+
+	kept := faces[:0]
+	for _, face := range faces {
+		if !face.removed {
+			kept = append(kept, face)
+		}
+	}
+	return kept, nil
+}
+
+// sphericalCells returns the cell of each site of the hull. The corners of a cell are the centres of the
+// empty circles through the faces around its site - the outward normals of the faces - in the same order.
+func sphericalCells(faces []*hullFace, sites int) [][]sphericalCorner {
+	around := make([]*hullFace, sites)
+	for _, face := range faces {
+		for _, corner := range face.corners {
+			around[corner] = face
+		}
+	}
+	cells := make([][]sphericalCorner, sites)
+	for id, start := range around {
+		var cell []sphericalCorner
+		face := start
+		for {
+			k := 0
+			for face.corners[k] != id {
+				k++
+			}
+			// The next face anticlockwise around the site shares the side from the corner before it
+			across := face.corners[(k+2)%3]
+			cell = append(cell, sphericalCorner{point: face.normal, across: SiteID(across)})
+			if face = face.neighbours[(k+2)%3]; face == start {
+				break
+			}
+		}
+		// Where four or more sites lie on a circle the faces between them meet at one corner
+		kept := cell[:0]
+		for k, corner := range cell {
+			if corner.point.sub(cell[(k+1)%len(cell)].point).length() > 1e-9 {
+				kept = append(kept, corner)
+			}
+		}
+		cells[id] = kept
+	}
+	return cells
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSphericalOctahedron(t *testing.T) {
+	siteList := []LatLon{{0, 0}, {0, 90}, {0, 180}, {0, -90}, {90, 0}, {-90, 0}}
+	diagram, err := ComputeSpherical(siteList, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each cell is a square with its corners at the centres of the faces of the octahedron
+	for id, cell := range diagram.Cells() {
+		if len(cell) != 4 {
+			t.Fatalf("cell %d has corners %v, want 4", id, cell)
+		}
+		for _, corner := range cell {
+			if angle := corner.vector().angle(siteList[id].vector()); !nearlyEqual(angle, math.Acos(1/math.Sqrt(3))) {
+				t.Errorf("cell %d has a corner %v at %v radians", id, corner, angle)
+			}
+		}
+	}
+	if area := sphericalPolygonArea(diagram.cells[4]); !nearlyEqual(area, 4*math.Pi/6) {
+		t.Errorf("north polar cell has area %v, want %v", area, 4*math.Pi/6)
+	}
+}
+
+func TestSphericalDiagram(t *testing.T) {
+	source := rand.New(rand.NewSource(3))
+	var siteList []LatLon
+	for k := 0; k < 500; k++ {
+		// Uniform over the globe, with some bunched around the antimeridian and the poles
+		siteList = append(siteList, LatLon{Lat: math.Asin(2*source.Float64()-1) * 180 / math.Pi,
+			Lon: 360*source.Float64() - 180})
+	}
+	for k := 0; k < 50; k++ {
+		siteList = append(siteList, LatLon{Lat: 20 * source.NormFloat64(), Lon: 180 + 2*source.NormFloat64()},
+			LatLon{Lat: 89 + source.Float64(), Lon: 360 * source.Float64()})
+	}
+	diagram, err := ComputeSpherical(siteList, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := diagram.points
+
+	totalArea := 0.0
+	for id, cell := range diagram.cells {
+		totalArea += sphericalPolygonArea(cell)
+		// Every corner is as far from the site as from the sites across the sides either side of it
+		for k, corner := range cell {
+			before := cell[(k+len(cell)-1)%len(cell)].across
+			distance := corner.point.angle(points[id])
+			for _, other := range []SiteID{before, corner.across} {
+				if math.Abs(corner.point.angle(points[other])-distance) > 1e-9 {
+					t.Fatalf("cell %d has a corner %v away from its site and %v from site %d", id, distance,
+						corner.point.angle(points[other]), other)
+				}
+			}
+		}
+	}
+	if !nearlyEqual(totalArea, 4*math.Pi) {
+		t.Errorf("cells cover %v, want %v", totalArea, 4*math.Pi)
+	}
+
+	// Every point is in the cell of its nearest site
+	for k := 0; k < 2000; k++ {
+		p := LatLon{Lat: math.Asin(2*source.Float64()-1) * 180 / math.Pi, Lon: 360*source.Float64() - 180}.vector()
+		nearest := 0
+		for id := range points {
+			if p.dot(points[id]) > p.dot(points[nearest]) {
+				nearest = id
+			}
+		}
+		cell := diagram.cells[nearest]
+		for j, corner := range cell {
+			if corner.point.cross(cell[(j+1)%len(cell)].point).dot(p) < -1e-12 {
+				t.Fatalf("%v is outside the cell of its nearest site %d", p.latLon(), nearest)
+			}
+		}
+	}
+}
+
+func TestSphericalInput(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		siteList []LatLon
+		want     error
+	}{
+		{"three sites", []LatLon{{0, 0}, {10, 10}, {20, 0}}, ErrDegenerateInput},
+		{"sites on a circle", []LatLon{{30, 0}, {30, 90}, {30, 180}, {30, -90}, {30, 45}}, ErrDegenerateInput},
+		{"latitude past the pole", []LatLon{{0, 0}, {10, 10}, {20, 0}, {91, 0}}, ErrInvalidInput},
+		{"infinite longitude", []LatLon{{0, 0}, {10, 10}, {20, 0}, {0, math.Inf(1)}}, ErrInvalidInput},
+		{"duplicate at the pole", []LatLon{{90, 0}, {90, 50}, {0, 0}, {0, 90}, {-60, 0}}, ErrDegenerateInput},
+	} {
+		if _, err := ComputeSpherical(test.siteList, Options{}); !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	// Sites a little less than a degree apart across the antimeridian are merged
+	diagram, err := ComputeSpherical([]LatLon{{0, 179.6}, {0, -179.8}, {0, 0}, {0, 90}, {60, 0}, {-60, 0}},
+		Options{DuplicateTolerance: 1, DuplicatePolicy: KeepFirstDuplicate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := diagram.SiteIDs(); ids[0] != ids[1] || len(diagram.Sites()) != 5 {
+		t.Errorf("got site ids %v", ids)
+	}
+	if _, err := ComputeSpherical(uniformSpherical(10), Options{Metric: Manhattan}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("computing under the Manhattan metric returned %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestWriteGeoJSON(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		siteList []LatLon
+		split    int // A site whose cell crosses the antimeridian
+	}{
+		// With sites on the poles, on the antimeridian, and either side of it with their border along it
+		{"poles and antimeridian", append(uniformSpherical(200), LatLon{90, 0}, LatLon{-90, 0}, LatLon{10, 180},
+			LatLon{-10, 179}, LatLon{-10, -179}), 202},
+		{"corner on the pole", []LatLon{{45, 0}, {45, 90}, {45, 180}, {45, -90}, {-45, 45}, {-45, -135}}, 2},
+		{"side through the pole", []LatLon{{80, 0}, {80, 180}, {-30, 90}, {-30, -90}, {-30, 0}}, 1},
+	} {
+		diagram, err := ComputeSpherical(test.siteList, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err := diagram.WriteGeoJSON(&out); err != nil {
+			t.Fatal(err)
+		}
+		var collection struct {
+			Features []struct {
+				Geometry struct {
+					Type        string
+					Coordinates json.RawMessage
+				}
+			}
+		}
+		if err := json.Unmarshal(out.Bytes(), &collection); err != nil {
+			t.Fatal(err)
+		}
+		if len(collection.Features) != len(test.siteList) {
+			t.Fatalf("%s: got %d features, want %d", test.name, len(collection.Features), len(test.siteList))
+		}
+
+		// On the map the pieces cover it exactly
+		totalArea := 0.0
+		for id, feature := range collection.Features {
+			var polygons [][][][2]float64
+			switch feature.Geometry.Type {
+			case "Polygon":
+				var polygon [][][2]float64
+				if err := json.Unmarshal(feature.Geometry.Coordinates, &polygon); err != nil {
+					t.Fatal(err)
+				}
+				polygons = append(polygons, polygon)
+			case "MultiPolygon":
+				if err := json.Unmarshal(feature.Geometry.Coordinates, &polygons); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if id == test.split && feature.Geometry.Type != "MultiPolygon" {
+				t.Errorf("%s: cell %d across the antimeridian is a %s", test.name, id, feature.Geometry.Type)
+			}
+			for _, polygon := range polygons {
+				ring := polygon[0]
+				if ring[0] != ring[len(ring)-1] {
+					t.Fatalf("%s: cell %d has a ring which is not closed", test.name, id)
+				}
+				area := 0.0
+				for k := 1; k < len(ring); k++ {
+					if math.Abs(ring[k][0]) > 180 || math.Abs(ring[k][1]) > 90 {
+						t.Fatalf("%s: cell %d has a point %v off the map", test.name, id, ring[k])
+					}
+					area += (ring[k-1][0]*ring[k][1] - ring[k][0]*ring[k-1][1]) / 2
+				}
+				if area <= 0 {
+					t.Errorf("%s: cell %d has a ring which is not anticlockwise", test.name, id)
+				}
+				totalArea += area
+			}
+		}
+		if math.Abs(totalArea-360*180) > 1e-6 {
+			t.Errorf("%s: cells cover %v square degrees of the map, want %v", test.name, totalArea, 360*180)
+		}
+	}
+}
+
+func TestRenderSphericalVoronoi(t *testing.T) {
+	diagram, err := ComputeSpherical(uniformSpherical(20), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	voronoi := renderSphericalVoronoi(diagram, 360)
+	bounds := voronoi.Image().Bounds()
+	if bounds.Dx() != 360 || bounds.Dy() != 180 {
+		t.Fatalf("got a map %v, want 360 by 180", bounds)
+	}
+	drawn := 0
+	for x := 0; x < bounds.Dx(); x++ {
+		for y := 0; y < bounds.Dy(); y++ {
+			if r, g, b, _ := voronoi.Image().At(x, y).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+				drawn++
+			}
+		}
+	}
+	if drawn == 0 {
+		t.Error("nothing was drawn")
+	}
+}
+
+func uniformSpherical(n int) []LatLon {
+	source := rand.New(rand.NewSource(int64(n)))
+	siteList := make([]LatLon, n)
+	for k := range siteList {
+		siteList[k] = LatLon{Lat: math.Asin(2*source.Float64()-1) * 180 / math.Pi, Lon: 360*source.Float64() - 180}
+	}
+	return siteList
+}
+
+// sphericalPolygonArea - the area of a convex polygon on the unit sphere, as a fan of triangles
+func sphericalPolygonArea(cell []sphericalCorner) float64 {
+	area := 0.0
+	a := cell[0].point
+	for k := 1; k+1 < len(cell); k++ {
+		b, c := cell[k].point, cell[k+1].point
+		area += 2 * math.Atan2(math.Abs(a.dot(b.cross(c))), 1+a.dot(b)+b.dot(c)+c.dot(a))
+	}
+	return area
+}
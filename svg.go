@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// WriteSVG draws the edges and sites of the diagram as an SVG image the size of the bounding box, flipping
+// the y axis as renderVoronoi does. Edges along circles are drawn as true arcs, and other edges as their
+// polylines.
+func (diagram *Diagram) WriteSVG(w io.Writer) error {
+	box := diagram.boundingBox
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %s %s\">\n",
+		formatFloat(box.width), formatFloat(box.height), formatFloat(box.width), formatFloat(box.height))
+	fmt.Fprintf(out, "  <rect width=\"%s\" height=\"%s\" fill=\"white\"/>\n", formatFloat(box.width),
+		formatFloat(box.height))
+	fmt.Fprintln(out, `  <g fill="none" stroke="rgb(77,179,204)" stroke-width="3">`)
+	for _, edge := range diagram.CurvedEdges() {
+		fmt.Fprintf(out, "    <path d=\"%s\"/>\n", svgPath(box, edge))
+	}
+	fmt.Fprintln(out, "  </g>")
+	fmt.Fprintln(out, `  <g fill="rgb(230,128,153)">`)
+	for _, s := range diagram.sites {
+		fmt.Fprintf(out, "    <circle cx=\"%s\" cy=\"%s\" r=\"2\"/>\n", formatFloat(s.x), formatFloat(box.height-s.y))
+	}
+	fmt.Fprintln(out, "  </g>")
+	fmt.Fprintln(out, "</svg>")
+	return out.Flush()
+}
+
+// svgPath - the path data of an edge. An arc is drawn in two halves, as a single arc cannot be a whole circle.
+func svgPath(box boundingBox, edge CurvedEdge) string {
+	var path strings.Builder
+	point := func(p vertex) string {
+		return formatFloat(p.x) + " " + formatFloat(box.height-p.y)
+	}
+	path.WriteString("M " + point(edge.Points[0]))
+	if edge.Curve.Kind != CircleCurve || len(edge.Points) < 3 {
+		for _, p := range edge.Points[1:] {
+			path.WriteString(" L " + point(p))
+		}
+		return path.String()
+	}
+	middle := len(edge.Points) / 2
+	for _, half := range [][]vertex{edge.Points[:middle+1], edge.Points[middle:]} {
+		// The angle the half turns through around the centre, anticlockwise before the y axis is flipped
+		// and so clockwise after, which is the direction of the sweep flag
+		centre, turn := edge.Curve.Center, 0.0
+		for k := 1; k < len(half); k++ {
+			a := vertex{x: half[k-1].x - centre.x, y: half[k-1].y - centre.y}
+			b := vertex{x: half[k].x - centre.x, y: half[k].y - centre.y}
+			turn += math.Atan2(a.x*b.y-a.y*b.x, a.x*b.x+a.y*b.y)
+		}
+		large, sweep := 0, 0
+		if math.Abs(turn) > math.Pi {
+			large = 1
+		}
+		if turn > 0 {
+			sweep = 1
+		}
+		radius := formatFloat(edge.Curve.Radius)
+		fmt.Fprintf(&path, " A %s %s 0 %d %d %s", radius, radius, large, sweep, point(half[len(half)-1]))
+	}
+	return path.String()
+}
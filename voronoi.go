@@ -1,7 +1,10 @@
 package main
 
 import (
-	"container/heap"
+	"context"
+	"log"
+	"os"
+	"sync"
 
 	"github.com/fogleman/gg"
 )
@@ -19,14 +22,14 @@ type boundingBox struct {
 	height, width float64
 }
 
-// Event - represents an event used in Fortune's algorithm for generating voronoi diagram
-type Event struct {
-	eventType string
-	location  site
-	leafNode  *node
-}
-
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := runBench(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Some input sites
 	// siteList := []site{
 	// 	site{x: 40, y: 120},
@@ -35,13 +38,11 @@ func main() {
 	// 	site{x: 260, y: 170},
 	// }
 
-	// TODO - Investigate why these cause an error
 	siteList := []site{
 		site{x: 188, y: 170},
 		site{x: 245, y: 104},
 		site{x: 198, y: 276},
-		site{x: 412, y: 200}, // Change the y coord here such that y < 170 or y > 276 will remove error
-		// i.e. Not the second site handled
+		site{x: 412, y: 200},
 	}
 
 	// siteList := []site{}
@@ -56,48 +57,150 @@ func main() {
 	// 	fmt.Println("Site (x, y) --> (", site.x, ", ", site.y, ")")
 	// }
 
-	// Create a priority queue, put the items in it
-	pq := make(PriorityQueue, len(siteList))
-	for i, coordinates := range siteList {
-		pq[i] = &Item{
-			value:    Event{eventType: "site", location: coordinates},
-			priority: coordinates.y,
-			index:    i,
-		}
+	boundingBox := boundingBox{height: 700, width: 700}
+	diagram, err := Compute(siteList, boundingBox, Options{})
+	if err != nil {
+		log.Fatal(err)
 	}
-	heap.Init(&pq)
 
-	fortunesAlgorithm(&pq, siteList)
+	// Draw voronoi
+	drawVoronoi(boundingBox, diagram.dcel, siteList)
+}
+
+// Options - optional settings for Compute. The zero value rejects duplicate sites.
+type Options struct {
+	// Sites closer together than this are treated as duplicates (zero only matches identical sites)
+	DuplicateTolerance float64
+	DuplicatePolicy    DuplicatePolicy
+	// Sweep this many vertical strips of the sites concurrently and stitch them together (zero or one
+	// sweeps all the sites in one pass). The diagram is the same either way.
+	Parallelism int
+	// Computing the diagram fails with ErrLimitExceeded if it goes past these
+	Limits Limits
+	// How distance is measured. Diagrams under the Manhattan and Chebyshev metrics are not swept, so
+	// Parallelism and the event limit do not apply to them.
+	Metric Metric
+	// The bounding box wraps around at its sides, as on a torus, so that a site near one side is next to the
+	// cells at the other. Sites outside the box are wrapped into it and every cell is whole, running over
+	// the sides of the box where it wraps, while the dcel holds the pieces of the cells inside the box, so
+	// drawings of it tile seamlessly. The diagram is computed from nine copies of the sites.
+	Periodic bool
+	// How the weights of the sites change the distance to them. Weighted diagrams have curved edges, which
+	// CurvedEdges returns.
+	Weighting Weighting
+	// The weight of each input site, which must be nil when unweighted. Duplicates merged together take the
+	// weight of the first of them.
+	Weights []float64
+	// How far the polylines of curved edges may stray from their curves (zero is a thousandth of the larger
+	// side of the bounding box)
+	CurveTolerance float64
+	// A symmetric, positive-definite matrix M under which the distance from p to q is sqrt((p-q)ᵀ M (p-q)),
+	// stretching the cells along the directions it weighs least. The zero matrix leaves distance euclidean.
+	// The sites are taken into a space where distance is euclidean and swept there, so the metric must be
+	// Euclidean and the diagram unweighted, in a box which does not wrap around.
+	Anisotropy [2][2]float64
+}
+
+// Diagram - a voronoi diagram clipped to a bounding box
+type Diagram struct {
+	sites       []site   // After merging duplicates, indexed by SiteID
+	siteIDs     []SiteID // The SiteID of each input site
+	boundingBox boundingBox
+	dcel        *doublyConnectedEdgeList
+	metric      Metric
+	fixedCells  [][]cellCorner // Kept from computing the diagram unless euclidean and isotropic, or when periodic
+	period      vertex         // The size of the bounding box if it wraps around, otherwise zero
+
+	weighting      Weighting
+	weights        []float64    // Of the merged sites, when weighted
+	curves         []CurvedEdge // The edges of a weighted diagram
+	curveTolerance float64      // How far the curves of the edges may be from their polylines
+	anisotropy     *anisotropy  // Under which distance is measured, if any
+	unweighted     *Diagram     // Of the sites of a weighted diagram
+
+	locatorOnce sync.Once // Builds the locator the first time Locate is called
+	locator     *locator
+
+	cellsOnce      sync.Once // Clips the cells the first time they are needed for interpolation
+	cells          [][]cellCorner
+	cellNeighbours [][]SiteID
+}
+
+// Compute generates the voronoi diagram of the sites within the bounding box. Returned errors wrap
+// ErrInvalidInput or ErrDegenerateInput if the input is rejected, ErrLimitExceeded if it goes past the limits
+// in the options, or ErrNumericalFailure if the sweep fails.
+func Compute(siteList []site, boundingBox boundingBox, options Options) (*Diagram, error) {
+	return ComputeContext(context.Background(), siteList, boundingBox, options)
+}
+
+// ComputeContext is Compute with a context. The sweep checks it periodically and stops with ctx.Err() once
+// it is done.
+func ComputeContext(ctx context.Context, siteList []site, boundingBox boundingBox, options Options) (*Diagram,
+	error) {
+	var builder Builder
+	return builder.ComputeContext(ctx, siteList, boundingBox, options)
+}
+
+// Sites returns the sites of the diagram after duplicates were merged, indexed by SiteID
+func (diagram *Diagram) Sites() []site {
+	return append([]site(nil), diagram.sites...)
+}
+
+// SiteIDs returns the id of the merged site (and so the cell) that each input site belongs to
+func (diagram *Diagram) SiteIDs() []SiteID {
+	return append([]SiteID(nil), diagram.siteIDs...)
 }
 
-func fortunesAlgorithm(eventQueue *PriorityQueue, siteList []site) {
+// fortunesAlgorithm sweeps down through the events, tracing out the voronoi edges. Edges which are still
+// being traced when the queue empties are left without an origin for connectEdgesToBoundary to close.
+// Everything is allocated from the arena of the event queue. The sweep stops with an error if it goes past
+// the limits.
+func fortunesAlgorithm(eventQueue *eventQueue, limits *sweepLimits) (*doublyConnectedEdgeList, error) {
 	beachline := redblacktree{root: nil}
-	dcel := doublyConnectedEdgeList{vertices: nil, edges: nil}
+	// A diagram of n sites has fewer than 3n edges, plus its vertices on the bounding box
+	sites := len(eventQueue.sites) - eventQueue.nextSite
+	dcel := doublyConnectedEdgeList{
+		vertices: make([]*vertex, 0, 3*sites),
+		edges:    make([]*halfEdge, 0, 6*sites),
+		arena:    eventQueue.arena,
+	}
 	counter := 1
-	for eventQueue.Len() > 0 {
-		item := heap.Pop(eventQueue).(*Item)
-		if item.value.eventType == "site" {
-			// Site event
-			beachline.insert(counter, &item.value.location, eventQueue, &dcel)
-		} else {
-			// Circle event
-			beachline.removeArc(item.value.leafNode, eventQueue, &item.value.location, &dcel,
-				item.priority, counter)
+	for {
+		next, ok := eventQueue.pop()
+		if !ok {
+			break
+		}
+		if err := limits.checkEvent(&dcel); err != nil {
+			return nil, err
+		}
+
+		var err error
+		var location vertex
+		switch next.eventType {
+		case SiteEvent:
+			location = vertex{x: next.site.x, y: next.site.y}
+			err = beachline.insert(counter, next.site, eventQueue, &dcel)
+		case CircleEvent:
+			location = next.circle.center
+			err = beachline.removeArc(next.circle, eventQueue, &dcel, counter)
+		}
+		if err != nil {
+			return nil, &EventError{EventType: next.eventType, Location: location, Err: err}
 		}
 		counter++
 	}
 
 	//beachline.inorderTraversal()
 
-	// Add bounding box and connect half infinite edges to it
-	boundingBox := boundingBox{height: 700, width: 700}
-	connectEdgesToBoundary(beachline.root, boundingBox, &dcel)
-
-	// Draw voronoi
-	drawVoronoi(boundingBox, &dcel, siteList)
+	return &dcel, nil
 }
 
 func drawVoronoi(boundingBox boundingBox, dcel *doublyConnectedEdgeList, siteList []site) {
+	renderVoronoi(boundingBox, dcel, siteList).SavePNG("testingVoronoi.png")
+}
+
+// renderVoronoi draws the edges and sites into an image the size of the bounding box
+func renderVoronoi(boundingBox boundingBox, dcel *doublyConnectedEdgeList, siteList []site) *gg.Context {
 	// The drawing module sets the top left as (0, 0) and bottom right as (boundary.width, boundary.height).
 	// i.e. flipped the y axis direction from what was expected - ignore for now
 	voronoi := gg.NewContext(int(boundingBox.width), int(boundingBox.height))
@@ -117,5 +220,5 @@ func drawVoronoi(boundingBox boundingBox, dcel *doublyConnectedEdgeList, siteLis
 		voronoi.DrawPoint(site.x, boundingBox.height-site.y, 2.0)
 		voronoi.Stroke()
 	}
-	voronoi.SavePNG("testingVoronoi.png")
+	return voronoi
 }
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// Fuzz inputs are a list of sites packed as big endian uint16 (x, y) pairs which are multiplied by a scale.
+// Small integer coordinates make ties and duplicates common while the scale reaches extreme magnitudes.
+const maxFuzzSites = 64
+
+func sitesFromBytes(data []byte, scale float64) []site {
+	siteList := []site{}
+	for len(data) >= 4 && len(siteList) < maxFuzzSites {
+		x := float64(binary.BigEndian.Uint16(data[0:2]))
+		y := float64(binary.BigEndian.Uint16(data[2:4]))
+		siteList = append(siteList, site{x: x * scale, y: y * scale})
+		data = data[4:]
+	}
+	return siteList
+}
+
+func bytesFromSites(siteList []site) []byte {
+	data := make([]byte, 0, 4*len(siteList))
+	for _, site := range siteList {
+		data = binary.BigEndian.AppendUint16(data, uint16(site.x))
+		data = binary.BigEndian.AppendUint16(data, uint16(site.y))
+	}
+	return data
+}
+
+// Inputs which crashed or produced an invalid diagram are kept in testdata/fuzz.
+func addFuzzSeeds(f *testing.F) {
+	seeds := [][]site{
+		{},
+		{{x: 1, y: 1}},
+		{{x: 1, y: 1}, {x: 5, y: 3}, {x: 2, y: 5}},
+		{{x: 188, y: 170}, {x: 245, y: 104}, {x: 198, y: 276}, {x: 412, y: 200}},
+		{{x: 40, y: 120}, {x: 70, y: 150}, {x: 120, y: 70}, {x: 260, y: 170}},
+	}
+	for _, siteList := range seeds {
+		f.Add(bytesFromSites(siteList), 1.0)
+	}
+}
+
+// wellConditioned reports whether a fuzz scale keeps every intermediate value in the sweep within the
+// range of a float64 (the circle event calculation cubes coordinates).
+func wellConditioned(scale float64) bool {
+	magnitude := math.Abs(scale)
+	return magnitude == 0 || (magnitude >= 1e-90 && magnitude <= 1e90)
+}
+
+func FuzzFortunesAlgorithm(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte, scale float64) {
+		siteList := sitesFromBytes(data, scale)
+		dcel, err := fortunesAlgorithm(newEventQueue(siteList), nil)
+		if err != nil {
+			if wellConditioned(scale) || !errors.Is(err, ErrNumericalFailure) {
+				t.Fatal(err)
+			}
+			return
+		}
+		if !wellConditioned(scale) {
+			return
+		}
+		for i, halfEdge := range dcel.edges {
+			if halfEdge.twinEdge == nil || halfEdge.twinEdge.twinEdge != halfEdge {
+				t.Fatalf("half-edge %d has a broken twin", i)
+			}
+			if halfEdge.site == nil || halfEdge.twinEdge.site == nil {
+				t.Fatalf("half-edge %d does not separate two sites", i)
+			}
+		}
+	})
+}
+
+func FuzzConnectEdgesToBoundary(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte, scale float64) {
+		siteList := sitesFromBytes(data, scale)
+		dcel, err := fortunesAlgorithm(newEventQueue(siteList), nil)
+		if err != nil {
+			if wellConditioned(scale) || !errors.Is(err, ErrNumericalFailure) {
+				t.Fatal(err)
+			}
+			return
+		}
+		side := math.MaxUint16 * math.Abs(scale)
+		connectEdgesToBoundary(boundingBox{height: side, width: side}, dcel)
+		if !wellConditioned(scale) {
+			return
+		}
+		if err := dcel.validate(); err != nil {
+			t.Fatal(err)
+		}
+		if magnitude := math.Abs(scale); magnitude >= 1e-6 && magnitude <= 1e6 {
+			checkEdgesAreBisectors(t, dcel, siteList)
+		}
+	})
+}
+
+// FuzzCompute checks that the public entry point either rejects the input with one of the package errors or
+// returns a valid diagram, whatever the scale (including NaN and infinities).
+func FuzzCompute(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Add(bytesFromSites([]site{{x: 1, y: 1}, {x: 5, y: 3}}), math.NaN())
+	f.Add(bytesFromSites([]site{{x: 1, y: 1}, {x: 5, y: 3}}), math.Inf(1))
+	f.Add(bytesFromSites([]site{{x: 1, y: 1}, {x: 5, y: 3}, {x: 2, y: 5}}), 1e200)
+	f.Fuzz(func(t *testing.T, data []byte, scale float64) {
+		siteList := sitesFromBytes(data, scale)
+		side := math.MaxUint16 * math.Abs(scale)
+		diagram, err := Compute(siteList, boundingBox{height: side, width: side}, Options{})
+		if err != nil {
+			if !errors.Is(err, ErrInvalidInput) && !errors.Is(err, ErrDegenerateInput) &&
+				!errors.Is(err, ErrNumericalFailure) {
+				t.Fatalf("unexpected error type: %v", err)
+			}
+			if wellConditioned(scale) && errors.Is(err, ErrNumericalFailure) {
+				t.Fatal(err)
+			}
+			return
+		}
+		if err := diagram.dcel.validate(); err != nil {
+			t.Fatal(err)
+		}
+		if magnitude := math.Abs(scale); magnitude >= 1e-6 && magnitude <= 1e6 {
+			checkEdgesAreBisectors(t, diagram.dcel, siteList)
+		}
+	})
+}
+
+func TestComputeRejectsInput(t *testing.T) {
+	box := boundingBox{height: 100, width: 100}
+	tests := []struct {
+		name     string
+		siteList []site
+		box      boundingBox
+		want     error
+		index    int
+	}{
+		{"no sites", []site{}, box, ErrDegenerateInput, -1},
+		{"single site", []site{{x: 1, y: 2}}, box, ErrDegenerateInput, 0},
+		{"duplicate", []site{{x: 1, y: 2}, {x: 5, y: 5}, {x: 1, y: 2}}, box, ErrDegenerateInput, 2},
+		{"NaN", []site{{x: 1, y: 2}, {x: math.NaN(), y: 5}}, box, ErrInvalidInput, 1},
+		{"infinite", []site{{x: 1, y: math.Inf(-1)}, {x: 3, y: 5}}, box, ErrInvalidInput, 0},
+		{"empty box", []site{{x: 1, y: 2}, {x: 3, y: 5}}, boundingBox{}, ErrInvalidInput, -1},
+	}
+	for _, test := range tests {
+		_, err := Compute(test.siteList, test.box, Options{})
+		var inputError *InputError
+		if !errors.Is(err, test.want) || !errors.As(err, &inputError) || inputError.Index != test.index {
+			t.Errorf("%s: got error %v, want %v at index %d", test.name, err, test.want, test.index)
+		}
+	}
+}
+
+// checkEdgesAreBisectors checks that both ends and the midpoint of every edge are equidistant from the
+// sites either side of it, and that no other site is closer.
+func checkEdgesAreBisectors(t *testing.T, dcel *doublyConnectedEdgeList, siteList []site) {
+	t.Helper()
+	extent := 0.0
+	for _, site := range siteList {
+		extent = math.Max(extent, math.Max(math.Abs(site.x), math.Abs(site.y)))
+	}
+	for i := 0; i < len(dcel.edges); i += 2 {
+		halfEdge := dcel.edges[i]
+		start, end := halfEdge.originVertex, halfEdge.twinEdge.originVertex
+		points := []vertex{*start, *end, {x: (start.x + end.x) / 2, y: (start.y + end.y) / 2}}
+		for _, point := range points {
+			distance := math.Hypot(point.x-halfEdge.site.x, point.y-halfEdge.site.y)
+			twinDistance := math.Hypot(point.x-halfEdge.twinEdge.site.x, point.y-halfEdge.twinEdge.site.y)
+			tolerance := 1e-6 * math.Max(extent, distance)
+			if math.Abs(distance-twinDistance) > tolerance {
+				t.Fatalf("edge %d point %v is %v from %v but %v from %v", i/2, point, distance, *halfEdge.site,
+					twinDistance, *halfEdge.twinEdge.site)
+			}
+			for _, other := range siteList {
+				if d := math.Hypot(point.x-other.x, point.y-other.y); d < distance-tolerance {
+					t.Fatalf("edge %d point %v between %v and %v is closer to %v", i/2, point, *halfEdge.site,
+						*halfEdge.twinEdge.site, other)
+				}
+			}
+		}
+	}
+}
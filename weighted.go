@@ -0,0 +1,743 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Weighting - how the weights of the sites change the distance to them. Weighted diagrams are computed
+// pair by pair rather than swept, so Parallelism and the event limit only apply to the unweighted diagram
+// of the sites swept alongside for the empty circles. They need the euclidean metric and a box which does
+// not wrap around.
+//
+// Locate, CellStats and CurvedEdges use the weighted distance. Natural neighbour interpolation needs an
+// unweighted diagram, and the empty circles, the roadmap clearances and the graphs of the neighbours
+// measure plain distance to the sites.
+type Weighting int
+
+const (
+	// Unweighted - the plain distance to each site
+	Unweighted Weighting = iota
+	// AdditiveWeights - the distance to a site less its weight (the Apollonius diagram). Borders are
+	// branches of hyperbolas, and a site closer to a heavier one than the difference of their weights
+	// has no cell.
+	AdditiveWeights
+	// MultiplicativeWeights - the distance to a site divided by its weight, which must be positive.
+	// Borders are circles around the lighter site (Apollonius circles), so a cell may surround others or
+	// come in several pieces.
+	MultiplicativeWeights
+)
+
+func (weighting Weighting) String() string {
+	switch weighting {
+	case AdditiveWeights:
+		return "additive"
+	case MultiplicativeWeights:
+		return "multiplicative"
+	}
+	return "unweighted"
+}
+
+// distance - the weighted distance from p to a site
+func (weighting Weighting) distance(p, s vertex, weight float64) float64 {
+	distance := math.Hypot(p.x-s.x, p.y-s.y)
+	switch weighting {
+	case AdditiveWeights:
+		return distance - weight
+	case MultiplicativeWeights:
+		return distance / weight
+	}
+	return distance
+}
+
+// CurveKind - the shape of the curve an edge of a diagram runs along
+type CurveKind int
+
+const (
+	// LineCurve - a straight line
+	LineCurve CurveKind = iota
+	// CircleCurve - an arc of the circle with Center and Radius
+	CircleCurve
+	// HyperbolaCurve - a piece of the branch of the hyperbola whose points are Difference further from the
+	// first focus than from the second
+	HyperbolaCurve
+)
+
+// Curve - an analytic description of the curve an edge runs along
+type Curve struct {
+	Kind       CurveKind
+	Center     vertex
+	Radius     float64
+	Foci       [2]vertex
+	Difference float64
+}
+
+// CurvedEdge - an edge of a diagram clipped to the bounding box, sampled as a polyline no further than the
+// curve tolerance from the curve it runs along. The first site is on the left going along the points. An
+// edge which is a whole circle starts and ends at the same point.
+type CurvedEdge struct {
+	Sites  [2]SiteID
+	Points []vertex
+	Curve  Curve
+}
+
+// CurvedEdges returns the edges of the diagram with the curves they run along. The edges of an unweighted
+// diagram are its straight clipped edges.
+func (diagram *Diagram) CurvedEdges() []CurvedEdge {
+	if diagram.weighting != Unweighted {
+		edges := make([]CurvedEdge, len(diagram.curves))
+		for k, edge := range diagram.curves {
+			edges[k] = edge
+			edges[k].Points = append([]vertex(nil), edge.Points...)
+		}
+		return edges
+	}
+	var edges []CurvedEdge
+	for _, segment := range clippedSegments(diagram) {
+		edge := CurvedEdge{Sites: segment.sites, Points: []vertex{segment.p, segment.q}}
+		if cross(segment.p, segment.q, diagram.siteImage(segment.sites[0], segment.p)) < 0 {
+			edge.Sites[0], edge.Sites[1] = edge.Sites[1], edge.Sites[0]
+		}
+		edges = append(edges, edge)
+	}
+	return edges
+}
+
+// validateWeights checks the weighting options against the sites before they are merged
+func validateWeights(siteList []site, options Options) error {
+	switch options.Weighting {
+	case Unweighted:
+		if options.Weights != nil {
+			return &InputError{Index: -1, Reason: "weights need a weighting", Err: ErrInvalidInput}
+		}
+		return nil
+	case AdditiveWeights, MultiplicativeWeights:
+	default:
+		return &InputError{Index: -1, Reason: fmt.Sprintf("unknown weighting %d", options.Weighting),
+			Err: ErrInvalidInput}
+	}
+	if options.Metric != Euclidean || options.Periodic {
+		return &InputError{Index: -1, Reason: "weights need a euclidean diagram in a box which does not wrap",
+			Err: ErrInvalidInput}
+	}
+	if len(options.Weights) != len(siteList) {
+		return &InputError{Index: -1, Reason: fmt.Sprintf("got %d weights for %d sites", len(options.Weights),
+			len(siteList)), Err: ErrInvalidInput}
+	}
+	if !isFinite(options.CurveTolerance) || options.CurveTolerance < 0 {
+		return &InputError{Index: -1, Reason: "curve tolerance must be finite and not negative",
+			Err: ErrInvalidInput}
+	}
+	for i, weight := range options.Weights {
+		if !isFinite(weight) || (options.Weighting == MultiplicativeWeights && weight <= 0) {
+			return &InputError{Index: i, Site: siteList[i], Reason: fmt.Sprintf("has weight %v, which is not "+
+				"allowed with %v weights", weight, options.Weighting), Err: ErrInvalidInput}
+		}
+	}
+	return nil
+}
+
+// mergedWeights - the weight of each merged site, which is that of the first input site merged into it
+func mergedWeights(weights []float64, siteIDs []SiteID, sites int) []float64 {
+	merged := make([]float64, sites)
+	seen := make([]bool, sites)
+	for k, id := range siteIDs {
+		if !seen[id] {
+			merged[id], seen[id] = weights[k], true
+		}
+	}
+	return merged
+}
+
+// nearestWeightedSite returns whichever of the candidate sites (or all of the sites if there are no
+// candidates) is nearest p under the weighting, breaking ties by plain distance
+func nearestWeightedSite(weighting Weighting, siteList []site, weights []float64, candidates []SiteID,
+	p vertex) SiteID {
+	nearest, nearestDistance := SiteID(-1), math.Inf(1)
+	consider := func(id SiteID) {
+		distance := weighting.distance(p, vertex(siteList[id]), weights[id])
+		if nearest < 0 || distance < nearestDistance || (distance == nearestDistance &&
+			Euclidean.closer(p, vertex(siteList[id]), vertex(siteList[nearest]))) {
+			nearest, nearestDistance = id, distance
+		}
+	}
+	if candidates == nil {
+		for id := range siteList {
+			consider(SiteID(id))
+		}
+	}
+	for _, id := range candidates {
+		consider(id)
+	}
+	return nearest
+}
+
+// angleSet - a set of angles, as sorted intervals which do not overlap, within [0, 2π]
+type angleSet [][2]float64
+
+// fullCircle - every angle
+var fullCircle = angleSet{{0, 2 * math.Pi}}
+
+// sinusoid - a cos(mθ) + b sin(mθ) + c
+type sinusoid struct {
+	a, b, c float64
+	m       int
+}
+
+// fitSinusoid finds the sinusoid of the given harmonic through a function which is known to be one, from
+// its values a quarter and a half of a period apart
+func fitSinusoid(m int, f func(angle float64) float64) sinusoid {
+	quarter := math.Pi / float64(2*m)
+	start, middle, end := f(0), f(quarter), f(2*quarter)
+	c := (start + end) / 2
+	return sinusoid{a: (start - end) / 2, b: middle - c, c: c, m: m}
+}
+
+// nonPositive returns the angles at which the sinusoid is zero or less, leaving out any single angles
+// where it only touches zero
+func (wave sinusoid) nonPositive() angleSet {
+	amplitude := math.Hypot(wave.a, wave.b)
+	if amplitude <= 1e-14*math.Abs(wave.c) || wave.c <= -amplitude {
+		if wave.c <= 0 {
+			return fullCircle
+		}
+		return nil
+	}
+	if wave.c >= amplitude {
+		return nil
+	}
+	// a cos(mθ) + b sin(mθ) = amplitude cos(mθ - phase), which is at most -c between the two turns
+	phase, turn := math.Atan2(wave.b, wave.a), math.Acos(-wave.c/amplitude)
+	var set angleSet
+	m := float64(wave.m)
+	for k := 0; k < wave.m; k++ {
+		lo := math.Mod((turn+phase+2*math.Pi*float64(k))/m, 2*math.Pi)
+		if lo < 0 {
+			lo += 2 * math.Pi
+		}
+		hi := lo + (2*math.Pi-2*turn)/m
+		if hi > 2*math.Pi {
+			set = append(set, [2]float64{lo, 2 * math.Pi}, [2]float64{0, hi - 2*math.Pi})
+		} else {
+			set = append(set, [2]float64{lo, hi})
+		}
+	}
+	sort.Slice(set, func(i, j int) bool { return set[i][0] < set[j][0] })
+	return set
+}
+
+// intersect returns the angles in both sets
+func (set angleSet) intersect(other angleSet) angleSet {
+	var both angleSet
+	for i, j := 0, 0; i < len(set) && j < len(other); {
+		lo, hi := math.Max(set[i][0], other[j][0]), math.Min(set[i][1], other[j][1])
+		if hi > lo {
+			both = append(both, [2]float64{lo, hi})
+		}
+		if set[i][1] < other[j][1] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return both
+}
+
+// arcs returns the intervals of the set as runs of angles, joining the run ending at 2π to the one starting
+// at 0, and leaving out runs too short to matter. A whole circle is one run of 2π.
+func (set angleSet) arcs() [][2]float64 {
+	var arcs [][2]float64
+	for _, interval := range set {
+		if interval[1]-interval[0] > 1e-12 {
+			arcs = append(arcs, interval)
+		}
+	}
+	if n := len(arcs); n > 1 && arcs[0][0] == 0 && arcs[n-1][1] == 2*math.Pi {
+		arcs[0] = [2]float64{arcs[n-1][0], arcs[0][1] + 2*math.Pi}
+		arcs = arcs[:n-1]
+	}
+	return arcs
+}
+
+// weightedBisector - the curve of points the same weighted distance from two sites, traced by an angle
+// with the left site on its left as the angle increases. Each condition on it is a sinusoid in the angle
+// which is zero or less where the condition holds.
+type weightedBisector struct {
+	left, right SiteID
+	curve       Curve
+	point       func(angle float64) vertex
+	domain      angleSet // The angles which trace the curve
+	harmonic    int      // Of the conditions that a third site is no nearer
+	// inside is zero or less where the curve is on the inner side of the line of points p with normal·p = offset
+	inside func(angle float64, normal vertex, offset float64) float64
+	// nearer is zero or less where the third site is no nearer than the two sites. It is false if the
+	// third site is never nearer.
+	nearer func(k SiteID) (func(angle float64) float64, bool)
+}
+
+// newWeightedBisector returns the bisector of sites i and j, or false if one of them is nearer everywhere.
+// Under additive weights, or multiplicative ones which are equal, the bisector is traced by the angle
+// around i, at which p = sᵢ + N/D u with u = (cos θ, sin θ), N = L² - δ², D = 2(u·v - δ), v = sⱼ - sᵢ,
+// L = |v| and δ the weight of i less that of j (zero for multiplicative weights). Otherwise it is the
+// circle around the lighter site traced by the angle around its centre.
+func newWeightedBisector(weighting Weighting, siteList []site, weights []float64, i, j SiteID) (*weightedBisector,
+	bool) {
+	if weighting == MultiplicativeWeights && weights[i] != weights[j] {
+		return newApolloniusCircle(siteList, weights, i, j), true
+	}
+	s := vertex(siteList[i])
+	v := vertex{x: siteList[j].x - s.x, y: siteList[j].y - s.y}
+	length, delta := math.Hypot(v.x, v.y), 0.0
+	if weighting == AdditiveWeights {
+		delta = weights[i] - weights[j]
+	}
+	if length <= math.Abs(delta) {
+		return nil, false
+	}
+	n := length*length - delta*delta
+	denominator := func(angle float64, v vertex, delta float64) float64 {
+		return 2 * (math.Cos(angle)*v.x + math.Sin(angle)*v.y - delta)
+	}
+	bisector := &weightedBisector{left: i, right: j, harmonic: 1}
+	bisector.curve = Curve{Kind: LineCurve}
+	if delta != 0 {
+		bisector.curve = Curve{Kind: HyperbolaCurve, Foci: [2]vertex{s, vertex(siteList[j])}, Difference: delta}
+	}
+	bisector.point = func(angle float64) vertex {
+		r := n / denominator(angle, v, delta)
+		return vertex{x: s.x + r*math.Cos(angle), y: s.y + r*math.Sin(angle)}
+	}
+	bisector.domain = fitSinusoid(1, func(angle float64) float64 { return -denominator(angle, v, delta) }).nonPositive()
+	bisector.inside = func(angle float64, normal vertex, offset float64) float64 {
+		return (normal.x*s.x+normal.y*s.y-offset)*denominator(angle, v, delta) +
+			n*(normal.x*math.Cos(angle)+normal.y*math.Sin(angle))
+	}
+	if weighting == AdditiveWeights {
+		bisector.nearer = func(k SiteID) (func(angle float64) float64, bool) {
+			vk := vertex{x: siteList[k].x - s.x, y: siteList[k].y - s.y}
+			lengthK, deltaK := math.Hypot(vk.x, vk.y), weights[i]-weights[k]
+			if lengthK <= deltaK {
+				return nil, false
+			}
+			nk := lengthK*lengthK - deltaK*deltaK
+			return func(angle float64) float64 {
+				return denominator(angle, vk, deltaK)/nk - denominator(angle, v, delta)/n
+			}, true
+		}
+		return bisector, true
+	}
+	// Under equal multiplicative weights w, k is no nearer where
+	// wₖ² N² ≤ w² (N² - 2 N D u·vₖ + |vₖ|² D²)
+	bisector.harmonic = 2
+	bisector.nearer = func(k SiteID) (func(angle float64) float64, bool) {
+		vk := vertex{x: siteList[k].x - s.x, y: siteList[k].y - s.y}
+		w, wk := weights[i]*weights[i], weights[k]*weights[k]
+		return func(angle float64) float64 {
+			d := denominator(angle, v, 0)
+			return (wk-w)*n*n + 2*w*n*d*(math.Cos(angle)*vk.x+math.Sin(angle)*vk.y) - w*(vk.x*vk.x+vk.y*vk.y)*d*d
+		}, true
+	}
+	return bisector, true
+}
+
+// newApolloniusCircle returns the bisector of two sites with different multiplicative weights, the circle
+// of points p with |p - sₐ| = λ |p - s_b| around the lighter site a, where λ = wₐ / w_b
+func newApolloniusCircle(siteList []site, weights []float64, i, j SiteID) *weightedBisector {
+	if weights[i] > weights[j] {
+		i, j = j, i
+	}
+	a, b := vertex(siteList[i]), vertex(siteList[j])
+	ratio := weights[i] / weights[j]
+	scale := 1 - ratio*ratio
+	centre := vertex{x: (a.x - ratio*ratio*b.x) / scale, y: (a.y - ratio*ratio*b.y) / scale}
+	radius := ratio * math.Hypot(b.x-a.x, b.y-a.y) / scale
+	bisector := &weightedBisector{left: i, right: j, harmonic: 1, domain: fullCircle,
+		curve: Curve{Kind: CircleCurve, Center: centre, Radius: radius}}
+	bisector.point = func(angle float64) vertex {
+		return vertex{x: centre.x + radius*math.Cos(angle), y: centre.y + radius*math.Sin(angle)}
+	}
+	bisector.inside = func(angle float64, normal vertex, offset float64) float64 {
+		p := bisector.point(angle)
+		return normal.x*p.x + normal.y*p.y - offset
+	}
+	bisector.nearer = func(k SiteID) (func(angle float64) float64, bool) {
+		s := vertex(siteList[k])
+		w, wk := weights[i]*weights[i], weights[k]*weights[k]
+		return func(angle float64) float64 {
+			p := bisector.point(angle)
+			return wk*((p.x-a.x)*(p.x-a.x)+(p.y-a.y)*(p.y-a.y)) - w*((p.x-s.x)*(p.x-s.x)+(p.y-s.y)*(p.y-s.y))
+		}, true
+	}
+	return bisector
+}
+
+// weightedSide - a side of a weighted cell, running anticlockwise around it from one point to the next,
+// with the site across it or -1 for the bounding box
+type weightedSide struct {
+	from, to vertex
+	across   SiteID
+}
+
+// weightedDiagram computes the curved edges of the weighted diagram of the diagram's sites, and from them
+// its cells and dcel. Each pair of sites whose cells may meet is tried in turn: the angles tracing their
+// bisector are cut down to those inside the bounding box and then, trying the sites nearest the first one
+// first, to those where none of the sites which may be nearer is. What is left is sampled into polylines
+// no further than tolerance from the curve.
+func weightedDiagram(limits *sweepLimits, diagram *Diagram) (*doublyConnectedEdgeList, [][]cellCorner,
+	[]CurvedEdge, error) {
+	siteList, weights, weighting, box := diagram.sites, diagram.weights, diagram.weighting, diagram.boundingBox
+	size, tolerance := math.Max(box.width, box.height), diagram.curveTolerance
+	index := newSiteIndex(siteList, vertex{}, vertex{x: box.width, y: box.height})
+
+	// Under additive weights a site closer to a heavier one than the difference of their weights has no cell
+	dominated := make([]bool, len(siteList))
+	if weighting == AdditiveWeights {
+		heaviest := math.Inf(-1)
+		for _, weight := range weights {
+			heaviest = math.Max(heaviest, weight)
+		}
+		for k := range siteList {
+			index.visitRings(&siteList[k], func(ring float64) bool {
+				return !dominated[k] && ring <= heaviest-weights[k]
+			}, func(m SiteID) {
+				if int(m) != k && weights[m]-weights[k] >= math.Hypot(siteList[m].x-siteList[k].x,
+					siteList[m].y-siteList[k].y) {
+					dominated[k] = true
+				}
+			})
+		}
+	}
+	reach, reached := newReachIndex(index, siteList, weights, weighting, dominated, box)
+
+	// The sides of the box as the normal pointing out of it and the offset along the normal
+	sides := [][3]float64{{0, -1, 0}, {1, 0, box.width}, {0, 1, box.height}, {-1, 0, 0}}
+	snap := newPointSnapper(1e-7 * size)
+	var curves []CurvedEdge
+	// The site plus one whose candidates each site was last added to
+	added, pairs := make([]SiteID, len(siteList)), 0
+	for id := range siteList {
+		i := SiteID(id)
+		if dominated[i] {
+			continue
+		}
+		// The sites whose cells may reach into the buckets that of i may reach into, nearest first
+		var candidates []SiteID
+		for _, bucket := range reached[i] {
+			for _, k := range reach.buckets[bucket] {
+				if added[k] != i+1 {
+					added[k] = i + 1
+					candidates = append(candidates, k)
+				}
+			}
+		}
+		sort.Slice(candidates, func(a, b int) bool {
+			return Euclidean.closer(vertex(siteList[i]), vertex(siteList[candidates[a]]),
+				vertex(siteList[candidates[b]]))
+		})
+		for _, j := range candidates {
+			if j <= i {
+				continue
+			}
+			if pairs++; pairs%contextCheckInterval == 0 {
+				if err := limits.checkContext(); err != nil {
+					return nil, nil, nil, err
+				}
+			}
+			bisector, ok := newWeightedBisector(weighting, siteList, weights, i, j)
+			if !ok {
+				continue
+			}
+			set := bisector.domain
+			for _, side := range sides {
+				if len(set) == 0 {
+					break
+				}
+				set = set.intersect(fitSinusoid(1, func(angle float64) float64 {
+					return bisector.inside(angle, vertex{x: side[0], y: side[1]}, side[2])
+				}).nonPositive())
+			}
+			for _, k := range candidates {
+				if len(set) == 0 {
+					break
+				}
+				if k == i || k == j {
+					continue
+				}
+				if nearer, ok := bisector.nearer(k); ok {
+					set = set.intersect(fitSinusoid(bisector.harmonic, nearer).nonPositive())
+				}
+			}
+			for _, arc := range set.arcs() {
+				// Every site which is nearest somewhere along an edge is a candidate, so an edge which is cut
+				// at all is cut at its ends. An arc with no candidate nearer may still lie where neither cell
+				// reaches, which is found from the sites which may be nearest its middle.
+				middle := bisector.point((arc[0] + arc[1]) / 2)
+				column, row := reach.bucket(middle)
+				nearest := nearestWeightedSite(weighting, siteList, weights, reach.buckets[row*reach.columns+column],
+					middle)
+				margin := 1e-9 * size
+				if weighting == MultiplicativeWeights {
+					margin /= weights[i]
+				}
+				if nearest >= 0 && nearest != i && nearest != j && weighting.distance(middle,
+					vertex(siteList[nearest]), weights[nearest]) < weighting.distance(middle, vertex(siteList[i]),
+					weights[i])-margin {
+					continue
+				}
+				if edge, ok := sampleBisector(bisector, arc, tolerance, snap, box); ok {
+					curves = append(curves, edge)
+				}
+			}
+		}
+	}
+
+	cells := weightedCells(siteList, weights, weighting, box, curves, reach)
+	dcel, err := dcelFromCells(siteList, cells, box)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return dcel, cells, curves, nil
+}
+
+// newReachIndex returns buckets over the bounding box holding the sites whose cells may reach into each,
+// and the buckets each site's cell may reach into. Every point of a bucket is no further from one of the
+// sites near it than the furthest corner of the bucket is, so a site whose weighted distance to the nearest
+// point of the bucket is more than that for all of them is never nearest there.
+func newReachIndex(index *siteIndex, siteList []site, weights []float64, weighting Weighting, dominated []bool,
+	box boundingBox) (*siteIndex, [][]int) {
+	reach := *index
+	reach.buckets = make([][]SiteID, index.columns*index.rows)
+	corners := func(column, row int) (low, high vertex) {
+		low = vertex{x: index.low.x + float64(column)*index.width, y: index.low.y + float64(row)*index.height}
+		high = vertex{x: math.Min(low.x+index.width, box.width), y: math.Min(low.y+index.height, box.height)}
+		return low, high
+	}
+	bound, furthestBound := make([]float64, len(reach.buckets)), 0.0
+	for row := 0; row < index.rows; row++ {
+		for column := 0; column < index.columns; column++ {
+			low, high := corners(column, row)
+			b := row*index.columns + column
+			bound[b] = math.Inf(1)
+			if low.x >= box.width || low.y >= box.height {
+				continue
+			}
+			middle := site{x: (low.x + high.x) / 2, y: (low.y + high.y) / 2}
+			index.visitRings(&middle, func(float64) bool { return math.IsInf(bound[b], 1) }, func(k SiteID) {
+				s := siteList[k]
+				furthest := math.Hypot(math.Max(s.x-low.x, high.x-s.x), math.Max(s.y-low.y, high.y-s.y))
+				if weighting == MultiplicativeWeights {
+					bound[b] = math.Min(bound[b], furthest/weights[k])
+				} else {
+					bound[b] = math.Min(bound[b], furthest-weights[k])
+				}
+			})
+			furthestBound = math.Max(furthestBound, bound[b])
+		}
+	}
+
+	// Lengths within which a cell may reach, allowing for rounding
+	margin, size := 1e-9*math.Max(box.width, box.height), math.Max(box.width, box.height)
+	within := func(bound float64, k SiteID) float64 {
+		if weighting == MultiplicativeWeights {
+			return bound*weights[k] + margin
+		}
+		return bound + weights[k] + margin
+	}
+	reached := make([][]int, len(siteList))
+	for id, s := range siteList {
+		k := SiteID(id)
+		if dominated[k] {
+			continue
+		}
+		// Any bucket reached is within the square around the site that the furthest bound allows
+		extent := math.Min(within(furthestBound, k), 2*size)
+		if extent < 0 {
+			continue
+		}
+		lowColumn, lowRow := index.bucket(vertex{x: s.x - extent, y: s.y - extent})
+		highColumn, highRow := index.bucket(vertex{x: s.x + extent, y: s.y + extent})
+		for row := lowRow; row <= highRow; row++ {
+			for column := lowColumn; column <= highColumn; column++ {
+				low, high := corners(column, row)
+				b := row*index.columns + column
+				nearest := math.Hypot(math.Max(0, math.Max(low.x-s.x, s.x-high.x)),
+					math.Max(0, math.Max(low.y-s.y, s.y-high.y)))
+				if !math.IsInf(bound[b], 1) && nearest <= within(bound[b], k) {
+					reach.buckets[b] = append(reach.buckets[b], k)
+					reached[k] = append(reached[k], b)
+				}
+			}
+		}
+	}
+	return &reach, reached
+}
+
+// sampleBisector samples the piece of a bisector traced by the angles of an arc, splitting each piece in
+// half while its middle is further than tolerance from the chord. Its ends are snapped together with those
+// of the other edges and onto the sides of the box. It returns false if the piece has shrunk to a point.
+func sampleBisector(bisector *weightedBisector, arc [2]float64, tolerance float64, snap *pointSnapper,
+	box boundingBox) (CurvedEdge, bool) {
+	edge := CurvedEdge{Sites: [2]SiteID{bisector.left, bisector.right}, Curve: bisector.curve}
+	start, end := bisector.point(arc[0]), bisector.point(arc[1])
+	closed := arc[1]-arc[0] >= 2*math.Pi
+	if !closed {
+		start, end = snap.point(snap.toSides(box, start)), snap.point(snap.toSides(box, end))
+		if start == end {
+			return edge, false
+		}
+	}
+	edge.Points = []vertex{start}
+	if bisector.curve.Kind == LineCurve {
+		edge.Points = append(edge.Points, end)
+		return edge, true
+	}
+	var split func(lo, hi float64, p, q vertex, depth int)
+	split = func(lo, hi float64, p, q vertex, depth int) {
+		middle := (lo + hi) / 2
+		if m := bisector.point(middle); depth < 24 && distanceToSegment(m, p, q) > tolerance {
+			split(lo, middle, p, m, depth+1)
+			split(middle, hi, m, q, depth+1)
+			return
+		}
+		edge.Points = append(edge.Points, q)
+	}
+	pieces := math.Ceil((arc[1] - arc[0]) / (math.Pi / 8))
+	step := (arc[1] - arc[0]) / pieces
+	previous := start
+	for k := 1; k <= int(pieces); k++ {
+		lo, hi := arc[0]+step*float64(k-1), arc[0]+step*float64(k)
+		next := bisector.point(hi)
+		if k == int(pieces) {
+			next = end
+		}
+		split(lo, hi, previous, next, 0)
+		previous = next
+	}
+	if closed {
+		edge.Points[len(edge.Points)-1] = start
+	}
+	return edge, true
+}
+
+// weightedCells joins the sides of each cell into rings: its edges with the site on their left, and the
+// pieces of the sides of the box between where the edges meet them, each belonging to the site nearest its
+// middle. A cell of several rings - pieces, or a cell with holes around others - is made one polygon by
+// going from the start of the first ring to the start of each other ring and back, along sides with its
+// own site across them, which add nothing to its area.
+func weightedCells(siteList []site, weights []float64, weighting Weighting, box boundingBox,
+	curves []CurvedEdge, reach *siteIndex) [][]cellCorner {
+	sides := make([][]weightedSide, len(siteList))
+	// Going anticlockwise round the box, the distance along its sides from the origin
+	along := func(p vertex) float64 {
+		switch {
+		case p.y == 0 && p.x < box.width:
+			return p.x
+		case p.x == box.width && p.y < box.height:
+			return box.width + p.y
+		case p.y == box.height && p.x > 0:
+			return 2*box.width + box.height - p.x
+		}
+		return 2*(box.width+box.height) - p.y
+	}
+	onBox := func(p vertex) bool { return p.x == 0 || p.y == 0 || p.x == box.width || p.y == box.height }
+	boundary := []vertex{{0, 0}, {box.width, 0}, {box.width, box.height}, {0, box.height}}
+	for _, edge := range curves {
+		left, right := edge.Sites[0], edge.Sites[1]
+		for k := 1; k < len(edge.Points); k++ {
+			p, q := edge.Points[k-1], edge.Points[k]
+			sides[left] = append(sides[left], weightedSide{from: p, to: q, across: right})
+			sides[right] = append(sides[right], weightedSide{from: q, to: p, across: left})
+		}
+		for _, end := range []vertex{edge.Points[0], edge.Points[len(edge.Points)-1]} {
+			if onBox(end) {
+				boundary = append(boundary, end)
+			}
+		}
+	}
+	sort.Slice(boundary, func(i, j int) bool { return along(boundary[i]) < along(boundary[j]) })
+	for k, p := range boundary {
+		q := boundary[(k+1)%len(boundary)]
+		if p == q {
+			continue
+		}
+		middle := vertex{x: (p.x + q.x) / 2, y: (p.y + q.y) / 2}
+		column, row := reach.bucket(middle)
+		owner := nearestWeightedSite(weighting, siteList, weights, reach.buckets[row*reach.columns+column], middle)
+		sides[owner] = append(sides[owner], weightedSide{from: p, to: q, across: -1})
+	}
+
+	cells := make([][]cellCorner, len(siteList))
+	for id, cellSides := range sides {
+		leaving := map[vertex][]int{}
+		for k, side := range cellSides {
+			leaving[side.from] = append(leaving[side.from], k)
+		}
+		used := make([]bool, len(cellSides))
+		var rings [][]cellCorner
+		for first := range cellSides {
+			if used[first] {
+				continue
+			}
+			var ring []cellCorner
+			for k := first; k >= 0; {
+				used[k] = true
+				ring = append(ring, cellCorner{cellSides[k].from, cellSides[k].across})
+				next := -1
+				for _, candidate := range leaving[cellSides[k].to] {
+					if !used[candidate] {
+						next = candidate
+						break
+					}
+				}
+				k = next
+			}
+			// Start the ring off the box, so that the sides joining the rings do not run along it
+			for k, corner := range ring {
+				if !onBox(corner.vertex) {
+					ring = append(ring[k:], ring[:k]...)
+					break
+				}
+			}
+			rings = append(rings, ring)
+		}
+		for n, ring := range rings {
+			if n > 0 {
+				cells[id] = append(cells[id], cellCorner{rings[0][0].vertex, SiteID(id)})
+			}
+			cells[id] = append(cells[id], ring...)
+			if n > 0 {
+				cells[id] = append(cells[id], cellCorner{ring[0].vertex, SiteID(id)})
+			}
+		}
+	}
+	return cells
+}
+
+// newCellIndex returns buckets over the bounding box holding the sites whose weighted cells may reach into
+// each. The polylines of curved edges may cross near where they meet, which the trapezoidal map cannot
+// hold, so Locate looks among these instead. A cell is within the curve tolerance of its polygon.
+func newCellIndex(diagram *Diagram) *siteIndex {
+	box := diagram.boundingBox
+	index := newSiteIndex(diagram.sites, vertex{}, vertex{x: box.width, y: box.height})
+	index.buckets = make([][]SiteID, index.columns*index.rows)
+	margin := 2 * diagram.curveTolerance
+	for id, cell := range diagram.fixedCells {
+		if len(cell) == 0 {
+			continue
+		}
+		low, high := cell[0].vertex, cell[0].vertex
+		for _, corner := range cell {
+			low = vertex{x: math.Min(low.x, corner.x), y: math.Min(low.y, corner.y)}
+			high = vertex{x: math.Max(high.x, corner.x), y: math.Max(high.y, corner.y)}
+		}
+		lowColumn, lowRow := index.bucket(vertex{x: low.x - margin, y: low.y - margin})
+		highColumn, highRow := index.bucket(vertex{x: high.x + margin, y: high.y + margin})
+		for row := lowRow; row <= highRow; row++ {
+			for column := lowColumn; column <= highColumn; column++ {
+				index.buckets[row*index.columns+column] = append(index.buckets[row*index.columns+column], SiteID(id))
+			}
+		}
+	}
+	return index
+}
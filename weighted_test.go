@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestWeightedDiagram(t *testing.T) {
+	for _, weighting := range []Weighting{AdditiveWeights, MultiplicativeWeights} {
+		source := rand.New(rand.NewSource(18))
+		siteList := uniformSites(80, source)
+		weights := make([]float64, len(siteList))
+		for k := range weights {
+			weights[k] = 1 + source.Float64()*2
+			if weighting == AdditiveWeights {
+				weights[k] = source.Float64() * 40
+			}
+		}
+		diagram, err := Compute(siteList, benchmarkBox, Options{Weighting: weighting, Weights: weights})
+		if err != nil {
+			t.Fatalf("%v: %v", weighting, err)
+		}
+		if err := diagram.dcel.validate(); err != nil {
+			t.Fatalf("%v: %v", weighting, err)
+		}
+
+		// Every point of an edge is as near its two sites as each other, and no other site is nearer
+		distance := func(id SiteID, p vertex) float64 {
+			return weighting.distance(p, vertex(siteList[id]), weights[id])
+		}
+		edges := diagram.CurvedEdges()
+		if len(edges) < len(siteList) {
+			t.Fatalf("%v: got %d edges between %d sites", weighting, len(edges), len(siteList))
+		}
+		for _, edge := range edges {
+			for _, p := range edge.Points {
+				a, b := distance(edge.Sites[0], p), distance(edge.Sites[1], p)
+				nearest := distance(nearestWeightedSite(weighting, siteList, weights, nil, p), p)
+				if math.Abs(a-b) > 1e-6 || a-nearest > 1e-6 {
+					t.Fatalf("%v: edge point %v is %v from %d and %v from %d, and %v from the nearest", weighting, p, a,
+						edge.Sites[0], b, edge.Sites[1], nearest)
+				}
+			}
+		}
+
+		// Every point is in the cell of the nearest site, unless it is between a curve and its polyline
+		tolerance := 1e-3 * benchmarkBox.width
+		for k := 0; k < 2000; k++ {
+			p := vertex{source.Float64() * benchmarkBox.width, source.Float64() * benchmarkBox.height}
+			got, want := diagram.Locate(p), nearestWeightedSite(weighting, siteList, weights, nil, p)
+			if got != want && distance(got, p)-distance(want, p) > 2*tolerance {
+				t.Fatalf("%v: %v is in cell %d, nearest is %d", weighting, p, got, want)
+			}
+		}
+
+		totalArea := 0.0
+		for _, cell := range diagram.CellStats() {
+			totalArea += cell.Area
+		}
+		if math.Abs(totalArea-benchmarkBox.width*benchmarkBox.height) > 1e-6 {
+			t.Errorf("%v: cells cover %v, want %v", weighting, totalArea, benchmarkBox.width*benchmarkBox.height)
+		}
+	}
+}
+
+func TestWeightedEqualWeights(t *testing.T) {
+	// With every weight the same the cells are those of the unweighted diagram
+	siteList := uniformSites(50, rand.New(rand.NewSource(19)))
+	plain, err := Compute(siteList, benchmarkBox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := plain.CellStats()
+	for _, weighting := range []Weighting{AdditiveWeights, MultiplicativeWeights} {
+		weights := make([]float64, len(siteList))
+		for k := range weights {
+			weights[k] = 3
+		}
+		diagram, err := Compute(siteList, benchmarkBox, Options{Weighting: weighting, Weights: weights})
+		if err != nil {
+			t.Fatalf("%v: %v", weighting, err)
+		}
+		for id, cell := range diagram.CellStats() {
+			if math.Abs(cell.Area-want[id].Area) > 1e-6 || len(cell.Neighbours) != len(want[id].Neighbours) {
+				t.Fatalf("%v: cell %d has area %v and %d neighbours, want %v and %d", weighting, id, cell.Area,
+					len(cell.Neighbours), want[id].Area, len(want[id].Neighbours))
+			}
+		}
+		for _, edge := range diagram.CurvedEdges() {
+			if edge.Curve.Kind != LineCurve || len(edge.Points) != 2 {
+				t.Fatalf("%v: got edge %+v, want straight edges", weighting, edge)
+			}
+		}
+		if got, want := diagram.LargestEmptyCircle(), plain.LargestEmptyCircle(); got.Center != want.Center ||
+			got.Radius != want.Radius {
+			t.Errorf("%v: got the largest empty circle %+v, want %+v", weighting, got, want)
+		}
+	}
+}
+
+func TestMultiplicativeCircle(t *testing.T) {
+	// The lighter site's cell is the circle of points a quarter as far from it as from the heavier one
+	box := boundingBox{width: 100, height: 100}
+	diagram, err := Compute([]site{{50, 50}, {20, 80}}, box, Options{Weighting: MultiplicativeWeights,
+		Weights: []float64{1, 4}, CurveTolerance: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	centre, radius := vertex{52, 48}, 0.25*math.Hypot(30, 30)/(1-1.0/16)
+	edges := diagram.CurvedEdges()
+	if len(edges) != 1 || edges[0].Curve.Kind != CircleCurve || edges[0].Sites != [2]SiteID{0, 1} {
+		t.Fatalf("got edges %+v, want one circle around site 0", edges)
+	}
+	edge := edges[0]
+	if math.Hypot(edge.Curve.Center.x-centre.x, edge.Curve.Center.y-centre.y) > 1e-9 ||
+		math.Abs(edge.Curve.Radius-radius) > 1e-9 || edge.Points[0] != edge.Points[len(edge.Points)-1] {
+		t.Fatalf("got a circle around %v of radius %v, want a whole one around %v of radius %v", edge.Curve.Center,
+			edge.Curve.Radius, centre, radius)
+	}
+	stats := diagram.CellStats()
+	if math.Abs(stats[0].Area-math.Pi*radius*radius) > 0.01*stats[0].Area || stats[0].TouchesBoundary {
+		t.Errorf("the circle has area %v, want %v", stats[0].Area, math.Pi*radius*radius)
+	}
+	if math.Abs(stats[0].Area+stats[1].Area-box.width*box.height) > 1e-6 || len(stats[1].Neighbours) != 1 {
+		t.Errorf("the cell around the circle has area %v and neighbours %+v", stats[1].Area, stats[1].Neighbours)
+	}
+	if got := diagram.Locate(centre); got != 0 {
+		t.Errorf("the centre of the circle is in cell %d", got)
+	}
+	if got := diagram.Locate(vertex{90, 10}); got != 1 {
+		t.Errorf("(90, 10) is in cell %d", got)
+	}
+
+	var svg bytes.Buffer
+	if err := diagram.WriteSVG(&svg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(svg.String(), " A ") || !strings.HasPrefix(svg.String(), "<svg") {
+		t.Errorf("got SVG %q, want the circle drawn as arcs", svg.String())
+	}
+}
+
+func TestAdditiveHyperbola(t *testing.T) {
+	box := boundingBox{width: 100, height: 100}
+	diagram, err := Compute([]site{{30, 50}, {70, 50}}, box, Options{Weighting: AdditiveWeights,
+		Weights: []float64{10, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := diagram.CurvedEdges()
+	if len(edges) != 1 || edges[0].Curve.Kind != HyperbolaCurve {
+		t.Fatalf("got edges %+v, want one hyperbola", edges)
+	}
+	// The branch bends around the lighter site, crossing between them where it is 10 nearer
+	curve, vertexReached := edges[0].Curve, math.Inf(1)
+	for _, p := range edges[0].Points {
+		difference := math.Hypot(p.x-curve.Foci[0].x, p.y-curve.Foci[0].y) - math.Hypot(p.x-curve.Foci[1].x,
+			p.y-curve.Foci[1].y)
+		if math.Abs(difference-curve.Difference) > 1e-9 {
+			t.Fatalf("%v is %v further from the first focus, want %v", p, difference, curve.Difference)
+		}
+		vertexReached = math.Min(vertexReached, math.Hypot(p.x-55, p.y-50))
+	}
+	if vertexReached > 1e-6 {
+		t.Errorf("the hyperbola passes %v from (55, 50)", vertexReached)
+	}
+	if got := diagram.Locate(vertex{50, 50}); got != 0 {
+		t.Errorf("(50, 50) is in cell %d, want the heavier site's", got)
+	}
+
+	// A site nearer a heavier one than the difference of their weights has no cell
+	diagram, err = Compute([]site{{30, 50}, {50, 50}, {80, 80}}, box, Options{Weighting: AdditiveWeights,
+		Weights: []float64{0, 30, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := diagram.CellStats(); stats[0].Area != 0 || diagram.Locate(vertex{30, 50}) != 1 {
+		t.Errorf("the dominated site has a cell of area %v", stats[0].Area)
+	}
+}
+
+func TestWeightedInput(t *testing.T) {
+	siteList := []site{{10, 10}, {20, 20}, {30, 10}}
+	box := boundingBox{width: 100, height: 100}
+	for _, test := range []struct {
+		name    string
+		options Options
+	}{
+		{"weights without weighting", Options{Weights: []float64{1, 2, 3}}},
+		{"unknown weighting", Options{Weighting: 7, Weights: []float64{1, 2, 3}}},
+		{"too few weights", Options{Weighting: AdditiveWeights, Weights: []float64{1, 2}}},
+		{"not finite", Options{Weighting: AdditiveWeights, Weights: []float64{1, math.Inf(1), 3}}},
+		{"not positive", Options{Weighting: MultiplicativeWeights, Weights: []float64{1, 0, 3}}},
+		{"negative tolerance", Options{Weighting: AdditiveWeights, Weights: []float64{1, 2, 3}, CurveTolerance: -1}},
+		{"manhattan", Options{Weighting: AdditiveWeights, Weights: []float64{1, 2, 3}, Metric: Manhattan}},
+		{"periodic", Options{Weighting: AdditiveWeights, Weights: []float64{1, 2, 3}, Periodic: true}},
+	} {
+		if _, err := Compute(siteList, box, test.options); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: got %v, want ErrInvalidInput", test.name, err)
+		}
+	}
+
+	weighted := Options{Weighting: AdditiveWeights, Weights: []float64{1, 2, 3}}
+	if _, err := ComputeOrder(siteList, box, 2, weighted); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("order diagram: got %v, want ErrInvalidInput", err)
+	}
+	if _, err := ComputeFarthest(siteList, box, weighted); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("farthest-point diagram: got %v, want ErrInvalidInput", err)
+	}
+
+	diagram, err := Compute(siteList, box, weighted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diagram.NaturalNeighbours(vertex{50, 50}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("natural neighbours of a weighted diagram: got %v, want ErrInvalidInput", err)
+	}
+}